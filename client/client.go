@@ -0,0 +1,267 @@
+// Package client calls the proxy's routes with typed methods, instead of formatting their paths and decoding
+// their envelopes by hand:
+//
+//	c, err := client.New("https://github-api.internal", client.WithAPIKey(os.Getenv("API_KEY")))
+//	count, _, err := c.CountRepos(ctx, "octocat", "")
+//
+// The GitHub objects are go-github's, along with the proxy's own types. The methods return the envelope's
+// pagination, rate limit and request ID in the Response, and the proxy's error bodies as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Client calls the routes of a proxy, version v1
+type Client struct {
+	baseURL *url.URL
+	client  *http.Client
+	header  http.Header
+}
+
+// Option configures the Client
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the HTTP client, http.DefaultClient otherwise
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithAPIKey authenticates the requests with the consumer's key, sent in the X-API-Key header
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithGitHubToken calls GitHub with the token instead of the proxy's, sent in the X-GitHub-Token header
+func WithGitHubToken(token string) Option {
+	return WithHeader("X-GitHub-Token", token)
+}
+
+// WithHeader sends the header with every request, e.g. "Authorization: Bearer <token>" issued by the identity
+// provider
+func WithHeader(name, value string) Option {
+	return func(c *Client) {
+		c.header.Set(name, value)
+	}
+}
+
+// New returns the client of the proxy served at the base url, e.g. "https://github-api.internal"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Invalid proxy url %q", baseURL)
+	}
+
+	c := &Client{baseURL: u, client: http.DefaultClient, header: http.Header{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Response is the proxy's response, with the metadata of its envelope
+type Response struct {
+	*http.Response
+	Pagination *Pagination
+	RateLimit  *RateLimit
+	RequestID  string
+}
+
+// Pagination holds the cursors or numbers of the neighbouring pages
+type Pagination struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	FirstPage  int    `json:"first_page,omitempty"`
+	PrevPage   int    `json:"prev_page,omitempty"`
+	NextPage   int    `json:"next_page,omitempty"`
+	LastPage   int    `json:"last_page,omitempty"`
+}
+
+// RateLimit is GitHub's rate limit after the request, Reset being a unix timestamp
+type RateLimit struct {
+	Limit     int   `json:"limit"`
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"`
+}
+
+// Error is the error body of the proxy's response
+type Error struct {
+	// StatusCode is the proxy's status, e.g. GitHub's 404 or 422, or 502 when GitHub failed
+	StatusCode int          `json:"-"`
+	Message    string       `json:"error"`
+	RequestID  string       `json:"request_id,omitempty"`
+	GitHub     *GitHubError `json:"github,omitempty"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.GitHub != nil {
+		return fmt.Sprintf("%v %v: %v", e.StatusCode, e.Message, e.GitHub.Message)
+	}
+	return fmt.Sprintf("%v %v", e.StatusCode, e.Message)
+}
+
+// GitHubError is GitHub's description of the error, when the request failed there
+type GitHubError struct {
+	Status           int            `json:"status"`
+	Message          string         `json:"message"`
+	Errors           []github.Error `json:"errors,omitempty"`
+	DocumentationURL string         `json:"documentation_url,omitempty"`
+}
+
+// FieldError is an invalid field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ListOptions selects the page of the lists, or all of them when All is set
+type ListOptions struct {
+	Page    int
+	PerPage int
+	All     bool
+}
+
+func (opt *ListOptions) query(query url.Values) url.Values {
+	if opt == nil {
+		return query
+	}
+	if opt.Page > 0 {
+		query.Set("page", strconv.Itoa(opt.Page))
+	}
+	if opt.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(opt.PerPage))
+	}
+	if opt.All {
+		query.Set("all", "true")
+	}
+	return query
+}
+
+// CursorOptions selects the page of the lists paginated by cursors, the Response's NextCursor or PrevCursor
+type CursorOptions struct {
+	Cursor  string
+	PerPage int
+}
+
+func (opt *CursorOptions) query() url.Values {
+	query := url.Values{}
+	if opt == nil {
+		return query
+	}
+	if opt.Cursor != "" {
+		query.Set("cursor", opt.Cursor)
+	}
+	if opt.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(opt.PerPage))
+	}
+	return query
+}
+
+// path returns the versioned path of the route, escaping the string arguments, e.g. the owners and repositories
+func path(format string, args ...interface{}) string {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = url.PathEscape(s)
+		}
+	}
+	return "/v1" + fmt.Sprintf(format, args...)
+}
+
+// newRequest returns the request of the route's path with the query, and the JSON body unless nil
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path += path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// send sends the request, returning the proxy's error body as *Error
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode}
+	if b, _ := io.ReadAll(resp.Body); json.Unmarshal(b, e) != nil || e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return resp, e
+}
+
+// do calls the route, decoding the data of the response's envelope into out unless nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*Response, error) {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := c.send(req)
+	if err != nil {
+		if httpResp != nil {
+			return &Response{Response: httpResp}, err
+		}
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	resp := &Response{Response: httpResp}
+	if httpResp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+	envelope := struct {
+		Data       json.RawMessage `json:"data"`
+		Pagination *Pagination     `json:"pagination"`
+		RateLimit  *RateLimit      `json:"rate_limit"`
+		RequestID  string          `json:"request_id"`
+	}{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&envelope); err != nil {
+		return resp, err
+	}
+	resp.Pagination, resp.RateLimit, resp.RequestID = envelope.Pagination, envelope.RateLimit, envelope.RequestID
+	if out == nil || len(envelope.Data) == 0 {
+		return resp, nil
+	}
+	return resp, json.Unmarshal(envelope.Data, out)
+}
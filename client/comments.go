@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// ReviewComment is a comment on the diff of a pull request, with the line and side fields go-github lacks
+type ReviewComment struct {
+	ID        *int64       `json:"id,omitempty"`
+	InReplyTo *int64       `json:"in_reply_to_id,omitempty"`
+	Body      *string      `json:"body,omitempty"`
+	CommitID  *string      `json:"commit_id,omitempty"`
+	Path      *string      `json:"path,omitempty"`
+	Position  *int         `json:"position,omitempty"`
+	Line      *int         `json:"line,omitempty"`
+	Side      *string      `json:"side,omitempty"`
+	StartLine *int         `json:"start_line,omitempty"`
+	StartSide *string      `json:"start_side,omitempty"`
+	DiffHunk  *string      `json:"diff_hunk,omitempty"`
+	User      *github.User `json:"user,omitempty"`
+	HTMLURL   *string      `json:"html_url,omitempty"`
+	CreatedAt *time.Time   `json:"created_at,omitempty"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+}
+
+// CommitComment comments a commit, on the line at the position of the path's diff when set
+type CommitComment struct {
+	Body     string  `json:"body"`
+	Path     *string `json:"path,omitempty"`
+	Position *int    `json:"position,omitempty"`
+}
+
+// PullComment comments the line of a side of a pull request's diff, or the lines from StartLine, or the legacy
+// Position in the diff
+type PullComment struct {
+	// Body may be empty when the proxy has a template for the comments
+	Body     string `json:"body,omitempty"`
+	CommitID string `json:"commit_id"`
+	Path     string `json:"path"`
+	Position int    `json:"position,omitempty"`
+	// Side is LEFT or RIGHT, the default, and StartSide defaults to Side
+	Line      int    `json:"line,omitempty"`
+	Side      string `json:"side,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+}
+
+// Suggestion suggests replacing the lines from StartLine to Line of the file with the text
+type Suggestion struct {
+	Path string `json:"path"`
+	// StartLine defaults to Line
+	StartLine  int    `json:"start_line,omitempty"`
+	Line       int    `json:"line"`
+	Suggestion string `json:"suggestion"`
+	// Body is the comment above the suggestion, if any
+	Body string `json:"body,omitempty"`
+	// CommitID defaults to the pull request's head
+	CommitID string `json:"commit_id,omitempty"`
+}
+
+// CommentListOptions filters and sorts the issue and review comments listed
+type CommentListOptions struct {
+	Since time.Time
+	// Sort is created or updated, and Direction asc or desc
+	Sort      string
+	Direction string
+	ListOptions
+}
+
+func (opt *CommentListOptions) query() url.Values {
+	query := url.Values{}
+	if opt == nil {
+		return query
+	}
+	if !opt.Since.IsZero() {
+		query.Set("since", opt.Since.Format(time.RFC3339))
+	}
+	for param, value := range map[string]string{"sort": opt.Sort, "direction": opt.Direction} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	return opt.ListOptions.query(query)
+}
+
+// CreateCommitComment comments the commit
+func (c *Client) CreateCommitComment(ctx context.Context, owner, repo, sha string, comment *CommitComment) (*github.RepositoryComment, *Response, error) {
+	created := new(github.RepositoryComment)
+	resp, err := c.do(ctx, "POST", path("/%v/repos/%v/%v/comment", owner, repo, sha), nil, comment, created)
+	if err != nil {
+		return nil, resp, err
+	}
+	return created, resp, nil
+}
+
+// CreatePullComment comments the pull request's diff
+func (c *Client) CreatePullComment(ctx context.Context, owner, repo string, number int, comment *PullComment) (*ReviewComment, *Response, error) {
+	return c.reviewComment(ctx, "POST", path("/%v/repos/%v/pulls/%v/comments", owner, repo, number), comment)
+}
+
+// SuggestChange comments the pull request with the suggestion, on the right side of its diff
+func (c *Client) SuggestChange(ctx context.Context, owner, repo string, number int, suggestion *Suggestion) (*ReviewComment, *Response, error) {
+	return c.reviewComment(ctx, "POST", path("/%v/repos/%v/pulls/%v/suggestions", owner, repo, number), suggestion)
+}
+
+// ReplyToPullComment replies to the pull request's review comment, in its thread
+func (c *Client) ReplyToPullComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (*ReviewComment, *Response, error) {
+	return c.reviewComment(ctx, "POST", path("/%v/repos/%v/pulls/%v/comments/%v/replies", owner, repo, number, commentID), map[string]string{"body": body})
+}
+
+func (c *Client) reviewComment(ctx context.Context, method, path string, body interface{}) (*ReviewComment, *Response, error) {
+	comment := new(ReviewComment)
+	resp, err := c.do(ctx, method, path, nil, body, comment)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comment, resp, nil
+}
+
+// ListCommitComments lists the comments of the commit, or of the repository when sha is empty
+func (c *Client) ListCommitComments(ctx context.Context, owner, repo, sha string, opt *ListOptions) ([]*github.RepositoryComment, *Response, error) {
+	p := path("/%v/repos/%v/comments", owner, repo)
+	if sha != "" {
+		p = path("/%v/repos/%v/commits/%v/comments", owner, repo, sha)
+	}
+
+	var comments []*github.RepositoryComment
+	resp, err := c.do(ctx, "GET", p, opt.query(url.Values{}), nil, &comments)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comments, resp, nil
+}
+
+// ListIssueComments lists the comments of the issue or pull request, or of the repository's issues when number is
+// 0
+func (c *Client) ListIssueComments(ctx context.Context, owner, repo string, number int, opt *CommentListOptions) ([]*github.IssueComment, *Response, error) {
+	p := path("/%v/repos/%v/issues/comments", owner, repo)
+	if number != 0 {
+		p = path("/%v/repos/%v/issues/%v/comments", owner, repo, number)
+	}
+
+	var comments []*github.IssueComment
+	resp, err := c.do(ctx, "GET", p, opt.query(), nil, &comments)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comments, resp, nil
+}
+
+// ListPullComments lists the review comments of the pull request, or of the repository's pull requests when
+// number is 0
+func (c *Client) ListPullComments(ctx context.Context, owner, repo string, number int, opt *CommentListOptions) ([]*ReviewComment, *Response, error) {
+	p := path("/%v/repos/%v/pulls/comments", owner, repo)
+	if number != 0 {
+		p = path("/%v/repos/%v/pulls/%v/comments", owner, repo, number)
+	}
+
+	var comments []*ReviewComment
+	resp, err := c.do(ctx, "GET", p, opt.query(), nil, &comments)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comments, resp, nil
+}
+
+// EditCommitComment replaces the body of the commit comment
+func (c *Client) EditCommitComment(ctx context.Context, owner, repo string, id int64, body string) (*github.RepositoryComment, *Response, error) {
+	comment := new(github.RepositoryComment)
+	resp, err := c.do(ctx, "PATCH", path("/%v/repos/%v/comments/%v", owner, repo, id), nil, map[string]string{"body": body}, comment)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comment, resp, nil
+}
+
+// DeleteCommitComment deletes the commit comment
+func (c *Client) DeleteCommitComment(ctx context.Context, owner, repo string, id int64) (*Response, error) {
+	return c.do(ctx, "DELETE", path("/%v/repos/%v/comments/%v", owner, repo, id), nil, nil, nil)
+}
+
+// EditIssueComment replaces the body of the issue comment
+func (c *Client) EditIssueComment(ctx context.Context, owner, repo string, id int64, body string) (*github.IssueComment, *Response, error) {
+	comment := new(github.IssueComment)
+	resp, err := c.do(ctx, "PATCH", path("/%v/repos/%v/issues/comments/%v", owner, repo, id), nil, map[string]string{"body": body}, comment)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comment, resp, nil
+}
+
+// DeleteIssueComment deletes the issue comment
+func (c *Client) DeleteIssueComment(ctx context.Context, owner, repo string, id int64) (*Response, error) {
+	return c.do(ctx, "DELETE", path("/%v/repos/%v/issues/comments/%v", owner, repo, id), nil, nil, nil)
+}
+
+// EditPullComment replaces the body of the review comment
+func (c *Client) EditPullComment(ctx context.Context, owner, repo string, id int64, body string) (*ReviewComment, *Response, error) {
+	return c.reviewComment(ctx, "PATCH", path("/%v/repos/%v/pulls/comments/%v", owner, repo, id), map[string]string{"body": body})
+}
+
+// DeletePullComment deletes the review comment
+func (c *Client) DeletePullComment(ctx context.Context, owner, repo string, id int64) (*Response, error) {
+	return c.do(ctx, "DELETE", path("/%v/repos/%v/pulls/comments/%v", owner, repo, id), nil, nil, nil)
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventFilter selects the webhook events by type, e.g. "pull_request" or "pull_request.opened", and by owner,
+// repository full name and org; empty lists select every event
+type EventFilter struct {
+	Events []string `json:"events,omitempty"`
+	Owners []string `json:"owners,omitempty"`
+	Repos  []string `json:"repos,omitempty"`
+	Orgs   []string `json:"orgs,omitempty"`
+}
+
+func (filter *EventFilter) query() url.Values {
+	query := url.Values{}
+	if filter == nil {
+		return query
+	}
+	for param, values := range map[string][]string{"events": filter.Events, "owner": filter.Owners, "repo": filter.Repos, "org": filter.Orgs} {
+		if len(values) > 0 {
+			query.Set(param, strings.Join(values, ","))
+		}
+	}
+	return query
+}
+
+// StoredEvent is a webhook event kept by the proxy's store
+type StoredEvent struct {
+	ID         int64           `json:"id"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Type       string          `json:"type"`
+	Action     string          `json:"action,omitempty"`
+	DeliveryID string          `json:"delivery_id"`
+	Repo       string          `json:"repo,omitempty"`
+	Org        string          `json:"org,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// EventListOptions selects the stored events after the ID of After, until the ID of Until when set
+type EventListOptions struct {
+	EventFilter
+	After   int64
+	Until   int64
+	PerPage int
+}
+
+// ListEvents lists the stored webhook events, oldest first; the next page is after the last event's ID
+func (c *Client) ListEvents(ctx context.Context, opt *EventListOptions) ([]*StoredEvent, *Response, error) {
+	query := url.Values{}
+	if opt != nil {
+		query = opt.EventFilter.query()
+		for param, value := range map[string]int64{"after": opt.After, "until": opt.Until, "per_page": int64(opt.PerPage)} {
+			if value > 0 {
+				query.Set(param, strconv.FormatInt(value, 10))
+			}
+		}
+	}
+
+	var events []*StoredEvent
+	resp, err := c.do(ctx, "GET", path("/events"), query, nil, &events)
+	if err != nil {
+		return nil, resp, err
+	}
+	return events, resp, nil
+}
+
+// ReplayRequest selects the stored events replayed to the proxy's sinks, after the ID of After, until the ID of
+// Until when set
+type ReplayRequest struct {
+	EventFilter
+	After int64 `json:"after,omitempty"`
+	Until int64 `json:"until,omitempty"`
+}
+
+// ReplayResult is the number of replayed events, and the ID of the last one
+type ReplayResult struct {
+	Replayed int   `json:"replayed"`
+	LastID   int64 `json:"last_id,omitempty"`
+}
+
+// ReplayEvents replays the stored events to the proxy's sinks, e.g. after their outage
+func (c *Client) ReplayEvents(ctx context.Context, replay *ReplayRequest) (*ReplayResult, *Response, error) {
+	result := new(ReplayResult)
+	resp, err := c.do(ctx, "POST", path("/events/replay"), nil, replay, result)
+	if err != nil {
+		return nil, resp, err
+	}
+	return result, resp, nil
+}
+
+// StreamedEvent is a webhook event received by the proxy, the Payload being GitHub's
+type StreamedEvent struct {
+	ID      uint64
+	Type    string
+	Payload json.RawMessage
+}
+
+// StreamEvents streams the webhook events received by the proxy to the handler until the context is done, the
+// handler fails or the proxy closes the stream, returning the error. Passing the ID of the last event handled as
+// lastEventID resumes the stream with the recent events missed since. The /events/ws WebSocket route streams the
+// same events to the browsers.
+func (c *Client) StreamEvents(ctx context.Context, filter *EventFilter, lastEventID uint64, handler func(*StreamedEvent) error) error {
+	req, err := c.newRequest(ctx, "GET", path("/events/stream"), filter.query(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(lastEventID, 10))
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	// the payloads are up to GitHub's 25MB
+	scanner.Buffer(make([]byte, 64<<10), 26<<20)
+	event := new(StreamedEvent)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			event.ID, _ = strconv.ParseUint(value, 10, 64)
+		case "event":
+			event.Type = value
+		case "data":
+			event.Payload = json.RawMessage(value)
+		case "":
+			if event.Payload != nil {
+				if err := handler(event); err != nil {
+					return err
+				}
+			}
+			event = new(StreamedEvent)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+
+	"github.com/google/go-github/github"
+)
+
+// StartImport starts importing the repository from another VCS, e.g. git with its VCSURL
+func (c *Client) StartImport(ctx context.Context, owner, repo string, in *github.Import) (*github.Import, *Response, error) {
+	return c.sourceImport(ctx, "PUT", owner, repo, in)
+}
+
+// ImportProgress returns the progress of the repository's source import
+func (c *Client) ImportProgress(ctx context.Context, owner, repo string) (*github.Import, *Response, error) {
+	return c.sourceImport(ctx, "GET", owner, repo, nil)
+}
+
+// UpdateImport updates the repository's source import, e.g. its credentials
+func (c *Client) UpdateImport(ctx context.Context, owner, repo string, in *github.Import) (*github.Import, *Response, error) {
+	return c.sourceImport(ctx, "PATCH", owner, repo, in)
+}
+
+func (c *Client) sourceImport(ctx context.Context, method, owner, repo string, in *github.Import) (*github.Import, *Response, error) {
+	var body interface{}
+	if in != nil {
+		body = in
+	}
+
+	imp := new(github.Import)
+	resp, err := c.do(ctx, method, path("/%v/repos/%v/import", owner, repo), nil, body, imp)
+	if err != nil {
+		return nil, resp, err
+	}
+	return imp, resp, nil
+}
+
+// CancelImport stops the repository's source import
+func (c *Client) CancelImport(ctx context.Context, owner, repo string) (*Response, error) {
+	return c.do(ctx, "DELETE", path("/%v/repos/%v/import", owner, repo), nil, nil, nil)
+}
+
+// ImportAuthors lists the commit authors found by the source import
+func (c *Client) ImportAuthors(ctx context.Context, owner, repo string) ([]*github.SourceImportAuthor, *Response, error) {
+	var authors []*github.SourceImportAuthor
+	resp, err := c.do(ctx, "GET", path("/%v/repos/%v/import/authors", owner, repo), nil, nil, &authors)
+	if err != nil {
+		return nil, resp, err
+	}
+	return authors, resp, nil
+}
+
+// MapImportAuthor maps the imported commit author onto the email and name
+func (c *Client) MapImportAuthor(ctx context.Context, owner, repo string, id int64, author *github.SourceImportAuthor) (*github.SourceImportAuthor, *Response, error) {
+	mapped := new(github.SourceImportAuthor)
+	resp, err := c.do(ctx, "PATCH", path("/%v/repos/%v/import/authors/%v", owner, repo, id), nil, author, mapped)
+	if err != nil {
+		return nil, resp, err
+	}
+	return mapped, resp, nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// BatchRequest is a request of a batch, e.g. {Method: "GET", Path: "/v1/octocat/repos/count"}
+type BatchRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the response to a request of a batch, whose Body is its JSON body, or a JSON string when it
+// isn't JSON
+type BatchResult struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Batch sends the requests at once, returning their results in the same order
+func (c *Client) Batch(ctx context.Context, requests []*BatchRequest) ([]*BatchResult, *Response, error) {
+	var results []*BatchResult
+	resp, err := c.do(ctx, "POST", path("/batch"), nil, requests, &results)
+	if err != nil {
+		return nil, resp, err
+	}
+	return results, resp, nil
+}
+
+// Job is a request served in the background, sent with the "Prefer: respond-async" header
+type Job struct {
+	ID string `json:"id"`
+	// Status is running or done, the Result's status telling whether the request succeeded
+	Status    string       `json:"status"`
+	Method    string       `json:"method"`
+	Path      string       `json:"path"`
+	CreatedAt time.Time    `json:"created_at"`
+	DoneAt    *time.Time   `json:"done_at,omitempty"`
+	Result    *BatchResult `json:"result,omitempty"`
+}
+
+// GetJob returns the job, with the result of its request once done
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, *Response, error) {
+	job := new(Job)
+	resp, err := c.do(ctx, "GET", path("/jobs/%v", id), nil, nil, job)
+	if err != nil {
+		return nil, resp, err
+	}
+	return job, resp, nil
+}
@@ -0,0 +1,366 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// InteractionRestriction is the interaction limits of a repository or org
+type InteractionRestriction struct {
+	// Limit is one of existing_users, contributors_only or collaborators_only
+	Limit *string `json:"limit,omitempty"`
+	// Origin is repository or organization, where the limit was set
+	Origin *string `json:"origin,omitempty"`
+	// Expiry is one of one_day, three_days, one_week, one_month or six_months, only used when setting
+	Expiry    *string           `json:"expiry,omitempty"`
+	ExpiresAt *github.Timestamp `json:"expires_at,omitempty"`
+}
+
+// interactionsPath is the path of the org's interaction limits when repo is empty, otherwise the repository's
+func interactionsPath(owner, repo string) string {
+	if repo == "" {
+		return path("/orgs/%v/interaction-limits", owner)
+	}
+	return path("/%v/repos/%v/interaction-limits", owner, repo)
+}
+
+// GetInteractions returns the interaction limits of the repository, or of the org when repo is empty
+func (c *Client) GetInteractions(ctx context.Context, owner, repo string) (*InteractionRestriction, *Response, error) {
+	restriction := new(InteractionRestriction)
+	resp, err := c.do(ctx, "GET", interactionsPath(owner, repo), nil, nil, restriction)
+	if err != nil {
+		return nil, resp, err
+	}
+	return restriction, resp, nil
+}
+
+// SetInteractions limits the interactions with the repository, or the org's when repo is empty
+func (c *Client) SetInteractions(ctx context.Context, owner, repo string, restriction *InteractionRestriction) (*InteractionRestriction, *Response, error) {
+	set := new(InteractionRestriction)
+	resp, err := c.do(ctx, "PUT", interactionsPath(owner, repo), nil, restriction, set)
+	if err != nil {
+		return nil, resp, err
+	}
+	return set, resp, nil
+}
+
+// RemoveInteractions removes the interaction limits of the repository, or of the org when repo is empty
+func (c *Client) RemoveInteractions(ctx context.Context, owner, repo string) (*Response, error) {
+	return c.do(ctx, "DELETE", interactionsPath(owner, repo), nil, nil, nil)
+}
+
+// MigrationRequest selects the repositories of a migration
+type MigrationRequest struct {
+	Repositories       []string `json:"repositories"`
+	LockRepositories   bool     `json:"lock_repositories"`
+	ExcludeAttachments bool     `json:"exclude_attachments"`
+}
+
+// StartMigration starts generating the migration archive of the org's repositories
+func (c *Client) StartMigration(ctx context.Context, org string, migration *MigrationRequest) (*github.Migration, *Response, error) {
+	started := new(github.Migration)
+	resp, err := c.do(ctx, "POST", path("/orgs/%v/migrations", org), nil, migration, started)
+	if err != nil {
+		return nil, resp, err
+	}
+	return started, resp, nil
+}
+
+// ListMigrations lists the org's most recent migrations
+func (c *Client) ListMigrations(ctx context.Context, org string, opt *ListOptions) ([]*github.Migration, *Response, error) {
+	var migrations []*github.Migration
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/migrations", org), opt.query(url.Values{}), nil, &migrations)
+	if err != nil {
+		return nil, resp, err
+	}
+	return migrations, resp, nil
+}
+
+// MigrationStatus returns the org's migration
+func (c *Client) MigrationStatus(ctx context.Context, org string, id int64) (*github.Migration, *Response, error) {
+	migration := new(github.Migration)
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/migrations/%v", org, id), nil, nil, migration)
+	if err != nil {
+		return nil, resp, err
+	}
+	return migration, resp, nil
+}
+
+// MigrationArchive returns the exported archive of the migration, a .tar.gz closed by the caller
+func (c *Client) MigrationArchive(ctx context.Context, org string, id int64) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, "GET", path("/orgs/%v/migrations/%v/archive", org, id), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/gzip")
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// AuditLogOptions filters the org's audit log, and selects its page by the Response's cursors
+type AuditLogOptions struct {
+	Phrase  string
+	Include string
+	// After and Before are the cursors of the next and previous pages
+	After   string
+	Before  string
+	Order   string
+	PerPage int
+}
+
+// GetAuditLog returns the page of the org's audit log events
+func (c *Client) GetAuditLog(ctx context.Context, org string, opt *AuditLogOptions) ([]json.RawMessage, *Response, error) {
+	query := url.Values{}
+	if opt != nil {
+		for param, value := range map[string]string{"phrase": opt.Phrase, "include": opt.Include, "after": opt.After, "before": opt.Before, "order": opt.Order} {
+			if value != "" {
+				query.Set(param, value)
+			}
+		}
+		if opt.PerPage > 0 {
+			query.Set("per_page", strconv.Itoa(opt.PerPage))
+		}
+	}
+
+	var events []json.RawMessage
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/audit-log", org), query, nil, &events)
+	if err != nil {
+		return nil, resp, err
+	}
+	return events, resp, nil
+}
+
+// Discussion is a discussion of a repository
+type Discussion struct {
+	ID             string     `json:"id"`
+	Number         int        `json:"number"`
+	Title          string     `json:"title"`
+	URL            string     `json:"url"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	AnswerChosenAt *time.Time `json:"answer_chosen_at"`
+	Author         *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Category struct {
+		Name string `json:"name"`
+	} `json:"category"`
+	Comments struct {
+		TotalCount int `json:"total_count"`
+	} `json:"comments"`
+}
+
+// ListDiscussions lists the repository's discussions, most recent first
+func (c *Client) ListDiscussions(ctx context.Context, owner, repo string, opt *CursorOptions) ([]*Discussion, *Response, error) {
+	var discussions []*Discussion
+	resp, err := c.do(ctx, "GET", path("/%v/repos/%v/discussions", owner, repo), opt.query(), nil, &discussions)
+	if err != nil {
+		return nil, resp, err
+	}
+	return discussions, resp, nil
+}
+
+// Project is a project (v2) of an org
+type Project struct {
+	ID               string    `json:"id"`
+	Number           int       `json:"number"`
+	Title            string    `json:"title"`
+	URL              string    `json:"url"`
+	Closed           bool      `json:"closed"`
+	Public           bool      `json:"public"`
+	ShortDescription string    `json:"short_description"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Items            struct {
+		TotalCount int `json:"total_count"`
+	} `json:"items"`
+}
+
+// ListProjects lists the org's projects
+func (c *Client) ListProjects(ctx context.Context, org string, opt *CursorOptions) ([]*Project, *Response, error) {
+	var projects []*Project
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/projects", org), opt.query(), nil, &projects)
+	if err != nil {
+		return nil, resp, err
+	}
+	return projects, resp, nil
+}
+
+// SCIMUser is a user provisioned by the org's SCIM identity provider
+type SCIMUser struct {
+	ID         string          `json:"id,omitempty"`
+	ExternalID string          `json:"externalId,omitempty"`
+	UserName   string          `json:"userName"`
+	Name       SCIMUserName    `json:"name"`
+	Emails     []*SCIMUserMail `json:"emails"`
+	Active     *bool           `json:"active,omitempty"`
+	Schemas    []string        `json:"schemas,omitempty"`
+}
+
+// SCIMUserName is the name of a SCIM user
+type SCIMUserName struct {
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+// SCIMUserMail is an email address of a SCIM user
+type SCIMUserMail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// SCIMUserList is a page of SCIM users
+type SCIMUserList struct {
+	TotalResults int         `json:"totalResults"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	StartIndex   int         `json:"startIndex"`
+	Resources    []*SCIMUser `json:"Resources"`
+	Schemas      []string    `json:"schemas,omitempty"`
+}
+
+// SCIMListOptions selects the SCIM users listed, with GitHub's filter, e.g. `userName eq "octocat"`
+type SCIMListOptions struct {
+	StartIndex int
+	Count      int
+	Filter     string
+}
+
+// ListSCIMUsers lists the users provisioned in the org
+func (c *Client) ListSCIMUsers(ctx context.Context, org string, opt *SCIMListOptions) (*SCIMUserList, *Response, error) {
+	query := url.Values{}
+	if opt != nil {
+		if opt.StartIndex > 0 {
+			query.Set("startIndex", strconv.Itoa(opt.StartIndex))
+		}
+		if opt.Count > 0 {
+			query.Set("count", strconv.Itoa(opt.Count))
+		}
+		if opt.Filter != "" {
+			query.Set("filter", opt.Filter)
+		}
+	}
+
+	list := new(SCIMUserList)
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/scim/users", org), query, nil, list)
+	if err != nil {
+		return nil, resp, err
+	}
+	return list, resp, nil
+}
+
+// GetSCIMUser returns the org's SCIM user
+func (c *Client) GetSCIMUser(ctx context.Context, org, id string) (*SCIMUser, *Response, error) {
+	user := new(SCIMUser)
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/scim/users/%v", org, id), nil, nil, user)
+	if err != nil {
+		return nil, resp, err
+	}
+	return user, resp, nil
+}
+
+// ProvisionSCIMUser provisions the user in the org, inviting them
+func (c *Client) ProvisionSCIMUser(ctx context.Context, org string, user *SCIMUser) (*SCIMUser, *Response, error) {
+	created := new(SCIMUser)
+	resp, err := c.do(ctx, "POST", path("/orgs/%v/scim/users", org), nil, user, created)
+	if err != nil {
+		return nil, resp, err
+	}
+	return created, resp, nil
+}
+
+// DeprovisionSCIMUser removes the SCIM user from the org
+func (c *Client) DeprovisionSCIMUser(ctx context.Context, org, id string) (*Response, error) {
+	return c.do(ctx, "DELETE", path("/orgs/%v/scim/users/%v", org, id), nil, nil, nil)
+}
+
+// HookDelivery is a delivery of a webhook
+type HookDelivery struct {
+	ID          int64     `json:"id"`
+	GUID        string    `json:"guid"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Redelivery  bool      `json:"redelivery"`
+	Duration    float64   `json:"duration"`
+	Status      string    `json:"status"`
+	StatusCode  int       `json:"status_code"`
+	Event       string    `json:"event"`
+	Action      string    `json:"action,omitempty"`
+}
+
+// FailedDelivery is a failed delivery of the hook of the org, or of its repository when Repo is set
+type FailedDelivery struct {
+	Repo    string `json:"repo,omitempty"`
+	HookID  int64  `json:"hook_id"`
+	HookURL string `json:"hook_url"`
+	*HookDelivery
+}
+
+// FailedDeliveriesOptions selects the failed deliveries of the org hooks and of the repositories' hooks
+type FailedDeliveriesOptions struct {
+	Repos []string
+	// Since is how far back the deliveries are, 24h when 0
+	Since time.Duration
+}
+
+func (opt *FailedDeliveriesOptions) query() url.Values {
+	query := url.Values{}
+	if opt == nil {
+		return query
+	}
+	if len(opt.Repos) > 0 {
+		query.Set("repos", strings.Join(opt.Repos, ","))
+	}
+	if opt.Since > 0 {
+		query.Set("since", opt.Since.String())
+	}
+	return query
+}
+
+// Redelivery is a delivery to redeliver
+type Redelivery struct {
+	Repo       string `json:"repo,omitempty"`
+	HookID     int64  `json:"hook_id"`
+	DeliveryID int64  `json:"delivery_id"`
+}
+
+// RedeliveryResult is the outcome of a redelivery
+type RedeliveryResult struct {
+	Redelivery
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ListFailedDeliveries lists the failed deliveries of the hooks which haven't been delivered since, newest first
+func (c *Client) ListFailedDeliveries(ctx context.Context, org string, opt *FailedDeliveriesOptions) ([]*FailedDelivery, *Response, error) {
+	var failed []*FailedDelivery
+	resp, err := c.do(ctx, "GET", path("/orgs/%v/hooks/failed-deliveries", org), opt.query(), nil, &failed)
+	if err != nil {
+		return nil, resp, err
+	}
+	return failed, resp, nil
+}
+
+// RedeliverFailed redelivers the deliveries, or when there are none every failed delivery listed with the options
+func (c *Client) RedeliverFailed(ctx context.Context, org string, deliveries []*Redelivery, opt *FailedDeliveriesOptions) ([]*RedeliveryResult, *Response, error) {
+	var body interface{}
+	if len(deliveries) > 0 {
+		body = deliveries
+	}
+
+	var results []*RedeliveryResult
+	resp, err := c.do(ctx, "POST", path("/orgs/%v/hooks/failed-deliveries/redeliver", org), opt.query(), body, &results)
+	if err != nil {
+		return nil, resp, err
+	}
+	return results, resp, nil
+}
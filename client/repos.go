@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// TokenInfo describes the token used to serve the requests
+type TokenInfo struct {
+	Login     string      `json:"login"`
+	Scopes    []string    `json:"scopes"`
+	RateLimit github.Rate `json:"rate_limit"`
+	// ExpiresAt is only set for tokens with an expiration
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// GetTokenInfo returns the authenticated login, granted scopes, rate limit and expiry of the token
+func (c *Client) GetTokenInfo(ctx context.Context) (*TokenInfo, *Response, error) {
+	info := new(TokenInfo)
+	resp, err := c.do(ctx, "GET", path("/token/info"), nil, nil, info)
+	if err != nil {
+		return nil, resp, err
+	}
+	return info, resp, nil
+}
+
+// CountRepos returns the number of repositories of the owner, of the type when set: public, private, forks or
+// sources
+func (c *Client) CountRepos(ctx context.Context, owner, kind string) (int, *Response, error) {
+	query := url.Values{}
+	if kind != "" {
+		query.Set("type", kind)
+	}
+	return c.count(ctx, path("/%v/repos/count", owner), query)
+}
+
+// CountIssues returns the number of issues of the repository in the state: open (when empty), closed or all
+func (c *Client) CountIssues(ctx context.Context, owner, repo, state string) (int, *Response, error) {
+	return c.count(ctx, path("/%v/repos/%v/issues/count", owner, repo), stateQuery(state))
+}
+
+// CountPulls returns the number of pull requests of the repository in the state: open (when empty), closed or
+// all
+func (c *Client) CountPulls(ctx context.Context, owner, repo, state string) (int, *Response, error) {
+	return c.count(ctx, path("/%v/repos/%v/pulls/count", owner, repo), stateQuery(state))
+}
+
+// CountStargazers returns the number of stargazers of the repository
+func (c *Client) CountStargazers(ctx context.Context, owner, repo string) (int, *Response, error) {
+	return c.count(ctx, path("/%v/repos/%v/stargazers/count", owner, repo), nil)
+}
+
+func (c *Client) count(ctx context.Context, path string, query url.Values) (int, *Response, error) {
+	var count int
+	resp, err := c.do(ctx, "GET", path, query, nil, &count)
+	return count, resp, err
+}
+
+func stateQuery(state string) url.Values {
+	query := url.Values{}
+	if state != "" {
+		query.Set("state", state)
+	}
+	return query
+}
+
+// RepoListOptions filters and sorts the repositories listed, as GitHub does
+type RepoListOptions struct {
+	// Type is one of all, owner, member, public, private, forks or sources
+	Type string
+	// Sort is one of created, updated, pushed or full_name, and Direction asc or desc
+	Sort      string
+	Direction string
+	ListOptions
+}
+
+// ListRepos lists the owner's repositories
+func (c *Client) ListRepos(ctx context.Context, owner string, opt *RepoListOptions) ([]*github.Repository, *Response, error) {
+	query := url.Values{}
+	if opt != nil {
+		for param, value := range map[string]string{"type": opt.Type, "sort": opt.Sort, "direction": opt.Direction} {
+			if value != "" {
+				query.Set(param, value)
+			}
+		}
+		query = opt.ListOptions.query(query)
+	}
+
+	var repos []*github.Repository
+	resp, err := c.do(ctx, "GET", path("/%v/repos", owner), query, nil, &repos)
+	if err != nil {
+		return nil, resp, err
+	}
+	return repos, resp, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/handlers"
+	"github.com/google/go-github/github"
+)
+
+// bodyFlags are the flags of the comments' body, given inline or read from a file
+type bodyFlags struct {
+	body, bodyFile string
+}
+
+func (f *bodyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.body, "body", "", "body of the comment")
+	fs.StringVar(&f.bodyFile, "body-file", "", "file to read the body of the comment from, - for stdin")
+}
+
+// read returns the comment's body, from the file when there's one
+func (f *bodyFlags) read() (string, error) {
+	if f.bodyFile == "" {
+		if strings.TrimSpace(f.body) == "" {
+			return "", usageError("The comment's body is required, with -body or -body-file")
+		}
+		return f.body, nil
+	}
+	if f.body != "" {
+		return "", usageError("Only one of -body and -body-file can be set")
+	}
+	var in io.Reader = os.Stdin
+	if f.bodyFile != "-" {
+		file, err := os.Open(f.bodyFile)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		in = file
+	}
+	body, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		return "", usageError("The comment's body is empty")
+	}
+	return string(body), nil
+}
+
+// commentPull comments the pull request's conversation, or the line of the side of its diff when the path is set,
+// on the pull request's head unless the commit is given
+func commentPull(ctx context.Context, data *handlers.Datastore, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("comment pr", flag.ContinueOnError)
+	var repo repoFlags
+	var body bodyFlags
+	repo.register(fs)
+	body.register(fs)
+	number := fs.Int("number", 0, "number of the pull request (required)")
+	commit := fs.String("commit", "", "SHA of the commit to comment, defaulting to the pull request's head")
+	path := fs.String("path", "", "path of the file to comment the line of")
+	line := fs.Int("line", 0, "line of the diff to comment, required with -path")
+	startLine := fs.Int("start-line", 0, "first line of a multi-line comment")
+	side := fs.String("side", "RIGHT", "side of the diff: LEFT, the deletions, or RIGHT, the additions and unchanged lines")
+	if err := parse(fs, args, "owner", "repo", "number"); err != nil {
+		return nil, err
+	}
+	text, err := body.read()
+	if err != nil {
+		return nil, err
+	}
+	svc, err := data.ServiceForOwner(repo.owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if *path == "" {
+		cmt, _, err := svc.CreateIssueComment(ctx, repo.owner, repo.repo, *number, &github.IssueComment{Body: github.String(text)})
+		return cmt, err
+	}
+	if *line < 1 {
+		return nil, usageError("The -line of the diff is required with -path")
+	}
+	if *startLine >= *line {
+		return nil, usageError("The -start-line must be before the -line")
+	}
+	if *side != "LEFT" && *side != "RIGHT" {
+		return nil, usageError("The -side must be LEFT or RIGHT")
+	}
+	if *commit == "" {
+		pull, _, err := svc.GetPull(ctx, repo.owner, repo.repo, *number)
+		if err != nil {
+			return nil, err
+		}
+		*commit = pull.GetHead().GetSHA()
+	}
+	comment := &githubsvc.ReviewComment{
+		Body:     github.String(text),
+		CommitID: commit,
+		Path:     path,
+		Line:     line,
+		Side:     side,
+	}
+	if *startLine > 0 {
+		comment.StartLine, comment.StartSide = startLine, side
+	}
+	cmt, _, err := svc.CreatePullComment(ctx, repo.owner, repo.repo, *number, comment)
+	return cmt, err
+}
+
+// commentIssue comments the issue
+func commentIssue(ctx context.Context, data *handlers.Datastore, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("comment issue", flag.ContinueOnError)
+	var repo repoFlags
+	var body bodyFlags
+	repo.register(fs)
+	body.register(fs)
+	number := fs.Int("number", 0, "number of the issue (required)")
+	if err := parse(fs, args, "owner", "repo", "number"); err != nil {
+		return nil, err
+	}
+	text, err := body.read()
+	if err != nil {
+		return nil, err
+	}
+	svc, err := data.ServiceForOwner(repo.owner)
+	if err != nil {
+		return nil, err
+	}
+
+	cmt, _, err := svc.CreateIssueComment(ctx, repo.owner, repo.repo, *number, &github.IssueComment{Body: github.String(text)})
+	return cmt, err
+}
+
+// commentCommit comments the commit, or the position of the path in its diff when set
+func commentCommit(ctx context.Context, data *handlers.Datastore, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("comment commit", flag.ContinueOnError)
+	var repo repoFlags
+	var body bodyFlags
+	repo.register(fs)
+	body.register(fs)
+	sha := fs.String("sha", "", "SHA of the commit (required)")
+	path := fs.String("path", "", "path of the file to comment")
+	position := fs.Int("position", 0, "position in the file's diff to comment, required with -path")
+	if err := parse(fs, args, "owner", "repo", "sha"); err != nil {
+		return nil, err
+	}
+	text, err := body.read()
+	if err != nil {
+		return nil, err
+	}
+	comment := &github.RepositoryComment{Body: github.String(text)}
+	if *path != "" {
+		if *position < 1 {
+			return nil, usageError("The -position in the diff is required with -path")
+		}
+		comment.Path, comment.Position = path, position
+	}
+	svc, err := data.ServiceForOwner(repo.owner)
+	if err != nil {
+		return nil, err
+	}
+
+	cmt, _, err := svc.CreateCommitComment(ctx, repo.owner, repo.repo, *sha, comment)
+	return cmt, err
+}
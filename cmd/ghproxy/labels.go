@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/handlers"
+)
+
+// addLabels adds the comma separated labels to the issue or pull request
+func addLabels(ctx context.Context, data *handlers.Datastore, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("labels add", flag.ContinueOnError)
+	var repo repoFlags
+	repo.register(fs)
+	number := fs.Int("number", 0, "number of the issue or pull request (required)")
+	list := fs.String("labels", "", "comma separated labels to add (required)")
+	if err := parse(fs, args, "owner", "repo", "number", "labels"); err != nil {
+		return nil, err
+	}
+	var labels []string
+	for _, label := range strings.Split(*list, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return nil, usageError("The -labels to add are required")
+	}
+	svc, err := data.ServiceForOwner(repo.owner)
+	if err != nil {
+		return nil, err
+	}
+
+	added, _, err := svc.AddLabels(ctx, repo.owner, repo.repo, *number, labels)
+	return added, err
+}
@@ -0,0 +1,185 @@
+// Command ghproxy runs the proxy's operations from the command line, e.g. in CI scripts, calling GitHub with the
+// same service layer as the server, without serving HTTP:
+//
+//	ghproxy comment pr --owner octocat --repo hello --number 3 --body "Deployed to staging"
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/handlers"
+)
+
+// command is a subcommand, e.g. "comment pr", printing the JSON of its result
+type command struct {
+	usage string
+	run   func(ctx context.Context, data *handlers.Datastore, args []string) (interface{}, error)
+}
+
+// commands are the subcommands of each command
+var commands = map[string]map[string]command{
+	"comment": {
+		"pr":     {"comments the pull request's conversation, or the line of its diff with -path", commentPull},
+		"issue":  {"comments the issue", commentIssue},
+		"commit": {"comments the commit, or the position of its diff with -path", commentCommit},
+	},
+	"labels": {
+		"add": {"adds the labels to the issue or pull request", addLabels},
+	},
+}
+
+// errUsage fails the command line, which is answered with the usage
+var errUsage = errors.New("invalid usage")
+
+// usageError is an invalid combination of the subcommand's flags
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line, returning the exit code: 2 for an invalid command line, 1 when the command
+// failed
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ghproxy", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	token := fs.String("token", envOr("GITHUB_TOKEN", os.Getenv("TOKEN")), "GitHub token, defaulting to GITHUB_TOKEN or TOKEN")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)][fs.Arg(1)]
+	if !ok {
+		fmt.Fprintf(stderr, "ghproxy: unknown command %q\n", strings.Join(fs.Args()[:2], " "))
+		fs.Usage()
+		return 2
+	}
+	if *token == "" {
+		fmt.Fprintln(stderr, "ghproxy: a GitHub token is required, with -token or GITHUB_TOKEN")
+		return 2
+	}
+
+	data, err := newDatastore(*token)
+	if err != nil {
+		fmt.Fprintln(stderr, "ghproxy:", err)
+		return 1
+	}
+	defer data.Close()
+
+	result, err := cmd.run(ctx, data, fs.Args()[2:])
+	var invalid usageError
+	switch {
+	case errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp):
+		return 2
+	case errors.As(err, &invalid):
+		fmt.Fprintln(stderr, "ghproxy:", err)
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "ghproxy:", err)
+		return 1
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+	return 0
+}
+
+// newDatastore returns the datastore of the token, calling github.com or the GITHUB_BASE_URL enterprise server
+// through the OUTBOUND_PROXY like the server, and retrying the transient failures
+func newDatastore(token string) (*handlers.Datastore, error) {
+	outbound := githubsvc.OutboundConfig{Proxy: os.Getenv("OUTBOUND_PROXY"), CAFile: os.Getenv("OUTBOUND_CA_FILE")}
+	if err := githubsvc.ConfigureOutbound(outbound); err != nil {
+		return nil, fmt.Errorf("Invalid outbound proxy configuration: %v", err)
+	}
+	if baseURL := os.Getenv("GITHUB_BASE_URL"); baseURL != "" {
+		err := githubsvc.UseEnterprise(baseURL, os.Getenv("GITHUB_UPLOAD_URL"), os.Getenv("GITHUB_ENTERPRISE_VERSION"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid GITHUB_BASE_URL: %v", err)
+		}
+	}
+	githubsvc.ConfigureRetries(githubsvc.RetryConfig{})
+	return handlers.New(token)
+}
+
+// usage prints the global flags and the subcommands
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintln(out, "Usage: ghproxy [-token token] <command> <subcommand> [flags]")
+	fmt.Fprintln(out)
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var subs []string
+		for sub := range commands[name] {
+			subs = append(subs, sub)
+		}
+		sort.Strings(subs)
+		for _, sub := range subs {
+			fmt.Fprintf(out, "  %-16s %s\n", name+" "+sub, commands[name][sub].usage)
+		}
+	}
+	fmt.Fprintln(out)
+	fs.PrintDefaults()
+}
+
+// repoFlags are the flags locating the repository of every subcommand
+type repoFlags struct {
+	owner, repo string
+}
+
+func (f *repoFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.owner, "owner", "", "owner or org of the repository (required)")
+	fs.StringVar(&f.repo, "repo", "", "name of the repository (required)")
+}
+
+// parse parses the subcommand's flags, failing with the missing required ones
+func parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var missing []string
+	for _, name := range required {
+		if f := fs.Lookup(name); f != nil && (f.Value.String() == "" || f.Value.String() == "0") {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(fs.Output(), "ghproxy %v: %v required\n", fs.Name(), strings.Join(missing, ", "))
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// envOr returns the environment variable, or the fallback when it isn't set
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,194 @@
+// Command server serves the proxy, configured by its flags, the environment and the config file.
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/handlers"
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config, err := ParseServerConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := middleware.ConfigureLogging(config.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	settings, err := handlers.LoadConfig(config.ConfigFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Mock != "" {
+		settings.Mock = config.Mock
+	}
+
+	if err := githubsvc.ConfigureOutbound(settings.Outbound); err != nil {
+		log.Fatal("Invalid outbound proxy configuration:", err)
+	}
+	shutdownTracing, err := middleware.ConfigureTracing(ctx, githubsvc.OutboundTransport)
+	if err != nil {
+		log.Fatal("Invalid tracing configuration:", err)
+	}
+	// the calls are recorded as sent to GitHub, after the proxy's retries and caching
+	if config.Record != "" {
+		if err := githubsvc.ConfigureRecording(config.Record); err != nil {
+			log.Fatal("Invalid cassette:", err)
+		}
+	}
+	if baseURL := os.Getenv("GITHUB_BASE_URL"); baseURL != "" {
+		err = githubsvc.UseEnterprise(baseURL, os.Getenv("GITHUB_UPLOAD_URL"), os.Getenv("GITHUB_ENTERPRISE_VERSION"))
+		if err != nil {
+			log.Fatal("Invalid GITHUB_BASE_URL:", err)
+		}
+	}
+
+	githubsvc.ConfigureRetries(settings.Retry)
+	// writes hold their turn while retried
+	githubsvc.ConfigurePacing(settings.Pacing)
+	// a call failing after its retries counts as one failure
+	githubsvc.ConfigureBreaker(settings.Breaker)
+	githubsvc.ConfigureBudget(settings.Budget)
+	var redis *middleware.RedisStore
+	if settings.Redis.URL != "" {
+		if redis, err = middleware.NewRedisStore(settings.Redis); err != nil {
+			log.Fatal("Invalid Redis configuration:", err)
+		}
+		defer redis.Close()
+		// the replicas share the tokens' rate limits and the writes' pacing
+		githubsvc.ShareRateLimits(redis)
+	}
+	if size := os.Getenv("ETAG_CACHE_SIZE"); size != "" {
+		// the number of GitHub responses kept for revalidation, in Redis instead when shared by the replicas
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			log.Fatal("Invalid ETAG_CACHE_SIZE:", err)
+		}
+		var store middleware.CacheStore = middleware.NewMemoryCache(n)
+		if redis != nil {
+			store = redis
+		}
+		githubsvc.ConfigureETagCache(store)
+	}
+	// coalesced calls share their revalidation too
+	githubsvc.ConfigureCoalescing()
+	// an unreachable GitHub is reported as such, before the tokens fail their validation
+	selfCheck := config.SelfCheck != "off" && settings.Mock == ""
+	if selfCheck {
+		checkStartup(ctx, config.SelfCheck, githubsvc.CheckConnectivity)
+	}
+	data, err := handlers.NewFromConfig(settings)
+	if err != nil || data == nil || data.Client == nil {
+		log.Fatal("Invalid Github client:", err)
+	}
+	if selfCheck {
+		checkStartup(ctx, config.SelfCheck, data.SelfCheck)
+	}
+	if clientID := os.Getenv("OAUTH_CLIENT_ID"); clientID != "" {
+		var scopes []string
+		if s := os.Getenv("OAUTH_SCOPES"); s != "" {
+			scopes = strings.Split(s, ",")
+		}
+		data.OAuth = handlers.NewOAuth(clientID, os.Getenv("OAUTH_CLIENT_SECRET"), os.Getenv("OAUTH_REDIRECT_URL"), scopes)
+	}
+
+	// the replaced datastores are closed once done serving their requests in flight, bounded by the write
+	// timeout, and the current one and those still draining on shutdown
+	var mu sync.Mutex
+	current := data
+	draining := map[*handlers.Datastore]bool{}
+	drain := config.WriteTimeout
+	if drain <= 0 {
+		drain = config.ShutdownTimeout
+	}
+
+	router := &swapHandler{}
+	handler := handlers.NewRouter(data)
+	router.Store(handler)
+	stopPrefetch := handlers.StartPrefetch(handler, settings.Prefetch)
+	if config.ConfigFile != "" {
+		err = handlers.WatchConfig(config.ConfigFile, func(settings *handlers.Config) {
+			if config.Mock != "" {
+				settings.Mock = config.Mock
+			}
+			next, err := handlers.NewFromConfig(settings)
+			if err != nil {
+				slog.Error("config not applied", "error", err)
+				return
+			}
+			// keep the logged in users' sessions
+			next.OAuth = data.OAuth
+			handler := handlers.NewRouter(next)
+			mu.Lock()
+			replaced := current
+			current = next
+			draining[replaced] = true
+			// the replaced router's prefetching stops, for the new one's to take over
+			stopPrefetch()
+			stopPrefetch = handlers.StartPrefetch(handler, settings.Prefetch)
+			mu.Unlock()
+			router.Store(handler)
+			// the replaced datastore's pollers and inventory refreshes stop at once, not to dispatch the events and
+			// walk the repositories twice
+			replaced.StopBackground()
+
+			time.AfterFunc(drain, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if draining[replaced] {
+					delete(draining, replaced)
+					replaced.Close()
+				}
+			})
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err = config.Serve(ctx, router, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopPrefetch()
+		current.Close()
+		for data := range draining {
+			data.Close()
+		}
+		draining = nil
+	})
+	// the signal context is done, so the spans are flushed with a fresh one
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("flushing spans failed", "error", err)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("shut down")
+}
+
+// checkStartup runs a startup self-check, logging its failure, or exiting on it in strict mode
+func checkStartup(ctx context.Context, mode string, check func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := check(ctx); err != nil {
+		if mode == "strict" {
+			log.Fatal("Self-check failed: ", err)
+		}
+		slog.Error("self-check failed", "error", err)
+	}
+}
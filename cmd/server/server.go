@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ServerConfig is the configuration of the proxy's listeners
+type ServerConfig struct {
+	// ConfigFile is the path of the YAML config file, which is reloaded when changed
+	ConfigFile   string
+	Addrs        []string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// TLSCert and TLSKey are the paths of the certificate and key files, when serving HTTPS
+	TLSCert string
+	TLSKey  string
+	// AutocertDomains are the domains to obtain Let's Encrypt certificates for, instead of certificate files
+	AutocertDomains []string
+	// AutocertCache is the directory where the obtained certificates are kept
+	AutocertCache string
+
+	// Socket is the path of a unix domain socket to also listen on, serving plain HTTP
+	Socket string
+
+	// ShutdownTimeout is how long requests in flight are drained for, when shutting down
+	ShutdownTimeout time.Duration
+
+	// ClientCA is the path of the CA bundle verifying client certificates, which are then required for
+	// write requests, or for every request when ClientCertAll is set
+	ClientCA      string
+	ClientCertAll bool
+
+	// LogLevel is the minimum level of the logged messages
+	LogLevel string
+
+	// Mock is the directory of the JSON fixtures, or the cassette file, answering the GitHub calls instead of
+	// GitHub, overriding the config's
+	Mock string
+	// Record is the cassette file recording the outbound calls and their responses, sanitized, for Mock to
+	// replay them
+	Record string
+
+	// SelfCheck is how the startup self-check of the GitHub connectivity and tokens is handled: "warn" logs its
+	// failures, "strict" exits on them and "off" skips it
+	SelfCheck string
+}
+
+// ParseServerConfig reads the listener configuration from the command line flags, which default to the
+// CONFIG_FILE, HOST, PORT, LISTEN_ADDRS, LISTEN_SOCKET, READ_TIMEOUT, WRITE_TIMEOUT, SHUTDOWN_TIMEOUT, TLS_CERT, TLS_KEY, AUTOCERT_DOMAINS,
+// AUTOCERT_CACHE, CLIENT_CA, CLIENT_CERT_ALL, LOG_LEVEL and SELF_CHECK environment variables
+func ParseServerConfig(args []string) (*ServerConfig, error) {
+	fs := flag.NewFlagSet("github-api", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML config file")
+	host := fs.String("host", os.Getenv("HOST"), "host to listen on")
+	port := fs.String("port", envOr("PORT", "5000"), "port to listen on")
+	addrs := fs.String("addrs", os.Getenv("LISTEN_ADDRS"), "comma separated host:port addresses to listen on instead of -host and -port, or none")
+	readTimeout := fs.String("read-timeout", envOr("READ_TIMEOUT", "15s"), "maximum duration for reading a request")
+	shutdownTimeout := fs.String("shutdown-timeout", envOr("SHUTDOWN_TIMEOUT", "30s"), "maximum duration for draining requests when shutting down")
+	writeTimeout := fs.String("write-timeout", envOr("WRITE_TIMEOUT", "60s"), "maximum duration for writing a response")
+	tlsCert := fs.String("tls-cert", os.Getenv("TLS_CERT"), "path of the TLS certificate file")
+	tlsKey := fs.String("tls-key", os.Getenv("TLS_KEY"), "path of the TLS key file")
+	autocertDomains := fs.String("autocert-domains", os.Getenv("AUTOCERT_DOMAINS"), "comma separated domains to obtain Let's Encrypt certificates for")
+	socket := fs.String("socket", os.Getenv("LISTEN_SOCKET"), "path of a unix domain socket to listen on, in addition to the addresses")
+	clientCA := fs.String("client-ca", os.Getenv("CLIENT_CA"), "path of the CA bundle to verify client certificates with")
+	clientCertAll := fs.Bool("client-cert-all", os.Getenv("CLIENT_CERT_ALL") == "true", "require client certificates for every request, not only writes")
+	autocertCache := fs.String("autocert-cache", envOr("AUTOCERT_CACHE", "certs"), "directory to cache Let's Encrypt certificates in")
+	mock := fs.String("mock", "", "directory of the JSON fixtures, or cassette file, answering the GitHub calls instead of GitHub, without a token")
+	record := fs.String("record", "", "cassette file to record the GitHub calls and their responses in, sanitized, for -mock to replay them")
+	logLevel := fs.String("log-level", envOr("LOG_LEVEL", "info"), "minimum level of the logged messages: debug, info, warn or error")
+	selfCheck := fs.String("self-check", envOr("SELF_CHECK", "warn"), "startup check of the GitHub connectivity and tokens: warn or strict on failure, or off")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := &ServerConfig{
+		ConfigFile:    *configFile,
+		Socket:        *socket,
+		TLSCert:       *tlsCert,
+		TLSKey:        *tlsKey,
+		AutocertCache: *autocertCache,
+		ClientCA:      *clientCA,
+		ClientCertAll: *clientCertAll,
+		LogLevel:      *logLevel,
+		Mock:          *mock,
+		Record:        *record,
+		SelfCheck:     *selfCheck,
+	}
+	if *autocertDomains != "" {
+		for _, domain := range strings.Split(*autocertDomains, ",") {
+			config.AutocertDomains = append(config.AutocertDomains, strings.TrimSpace(domain))
+		}
+	}
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return nil, errors.New("Both -tls-cert and -tls-key are required to serve HTTPS")
+	}
+	if config.TLSCert != "" && len(config.AutocertDomains) > 0 {
+		return nil, errors.New("Certificate files and autocert domains are mutually exclusive")
+	}
+	if config.ClientCA != "" && config.TLSCert == "" && len(config.AutocertDomains) == 0 {
+		return nil, errors.New("Client certificates require serving HTTPS")
+	}
+	switch config.SelfCheck {
+	case "warn", "strict", "off":
+	default:
+		return nil, fmt.Errorf("Invalid self-check mode %q, expected warn, strict or off", config.SelfCheck)
+	}
+	if *addrs == "none" {
+		// only listen on the socket
+		if *socket == "" {
+			return nil, errors.New("-addrs=none requires a -socket to listen on")
+		}
+	} else if *addrs != "" {
+		for _, addr := range strings.Split(*addrs, ",") {
+			config.Addrs = append(config.Addrs, strings.TrimSpace(addr))
+		}
+	} else {
+		config.Addrs = []string{fmt.Sprintf("%v:%v", *host, *port)}
+	}
+
+	var err error
+	if config.ReadTimeout, err = time.ParseDuration(*readTimeout); err != nil {
+		return nil, fmt.Errorf("Invalid read timeout: %v", err)
+	}
+	if config.WriteTimeout, err = time.ParseDuration(*writeTimeout); err != nil {
+		return nil, fmt.Errorf("Invalid write timeout: %v", err)
+	}
+	if config.ShutdownTimeout, err = time.ParseDuration(*shutdownTimeout); err != nil {
+		return nil, fmt.Errorf("Invalid shutdown timeout: %v", err)
+	}
+	return config, nil
+}
+
+// Serve listens on every configured address, over HTTPS when TLS is configured, and on the unix socket, until
+// a listener fails or ctx is done. It then stops accepting connections and drains the requests in flight for
+// up to the shutdown timeout, before calling cancel to abort the remaining ones.
+func (config *ServerConfig) Serve(ctx context.Context, handler http.Handler, cancel func()) error {
+	// access to the socket is controlled by its file permissions, so it doesn't require client certificates
+	local := handler
+
+	var tlsConfig *tls.Config
+	if len(config.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.AutocertDomains...),
+			Cache:      autocert.DirCache(config.AutocertCache),
+		}
+		// certificates are obtained through the TLS-ALPN-01 challenge, on the listeners themselves
+		tlsConfig = m.TLSConfig()
+	}
+	secure := tlsConfig != nil || config.TLSCert != ""
+
+	if config.ClientCA != "" {
+		pem, err := ioutil.ReadFile(config.ClientCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("No certificates found in " + config.ClientCA)
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig.ClientCAs = pool
+		if config.ClientCertAll {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			// read requests are allowed without a certificate, writes are rejected by RequireClientCert
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			handler = middleware.RequireClientCert(handler)
+		}
+	}
+
+	var servers []*http.Server
+	errs := make(chan error, len(config.Addrs)+1)
+	if config.Socket != "" {
+		// a socket file left by a previous run would make listening fail
+		if err := os.Remove(config.Socket); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		l, err := net.Listen("unix", config.Socket)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{
+			Handler:      local,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+		}
+		servers = append(servers, server)
+		slog.Info("listening", "socket", config.Socket)
+		go func() {
+			errs <- server.Serve(l)
+		}()
+	}
+	for _, addr := range config.Addrs {
+		server := &http.Server{
+			Addr:         addr,
+			Handler:      handler,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		}
+		servers = append(servers, server)
+		if secure {
+			slog.Info("listening", "addr", addr, "tls", true)
+		} else {
+			slog.Info("listening", "addr", addr)
+		}
+		go func() {
+			if secure {
+				// the certificate files are empty when using autocert
+				errs <- server.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+				return
+			}
+			errs <- server.ListenAndServe()
+		}()
+	}
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	}
+
+	shutdownCtx, done := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer done()
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			server.Shutdown(shutdownCtx)
+		}(server)
+	}
+	wg.Wait()
+
+	// the requests still in flight after the timeout are aborted
+	cancel()
+	for _, server := range servers {
+		server.Close()
+	}
+	return err
+}
+
+// envOr returns the environment variable, or the fallback when it isn't set
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// swapHandler serves requests with the latest router, which is replaced whenever the config is reloaded
+type swapHandler struct {
+	handler atomic.Value
+}
+
+func (h *swapHandler) Store(handler http.Handler) {
+	h.handler.Store(&handler)
+}
+
+func (h *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.handler.Load().(*http.Handler)).ServeHTTP(w, r)
+}
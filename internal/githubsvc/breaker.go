@@ -0,0 +1,108 @@
+package githubsvc
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerConfig configures the circuit breaker of the GitHub calls. It's only read at startup.
+type BreakerConfig struct {
+	// Failures is the number of consecutive failed calls tripping the breaker, defaulting to 5; -1 disables it
+	Failures int `yaml:"failures"`
+	// Cooldown is how long calls fail fast once tripped, before one is let through to probe GitHub, defaulting
+	// to 30s
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// CircuitOpenError is returned for the GitHub calls failed fast while GitHub is degraded
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("GitHub is unavailable, retry in %v", e.RetryAfter.Round(time.Second))
+}
+
+// ConfigureBreaker fails the GitHub API calls fast once the configured number of consecutive calls failed or
+// timed out, or were answered with a server error or a 429, until a probe call after the cooldown succeeds. The
+// calls whose own context is done aren't counted.
+func ConfigureBreaker(config BreakerConfig) {
+	if config.Failures == 0 {
+		config.Failures = 5
+	}
+	if config.Cooldown == 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	if config.Failures > 0 {
+		OutboundTransport = &breakerTransport{base: OutboundTransport, config: config}
+	}
+}
+
+// breakerTransport is a circuit breaker: closed while GitHub is healthy, open while failing fast, and half
+// open while probing
+type breakerTransport struct {
+	base   http.RoundTripper
+	config BreakerConfig
+
+	mu       sync.Mutex
+	failures int
+	// openUntil is when the open breaker lets a probe through
+	openUntil time.Time
+	probing   bool
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isGitHubAPI(req) {
+		return t.base.RoundTrip(req)
+	}
+	if err := t.allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		// calls cancelled by their consumer, or past their own deadline, say nothing of GitHub
+		t.mu.Lock()
+		t.probing = false
+		t.mu.Unlock()
+		return resp, err
+	}
+	t.record(err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests)
+	return resp, err
+}
+
+// allow returns a CircuitOpenError while the breaker is open, or a probe is already being made
+func (t *breakerTransport) allow() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failures < t.config.Failures {
+		return nil
+	}
+	if wait := time.Until(t.openUntil); wait > 0 || t.probing {
+		if wait <= 0 {
+			wait = time.Second
+		}
+		return &CircuitOpenError{RetryAfter: wait}
+	}
+	t.probing = true
+	return nil
+}
+
+// record closes the breaker after a successful call, and opens it after too many failed ones
+func (t *breakerTransport) record(failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.probing = false
+	if !failed {
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if t.failures >= t.config.Failures {
+		t.openUntil = time.Now().Add(t.config.Cooldown)
+	}
+}
@@ -0,0 +1,80 @@
+package githubsvc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outcomeTransport answers every call with the status, or fails it with the error once the request's context is
+// done when wait is set
+type outcomeTransport struct {
+	status int
+	err    error
+	wait   bool
+}
+
+func (t *outcomeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.wait {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	return &http.Response{StatusCode: t.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func TestBreakerFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    *outcomeTransport
+		ctx     func() (context.Context, context.CancelFunc)
+		tripped bool
+	}{
+		{name: "server error", base: &outcomeTransport{status: http.StatusBadGateway}, tripped: true},
+		{name: "rate limited", base: &outcomeTransport{status: http.StatusTooManyRequests}, tripped: true},
+		{name: "transport error", base: &outcomeTransport{err: errors.New("connection reset")}, tripped: true},
+		{name: "not found", base: &outcomeTransport{status: http.StatusNotFound}},
+		{name: "forbidden", base: &outcomeTransport{status: http.StatusForbidden}},
+		{
+			name: "past the request's deadline", base: &outcomeTransport{wait: true},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+		},
+		{
+			name: "cancelled by the consumer", base: &outcomeTransport{wait: true},
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := &breakerTransport{base: test.base, config: BreakerConfig{Failures: 2, Cooldown: time.Minute}}
+			for i := 0; i < 2; i++ {
+				ctx, cancel := context.Background(), context.CancelFunc(func() {})
+				if test.ctx != nil {
+					ctx, cancel = test.ctx()
+				}
+				req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/repos/octocat/hello-world", nil)
+				if resp, err := transport.RoundTrip(req); err == nil {
+					resp.Body.Close()
+				}
+				cancel()
+			}
+
+			var open *CircuitOpenError
+			if err := transport.allow(); errors.As(err, &open) != test.tripped {
+				t.Errorf("allowed with %v, want tripped %v", err, test.tripped)
+			}
+		})
+	}
+}
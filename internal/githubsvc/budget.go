@@ -0,0 +1,263 @@
+package githubsvc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BudgetConfig configures the scheduling of the GitHub calls by priority, keeping the end of each token's rate
+// limit for the interactive ones. It's only read at startup, except for BackgroundRoutes.
+type BudgetConfig struct {
+	// Reserve is the number of calls left of a token's rate limit kept for the interactive calls, defaulting to
+	// 500; -1 disables the scheduler
+	Reserve int `yaml:"reserve"`
+	// MaxDelay is how long background calls wait for the rate limit to reset, before being rejected instead,
+	// defaulting to 10s
+	MaxDelay time.Duration `yaml:"max_delay"`
+	// BackgroundRoutes are the route groups whose calls are background ones, e.g. "migrations"
+	BackgroundRoutes []string `yaml:"background_routes"`
+}
+
+// BudgetExhaustedError is returned for the background calls rejected to spare the rate limit
+type BudgetExhaustedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	return fmt.Sprintf("The GitHub rate limit is reserved for interactive requests, retry in %v", e.RetryAfter.Round(time.Second))
+}
+
+type contextKey string
+
+const backgroundKey contextKey = "background"
+
+// Background marks the request's GitHub calls as background ones, e.g. exports and prefetching, which are
+// delayed or rejected once the rate limit runs low
+func Background(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backgroundKey, true)
+}
+
+// IsBackground reports whether the request's GitHub calls are background ones
+func IsBackground(ctx context.Context) bool {
+	background, _ := ctx.Value(backgroundKey).(bool)
+	return background
+}
+
+// BackgroundPriority makes the requests background ones, e.g. of the background route groups
+func BackgroundPriority(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(Background(r.Context())))
+	})
+}
+
+// RequestPriority makes the requests of the consumers sending X-Request-Priority: background background ones.
+// Consumers can't raise the priority of the background routes.
+func RequestPriority(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-Priority") == "background" {
+			r = r.WithContext(Background(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// budgetReserve is the configured reserve, left alone by the background fan-outs
+var budgetReserve int
+
+// budgets is the scheduler's transport, tracking the rate limits of the tokens, when configured
+var budgets *budgetTransport
+
+// CallBudget returns how many of the token's remaining calls the request may spend at once, e.g. on the pages of a
+// list: all of them for the interactive requests, and those above the reserve for the background ones
+func CallBudget(ctx context.Context, remaining int) int {
+	if IsBackground(ctx) {
+		remaining -= budgetReserve
+	}
+	return max(remaining, 0)
+}
+
+// ConfigureBudget schedules the GitHub API calls by priority: interactive calls are always made, while
+// background ones wait for the rate limit to reset once the token has no more than the reserve left
+func ConfigureBudget(config BudgetConfig) {
+	if config.Reserve == 0 {
+		config.Reserve = 500
+	}
+	if config.MaxDelay == 0 {
+		config.MaxDelay = 10 * time.Second
+	}
+	if config.Reserve > 0 {
+		budgetReserve = config.Reserve
+		budgets = &budgetTransport{base: OutboundTransport, config: config, budgets: map[budgetKey]*tokenBudget{}}
+		OutboundTransport = budgets
+	}
+}
+
+// budgetTransport tracks the rate limit left of each token and resource, as reported by GitHub
+type budgetTransport struct {
+	base   http.RoundTripper
+	config BudgetConfig
+
+	mu      sync.Mutex
+	budgets map[budgetKey]*tokenBudget
+}
+
+// budgetKey identifies the rate limit of a token's resource, e.g. "core", "search" or "graphql", each limited on
+// its own
+type budgetKey struct {
+	// token is the hash of the Authorization header, the tokens only being kept hashed
+	token    [32]byte
+	resource string
+}
+
+// shared returns the key of the budget shared by the replicas
+func (key budgetKey) shared() string {
+	return hex.EncodeToString(key.token[:]) + ":" + key.resource
+}
+
+// requestResource returns the rate limit resource the call is counted against
+func requestResource(req *http.Request) string {
+	switch {
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+type tokenBudget struct {
+	// limit is the resource's rate limit, 0 when unknown
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+// reserve returns the calls kept for the interactive ones, at most a tenth of the resource's limit for the reserve
+// not to take the whole of the smaller ones, e.g. the 30 searches a minute
+func (budget *tokenBudget) reserve(reserve int) int {
+	if budget.limit > 0 {
+		return min(reserve, budget.limit/10)
+	}
+	return reserve
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isGitHubAPI(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	key := budgetKey{token: sha256.Sum256([]byte(req.Header.Get("Authorization"))), resource: requestResource(req)}
+	if IsBackground(req.Context()) {
+		if err := t.wait(req.Context(), key); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		if resource := resp.Header.Get("X-RateLimit-Resource"); resource != "" {
+			key.resource = resource
+		}
+		seconds, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		reset := time.Unix(seconds, 0)
+		if shared != nil {
+			merged, mergedReset, err := shared.ShareRateLimit(req.Context(), key.shared(), remaining, reset)
+			if err != nil {
+				slog.ErrorContext(req.Context(), "sharing the rate limit failed", "error", err)
+			} else {
+				remaining, reset = merged, mergedReset
+			}
+		}
+		t.mu.Lock()
+		if _, ok := t.budgets[key]; !ok {
+			// drop the budgets whose rate limit reset, e.g. of the consumers' own tokens
+			for k, budget := range t.budgets {
+				if time.Now().After(budget.reset) {
+					delete(t.budgets, k)
+				}
+			}
+		}
+		limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+		t.budgets[key] = &tokenBudget{limit: limit, remaining: remaining, reset: reset}
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// wait holds the background call back until the rate limit resets, when the token's resource is down to its
+// reserve
+func (t *budgetTransport) wait(ctx context.Context, key budgetKey) error {
+	t.mu.Lock()
+	budget, ok := t.budgets[key]
+	t.mu.Unlock()
+	if shared != nil {
+		// the other replicas may have spent the token since
+		if remaining, reset, found := shared.SharedRateLimit(ctx, key.shared()); found {
+			limit := 0
+			if budget != nil {
+				limit = budget.limit
+			}
+			budget, ok = &tokenBudget{limit: limit, remaining: remaining, reset: reset}, true
+			t.mu.Lock()
+			t.budgets[key] = budget
+			t.mu.Unlock()
+		}
+	}
+	if !ok || budget.remaining > budget.reserve(t.config.Reserve) {
+		return nil
+	}
+
+	delay := time.Until(budget.reset)
+	if delay <= 0 {
+		return nil
+	}
+	if delay > t.config.MaxDelay {
+		return &BudgetExhaustedError{RetryAfter: delay}
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Saturated returns how long until the first rate limit resets when the rate limits of every token and resource
+// GitHub reported on are spent, down to the reserve for the background requests, or 0 while a token has calls left or the
+// scheduler isn't configured
+func Saturated(ctx context.Context) time.Duration {
+	if budgets == nil {
+		return 0
+	}
+	background := IsBackground(ctx)
+
+	budgets.mu.Lock()
+	defer budgets.mu.Unlock()
+	var wait time.Duration
+	for _, budget := range budgets.budgets {
+		floor := 0
+		if background {
+			floor = budget.reserve(budgetReserve)
+		}
+		delay := time.Until(budget.reset)
+		if budget.remaining > floor || delay <= 0 {
+			return 0
+		}
+		if wait == 0 || delay < wait {
+			wait = delay
+		}
+	}
+	return wait
+}
@@ -0,0 +1,100 @@
+package githubsvc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rateLimitedTransport answers every call with the rate limit of the resource of its path
+type rateLimitedTransport struct {
+	limits map[string][2]int
+	reset  time.Time
+	// headers is whether the resource is reported in X-RateLimit-Resource
+	headers bool
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := requestResource(req)
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", strconv.Itoa(t.limits[resource][0]))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(t.limits[resource][1]))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(t.reset.Unix(), 10))
+	if t.headers {
+		header.Set("X-RateLimit-Resource", resource)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func TestBudgetByResource(t *testing.T) {
+	for _, headers := range []bool{true, false} {
+		t.Run("resource header "+strconv.FormatBool(headers), func(t *testing.T) {
+			base := &rateLimitedTransport{
+				// the searches are spent, while most of the core calls are left
+				limits:  map[string][2]int{"search": {30, 0}, "core": {5000, 4000}, "graphql": {5000, 4500}},
+				reset:   time.Now().Add(time.Hour),
+				headers: headers,
+			}
+			transport := &budgetTransport{base: base, config: BudgetConfig{Reserve: 500, MaxDelay: 10 * time.Second}, budgets: map[budgetKey]*tokenBudget{}}
+			previous, previousReserve := budgets, budgetReserve
+			budgets, budgetReserve = transport, 500
+			t.Cleanup(func() { budgets, budgetReserve = previous, previousReserve })
+
+			call := func(ctx context.Context, path string) error {
+				req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.github.com"+path, nil)
+				req.Header.Set("Authorization", "token t0ken")
+				resp, err := transport.RoundTrip(req)
+				if err == nil {
+					resp.Body.Close()
+				}
+				return err
+			}
+			for _, path := range []string{"/search/issues", "/repos/octocat/hello-world"} {
+				if err := call(context.Background(), path); err != nil {
+					t.Fatalf("interactive %v: %v", path, err)
+				}
+			}
+
+			background := Background(context.Background())
+			var exhausted *BudgetExhaustedError
+			if err := call(background, "/search/issues"); !errors.As(err, &exhausted) {
+				t.Errorf("background search: %v, want the budget exhausted", err)
+			}
+			start := time.Now()
+			if err := call(background, "/repos/octocat/hello-world/issues"); err != nil {
+				t.Errorf("background core call: %v", err)
+			}
+			if err := call(background, "/graphql"); err != nil {
+				t.Errorf("background GraphQL call: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("background core calls delayed %v by the spent searches", elapsed)
+			}
+			if wait := Saturated(background); wait != 0 {
+				t.Errorf("saturated for %v with core calls left", wait)
+			}
+		})
+	}
+}
+
+func TestRequestResource(t *testing.T) {
+	tests := map[string]string{
+		"https://api.github.com/search/repositories?q=go":   "search",
+		"https://api.github.com/graphql":                    "graphql",
+		"https://github.example.com/api/graphql":            "graphql",
+		"https://github.example.com/api/v3/search/issues":   "search",
+		"https://api.github.com/repos/octocat/hello-world":  "core",
+		"https://api.github.com/orgs/octocat/search-things": "core",
+	}
+	for url, want := range tests {
+		req, _ := http.NewRequest("GET", url, nil)
+		if got := requestResource(req); got != want {
+			t.Errorf("%v: %v, want %v", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package githubsvc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ConfigureCoalescing makes concurrent identical GitHub API GETs share a single call, e.g. when many dashboards
+// refresh at once
+func ConfigureCoalescing() {
+	OutboundTransport = &coalescingTransport{base: OutboundTransport}
+}
+
+// coalescingTransport shares the response of a GitHub API GET with the identical ones made meanwhile
+type coalescingTransport struct {
+	base  http.RoundTripper
+	group singleflight.Group
+}
+
+// sharedResponse is a response read whole, to be copied for each caller
+type sharedResponse struct {
+	resp *http.Response
+	body []byte
+}
+
+func (t *coalescingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || !isGitHubAPI(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	ch := t.group.DoChan(etagKey(req), func() (interface{}, error) {
+		// the shared call outlives the caller making it, up to its deadline, as the others are waiting on it
+		ctx := context.WithoutCancel(req.Context())
+		if deadline, ok := req.Context().Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+
+		resp, err := t.base.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedResponse{resp: resp, body: body}, nil
+	})
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		shared := res.Val.(*sharedResponse)
+		resp := *shared.resp
+		resp.Header = shared.resp.Header.Clone()
+		resp.Body = io.NopCloser(bytes.NewReader(shared.body))
+		resp.Request = req
+		return &resp, nil
+	}
+}
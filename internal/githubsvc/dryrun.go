@@ -0,0 +1,67 @@
+package githubsvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// dryRunTransport records the writes of the dry run requests in their DryRun instead of sending them, answering
+// them with an empty 204. Their reads, e.g. of a pull request's head, are sent.
+type dryRunTransport struct {
+	base http.RoundTripper
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dryRun := middleware.DryRunFrom(req.Context())
+	if dryRun == nil || !middleware.IsWrite(req.Method) {
+		return t.base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isGraphQLQuery(req, body) {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return t.base.RoundTrip(req)
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	dryRun.Record(&middleware.DryRunRequest{Method: req.Method, URL: req.URL.String(), Body: body})
+	return &http.Response{
+		Status:     "204 No Content",
+		StatusCode: http.StatusNoContent,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// isGraphQLQuery reports whether the request is a GraphQL query rather than a mutation, which are both POSTed
+func isGraphQLQuery(req *http.Request, body []byte) bool {
+	if !strings.HasSuffix(req.URL.Path, "/graphql") {
+		return false
+	}
+	var graphql struct {
+		Query string `json:"query"`
+	}
+	if json.Unmarshal(body, &graphql) != nil {
+		return false
+	}
+	return !strings.HasPrefix(strings.TrimSpace(graphql.Query), "mutation")
+}
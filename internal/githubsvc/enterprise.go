@@ -0,0 +1,136 @@
+package githubsvc
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	githuboauth "golang.org/x/oauth2/github"
+)
+
+// features which aren't available on every GitHub Enterprise Server version
+const (
+	FeatureInteractionLimits = "interaction-limits"
+	FeatureAuditLog          = "audit-log"
+	FeatureSCIM              = "scim"
+	FeatureDiscussions       = "discussions"
+	FeatureProjectsV2        = "projects"
+	FeatureHookDeliveries    = "hook-deliveries"
+)
+
+// enterprise is the GitHub Enterprise Server instance the clients connect to, or nil for github.com
+var enterprise *enterpriseServer
+
+type enterpriseServer struct {
+	baseURL   *url.URL
+	uploadURL *url.URL
+	// version is the major and minor version of the server, e.g. [3, 4] (empty when unknown)
+	version []int
+}
+
+// UseEnterprise makes all the clients created afterwards connect to a GitHub Enterprise Server instance.
+// The upload url defaults to the base url's host, and version (e.g. "3.4") enables the version dependent
+// features; when omitted the latest server version is assumed.
+func UseEnterprise(baseURL, uploadURL, version string) error {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	if base.Path == "" || base.Path == "/" {
+		base.Path = "/api/v3/"
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	upload := &url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/api/uploads/"}
+	if uploadURL != "" {
+		if upload, err = url.Parse(uploadURL); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(upload.Path, "/") {
+			upload.Path += "/"
+		}
+	}
+
+	server := &enterpriseServer{baseURL: base, uploadURL: upload}
+	if version != "" {
+		parts := append(strings.SplitN(version, ".", 3), "0")
+		for _, part := range parts[:2] {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return err
+			}
+			server.version = append(server.version, n)
+		}
+	}
+
+	enterprise = server
+	return nil
+}
+
+// Supports reports whether the connected GitHub server provides the feature
+func Supports(feature string) bool {
+	if enterprise == nil {
+		return true
+	}
+
+	switch feature {
+	case FeatureInteractionLimits, FeatureSCIM:
+		// only available on github.com
+		return false
+	case FeatureAuditLog:
+		return enterprise.atLeast(3, 0)
+	case FeatureDiscussions:
+		return enterprise.atLeast(3, 6)
+	case FeatureProjectsV2:
+		return enterprise.atLeast(3, 7)
+	case FeatureHookDeliveries:
+		return enterprise.atLeast(3, 2)
+	}
+	return true
+}
+
+// atLeast reports whether the server's version is at least major.minor, which is assumed when it's unknown
+func (s *enterpriseServer) atLeast(major, minor int) bool {
+	if len(s.version) < 2 {
+		return true
+	}
+	return s.version[0] > major || (s.version[0] == major && s.version[1] >= minor)
+}
+
+// NewClient returns a Github client for github.com or the configured enterprise server. Its calls are
+// traced, their rate limit is logged and the request IDs are forwarded, so the http client must not be shared.
+// The writes of the dry run requests are recorded instead of sent.
+func NewClient(httpClient *http.Client) *github.Client {
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = &dryRunTransport{base: &middleware.TracingTransport{Base: &middleware.RateLimitRecorder{Base: &middleware.RequestIDTransport{Base: base}}}}
+
+	if enterprise == nil {
+		return github.NewClient(httpClient)
+	}
+
+	// the urls were validated by UseEnterprise
+	client, _ := github.NewEnterpriseClient(enterprise.baseURL.String(), enterprise.uploadURL.String(), httpClient)
+	return client
+}
+
+// OAuthEndpoint returns the OAuth endpoint of github.com or the configured enterprise server
+func OAuthEndpoint() oauth2.Endpoint {
+	if enterprise == nil {
+		return githuboauth.Endpoint
+	}
+
+	host := enterprise.baseURL.Scheme + "://" + enterprise.baseURL.Host
+	return oauth2.Endpoint{
+		AuthURL:  host + "/login/oauth/authorize",
+		TokenURL: host + "/login/oauth/access_token",
+	}
+}
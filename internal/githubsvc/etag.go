@@ -0,0 +1,97 @@
+package githubsvc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// ConfigureETagCache makes the GitHub API calls conditional on the responses kept in the store: their ETag or
+// Last-Modified is sent along, and GitHub's 304s are answered with the stored response. Conditional requests
+// answered with a 304 don't count against the rate limit.
+func ConfigureETagCache(store middleware.CacheStore) {
+	OutboundTransport = &etagTransport{base: OutboundTransport, store: store}
+}
+
+// etagTransport revalidates the stored GitHub responses instead of fetching them again
+type etagTransport struct {
+	base  http.RoundTripper
+	store middleware.CacheStore
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || !isGitHubAPI(req) || req.Header.Get("If-None-Match") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := etagKey(req)
+	cached := t.load(req, key)
+	if cached != nil {
+		// RoundTrippers must not modify the original request
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		} else {
+			req.Header.Set("If-Modified-Since", cached.Header.Get("Last-Modified"))
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		// the 304 carries the current rate limit
+		for _, header := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+			if value := resp.Header.Get(header); value != "" {
+				cached.Header.Set(header, value)
+			}
+		}
+		cached.Request = req
+		return cached, nil
+	}
+
+	if resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") {
+		// reads the body, replacing it with a copy
+		if dump, err := httputil.DumpResponse(resp, true); err == nil {
+			t.store.Set(req.Context(), key, dump, 0)
+		}
+	}
+	return resp, nil
+}
+
+// load returns the stored response of the key, or nil
+func (t *etagTransport) load(req *http.Request, key string) *http.Response {
+	dump, ok := t.store.Get(req.Context(), key)
+	if !ok {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// etagKey identifies the response by url, media type and token, as responses differ per token's access
+func etagKey(req *http.Request) string {
+	hash := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" + req.Header.Get("Authorization")))
+	return "etag:" + hex.EncodeToString(hash[:])
+}
+
+// isGitHubAPI reports whether the request is a call to the GitHub API, rather than e.g. an archive download
+func isGitHubAPI(req *http.Request) bool {
+	if enterprise == nil {
+		return req.URL.Host == "api.github.com"
+	}
+	return req.URL.Host == enterprise.baseURL.Host &&
+		(strings.HasPrefix(req.URL.Path, enterprise.baseURL.Path) || req.URL.Path == enterprise.baseURL.JoinPath(graphqlPath).Path)
+}
@@ -0,0 +1,59 @@
+package githubsvc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// graphqlPath is the GraphQL endpoint relative to the REST API's base url, /graphql on github.com and
+// /api/graphql on the enterprise servers
+const graphqlPath = "../graphql"
+
+// GraphQLError is an error of a GraphQL response, e.g. of type NOT_FOUND
+type GraphQLError struct {
+	Type      string          `json:"type,omitempty"`
+	Message   string          `json:"message"`
+	Path      []interface{}   `json:"path,omitempty"`
+	Locations json.RawMessage `json:"locations,omitempty"`
+	// Extensions are kept for the responses forwarded whole
+	Extensions json.RawMessage `json:"extensions,omitempty"`
+}
+
+// GraphQLErrors are returned for the GraphQL responses with errors, which GitHub answers with 200 OK
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Status returns the HTTP status matching the type of the first error
+func (e GraphQLErrors) Status() int {
+	switch e[0].Type {
+	case "NOT_FOUND":
+		return http.StatusNotFound
+	case "FORBIDDEN":
+		return http.StatusForbidden
+	case "RATE_LIMITED":
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadGateway
+}
+
+// GraphQLRequest is a GraphQL query with its variables, and the name of the operation to run when the query has
+// several
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLResponse is the body of the GraphQL responses
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors,omitempty"`
+}
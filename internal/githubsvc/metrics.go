@@ -0,0 +1,18 @@
+package githubsvc
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// the proxy's Prometheus metrics, served on /metrics
+var (
+	writeQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "github_api_write_queue_depth",
+		Help: "Mutating GitHub calls waiting for their turn to be paced.",
+	})
+	secondaryRateLimits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "github_api_secondary_rate_limits_total",
+		Help: "GitHub calls answered with a secondary rate limit.",
+	})
+)
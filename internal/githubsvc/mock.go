@@ -0,0 +1,96 @@
+package githubsvc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mockTransport answers the GitHub calls with the JSON fixtures of its directory instead of calling GitHub.
+// The fixture of a call is the file of its method and path, e.g. GET/repos/octocat/hello-world/pulls/1.json,
+// whatever its query. Reads without a fixture are answered with GitHub's 404, and writes without one with
+// their own body.
+type mockTransport struct {
+	dir string
+}
+
+// NewMockTransport returns a transport answering the GitHub calls with the JSON fixtures of the directory, or
+// replaying the cassette file recorded by ConfigureRecording, e.g. for the consumers to develop and run their
+// integration tests against the proxy without a token or network
+func NewMockTransport(path string) (http.RoundTripper, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return NewReplayTransport(path)
+	}
+	return &mockTransport{dir: path}, nil
+}
+
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	status := http.StatusOK
+	switch req.Method {
+	case "POST":
+		status = http.StatusCreated
+	case "DELETE":
+		status = http.StatusNoContent
+	}
+	fixture, err := os.ReadFile(t.fixture(req))
+	switch {
+	case err == nil:
+		if status == http.StatusNoContent && len(fixture) > 0 {
+			status = http.StatusOK
+		}
+		body = fixture
+	case !os.IsNotExist(err):
+		return nil, err
+	case req.Method == "GET" || req.Method == "HEAD":
+		status = http.StatusNotFound
+		body = []byte(`{"message":"Not Found","documentation_url":"https://docs.github.com/rest"}`)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("X-OAuth-Scopes", "repo, read:org")
+	header.Set("X-RateLimit-Limit", "5000")
+	header.Set("X-RateLimit-Remaining", "5000")
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// fixture returns the path of the call's fixture, the enterprise servers' /api/v3 and /api prefixes left out
+func (t *mockTransport) fixture(req *http.Request) string {
+	p := strings.TrimPrefix(req.URL.Path, "/api/v3")
+	if p == "/api/graphql" {
+		p = "/graphql"
+	}
+	// cleaning the rooted path keeps the fixtures within the directory
+	return filepath.Join(t.dir, req.Method, filepath.FromSlash(path.Clean("/"+p))) + ".json"
+}
@@ -0,0 +1,174 @@
+package githubsvc
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// PacingConfig configures the pacing of the mutating GitHub calls, which trip GitHub's secondary rate limits when
+// made concurrently or in quick succession. It's only read at startup.
+type PacingConfig struct {
+	// Interval is the minimum time between two writes to the same repository, defaulting to 1s
+	Interval time.Duration `yaml:"interval"`
+	// Cooldown is how long every write is held back after a secondary rate limit without a Retry-After,
+	// defaulting to 1m
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// ConfigurePacing serializes the mutating GitHub API calls per repository, spacing them by the interval, and
+// holds all of them back once GitHub answers one with a secondary rate limit, across the replicas with
+// ShareRateLimits. Calls are queued rather than failed, the depth of the queue being exported as the
+// github_api_write_queue_depth metric.
+func ConfigurePacing(config PacingConfig) {
+	if config.Interval == 0 {
+		config.Interval = time.Second
+	}
+	if config.Cooldown == 0 {
+		config.Cooldown = time.Minute
+	}
+	OutboundTransport = &pacingTransport{base: OutboundTransport, config: config, queues: map[string]*writeQueue{}}
+}
+
+// pacingTransport paces the writes to GitHub
+type pacingTransport struct {
+	base   http.RoundTripper
+	config PacingConfig
+
+	mu     sync.Mutex
+	queues map[string]*writeQueue
+	// pausedUntil holds back every write after a secondary rate limit
+	pausedUntil time.Time
+}
+
+// writeQueue serializes the writes to a repository
+type writeQueue struct {
+	turn    chan struct{}
+	waiting int
+	last    time.Time
+}
+
+func (t *pacingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the GraphQL calls are queries
+	if !middleware.IsWrite(req.Method) || !isGitHubAPI(req) || strings.HasSuffix(req.URL.Path, "/graphql") {
+		return t.base.RoundTrip(req)
+	}
+
+	q, err := t.acquire(req.Context(), writeQueueKey(req))
+	if err != nil {
+		return nil, err
+	}
+	defer t.release(q)
+
+	resp, err := t.base.RoundTrip(req)
+	limited := err == nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && isRateLimited(resp)
+
+	t.mu.Lock()
+	q.last = time.Now()
+	if !limited {
+		t.mu.Unlock()
+		return resp, err
+	}
+	secondaryRateLimits.Inc()
+	pause := t.config.Cooldown
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		pause = time.Duration(seconds) * time.Second
+	}
+	until := q.last.Add(pause)
+	if until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+	t.mu.Unlock()
+	if shared != nil {
+		// the other replicas' writes are held back too
+		if err := shared.PauseTurns(req.Context(), until); err != nil {
+			slog.ErrorContext(req.Context(), "pausing the writes failed", "error", err)
+		}
+	}
+	return resp, err
+}
+
+// acquire waits for the turn of the request in the repository's queue, and then for its pace
+func (t *pacingTransport) acquire(ctx context.Context, key string) (*writeQueue, error) {
+	t.mu.Lock()
+	q, ok := t.queues[key]
+	if !ok {
+		for k, idle := range t.queues {
+			if idle.waiting == 0 && time.Since(idle.last) >= t.config.Interval {
+				delete(t.queues, k)
+			}
+		}
+		q = &writeQueue{turn: make(chan struct{}, 1)}
+		t.queues[key] = q
+	}
+	q.waiting++
+	t.mu.Unlock()
+
+	writeQueueDepth.Inc()
+	select {
+	case q.turn <- struct{}{}:
+		writeQueueDepth.Dec()
+	case <-ctx.Done():
+		writeQueueDepth.Dec()
+		t.leave(q)
+		return nil, ctx.Err()
+	}
+
+	t.mu.Lock()
+	next := q.last.Add(t.config.Interval)
+	if t.pausedUntil.After(next) {
+		next = t.pausedUntil
+	}
+	t.mu.Unlock()
+	if shared != nil {
+		// the turn is also spaced from the other replicas' writes
+		wait, err := shared.ReserveTurn(ctx, key, t.config.Interval)
+		if err != nil {
+			slog.ErrorContext(ctx, "reserving the write's turn failed", "error", err)
+		} else if turn := time.Now().Add(wait); turn.After(next) {
+			next = turn
+		}
+	}
+	select {
+	case <-time.After(time.Until(next)):
+		return q, nil
+	case <-ctx.Done():
+		t.release(q)
+		return nil, ctx.Err()
+	}
+}
+
+// release passes the turn on to the next request of the queue
+func (t *pacingTransport) release(q *writeQueue) {
+	<-q.turn
+	t.leave(q)
+}
+
+// leave leaves the queue, which is dropped once idle by acquire
+func (t *pacingTransport) leave(q *writeQueue) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q.waiting--
+}
+
+// writeQueueKey returns the repository or organization the call writes to, e.g. "repos/octocat/hello-world"
+func writeQueueKey(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	if enterprise != nil {
+		path = strings.TrimPrefix(req.URL.Path, enterprise.baseURL.Path)
+	}
+	segments := strings.SplitN(path, "/", 4)
+	if len(segments) >= 3 && segments[0] == "repos" {
+		return strings.Join(segments[:3], "/")
+	}
+	if len(segments) >= 2 {
+		return strings.Join(segments[:2], "/")
+	}
+	return path
+}
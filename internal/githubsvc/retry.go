@@ -0,0 +1,150 @@
+package githubsvc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryConfig configures retrying the GitHub calls failing transiently. It's only read at startup, but for the
+// attempts of the route groups.
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts of a call, defaulting to 3; 1 disables retries
+	MaxAttempts int `yaml:"max_attempts"`
+	// Routes overrides MaxAttempts by route group, e.g. "batch: 1" for the automations retrying on their own
+	Routes map[string]int `yaml:"routes"`
+	// BaseDelay is the backoff before the first retry, doubled on each one up to MaxDelay. Retries which would
+	// wait longer than MaxDelay, e.g. for the rate limit to reset, are given up.
+	BaseDelay time.Duration `yaml:"base_delay"`
+	MaxDelay  time.Duration `yaml:"max_delay"`
+}
+
+// ConfigureRetries retries the GitHub API calls answered with a 502 or 503, or a secondary rate limit 403, with a
+// jittered exponential backoff, or waiting as long as GitHub asks with Retry-After or X-RateLimit-Reset
+func ConfigureRetries(config RetryConfig) {
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseDelay == 0 {
+		config.BaseDelay = 500 * time.Millisecond
+	}
+	if config.MaxDelay == 0 {
+		config.MaxDelay = 30 * time.Second
+	}
+	// the transport is kept without retries, for those of the route groups
+	OutboundTransport = &retryTransport{base: OutboundTransport, config: config}
+}
+
+const attemptsKey contextKey = "attempts"
+
+// RetryAttempts makes the GitHub calls of the requests attempted up to the number of times, e.g. of the route
+// groups with their own retry policy
+func RetryAttempts(attempts int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), attemptsKey, attempts)))
+		})
+	}
+}
+
+// retryTransport retries the transient failures of the GitHub API calls
+type retryTransport struct {
+	base   http.RoundTripper
+	config RetryConfig
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isGitHubAPI(req) || (req.Body != nil && req.GetBody == nil) {
+		// bodies which can't be read again can't be retried
+		return t.base.RoundTrip(req)
+	}
+
+	maxAttempts := t.config.MaxAttempts
+	if attempts, ok := req.Context().Value(attemptsKey).(int); ok {
+		maxAttempts = attempts
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || attempt >= maxAttempts {
+			return resp, err
+		}
+		delay, retry := t.retryDelay(req, resp, attempt)
+		if !retry {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		if req.GetBody != nil {
+			// RoundTrippers must not modify the original request
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying the call, and whether it should be
+func (t *retryTransport) retryDelay(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		// the write may have been made, so only the idempotent calls are retried
+		if req.Method == "POST" || req.Method == "PATCH" {
+			return 0, false
+		}
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		// rate limited calls weren't made, so they're retried whatever their method
+		if !isRateLimited(resp) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		delay = time.Until(time.Unix(reset, 0))
+	} else {
+		backoff := t.config.BaseDelay << uint(attempt-1)
+		if backoff > t.config.MaxDelay || backoff <= 0 {
+			backoff = t.config.MaxDelay
+		}
+		// full jitter spreads the retries of the replicas
+		delay = time.Duration(rand.Int63n(int64(backoff) + 1))
+	}
+	return delay, delay <= t.config.MaxDelay
+}
+
+// isRateLimited reports whether the 403 or 429 is GitHub's primary or secondary rate limit, rather than a
+// permission error. The response body is restored after being checked.
+func isRateLimited(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if err != nil {
+		return false
+	}
+	message := strings.ToLower(string(body))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse")
+}
@@ -0,0 +1,69 @@
+package githubsvc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// BaseURL returns the API URL of github.com or the configured enterprise server
+func BaseURL() *url.URL {
+	if enterprise != nil {
+		return enterprise.baseURL
+	}
+	u, _ := url.Parse("https://api.github.com/")
+	return u
+}
+
+// CheckConnectivity verifies the GitHub API host can be reached: that its name resolves, unless the outbound
+// proxy resolves it, and that an unauthenticated call to its /rate_limit completes the TLS handshake and gets
+// an answer. Each check is logged, and the first one failing is returned.
+func CheckConnectivity(ctx context.Context) error {
+	base := BaseURL()
+	req, err := http.NewRequestWithContext(ctx, "GET", base.ResolveReference(&url.URL{Path: "rate_limit"}).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	proxy, err := outboundProxy(req)
+	if err != nil {
+		return fmt.Errorf("Invalid outbound proxy: %v", err)
+	}
+	if proxy != nil {
+		slog.InfoContext(ctx, "self-check passed", "check", "dns", "host", base.Hostname(), "proxy", proxy.Redacted())
+	} else {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, base.Hostname())
+		if err != nil {
+			return fmt.Errorf("Can't resolve the GitHub API host %v: %v", base.Hostname(), err)
+		}
+		slog.InfoContext(ctx, "self-check passed", "check", "dns", "host", base.Hostname(), "addrs", addrs)
+	}
+
+	// the call is sent without the proxy's retries, caching and rate limit tracking, which a failure would confuse
+	resp, err := (&http.Client{Transport: outbound}).Do(req)
+	if err != nil {
+		return fmt.Errorf("Can't connect to the GitHub API at %v: %v", base, err)
+	}
+	resp.Body.Close()
+	attrs := []interface{}{"check", "connect", "url", base.String(), "status", resp.StatusCode}
+	if resp.TLS != nil {
+		attrs = append(attrs, "tls", tls.VersionName(resp.TLS.Version), "protocol", resp.Proto)
+		if len(resp.TLS.PeerCertificates) > 0 {
+			attrs = append(attrs, "cert_expiry", resp.TLS.PeerCertificates[0].NotAfter)
+		}
+	}
+	slog.InfoContext(ctx, "self-check passed", attrs...)
+	return nil
+}
+
+// outboundProxy returns the URL of the proxy the outbound transport sends the request through, or nil
+func outboundProxy(req *http.Request) (*url.URL, error) {
+	if outbound.Proxy == nil {
+		return nil, nil
+	}
+	return outbound.Proxy(req)
+}
@@ -0,0 +1,569 @@
+// Package githubsvc calls GitHub: the GitHubService used by the handlers, and the transports its clients send
+// their requests through, retrying, pacing and caching them.
+package githubsvc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHubService is the GitHub API used by the handlers, implemented by githubService on top of go-github.
+// Handlers only depend on this interface, so they can be tested and instrumented with other implementations.
+type GitHubService interface {
+	GetUser(ctx context.Context, login string) (*github.User, *github.Response, error)
+
+	ListRepos(ctx context.Context, owner string, opt *github.RepositoryListOptions) ([]*github.Repository, *github.Response, error)
+	SearchRepos(ctx context.Context, query string, opt *github.SearchOptions) (*github.RepositoriesSearchResult, *github.Response, error)
+	GetRepo(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	// SearchIssues searches the issues and the pull requests
+	SearchIssues(ctx context.Context, query string, opt *github.SearchOptions) (*github.IssuesSearchResult, *github.Response, error)
+	CreateCommitComment(ctx context.Context, owner, repo, sha string, comment *github.RepositoryComment) (*github.RepositoryComment, *github.Response, error)
+	CreatePullComment(ctx context.Context, owner, repo string, number int, comment *ReviewComment) (*ReviewComment, *github.Response, error)
+	// ReplyToPullComment replies to the review comment, in its thread
+	ReplyToPullComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (*ReviewComment, *github.Response, error)
+	// CreateIssueComment comments the issue or pull request
+	CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	// the comments are edited and deleted by their ID, the review comments keeping their line and side fields
+	EditCommitComment(ctx context.Context, owner, repo string, id int64, body string) (*github.RepositoryComment, *github.Response, error)
+	DeleteCommitComment(ctx context.Context, owner, repo string, id int64) (*github.Response, error)
+	EditIssueComment(ctx context.Context, owner, repo string, id int64, body string) (*github.IssueComment, *github.Response, error)
+	DeleteIssueComment(ctx context.Context, owner, repo string, id int64) (*github.Response, error)
+	EditPullComment(ctx context.Context, owner, repo string, id int64, body string) (*ReviewComment, *github.Response, error)
+	DeletePullComment(ctx context.Context, owner, repo string, id int64) (*github.Response, error)
+	// the comments are the repository's when sha or number is empty, otherwise the commit's, issue's or pull request's
+	ListCommitComments(ctx context.Context, owner, repo, sha string, opt *github.ListOptions) ([]*github.RepositoryComment, *github.Response, error)
+	ListIssueComments(ctx context.Context, owner, repo string, number int, opt *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error)
+	ListPullComments(ctx context.Context, owner, repo string, number int, opt *github.PullRequestListCommentsOptions) ([]*ReviewComment, *github.Response, error)
+	GetPull(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error)
+	ListPullFiles(ctx context.Context, owner, repo string, number int, opt *github.ListOptions) ([]*github.CommitFile, *github.Response, error)
+	// AddLabels adds the labels to the issue or pull request
+	AddLabels(ctx context.Context, owner, repo string, number int, labels []string) ([]*github.Label, *github.Response, error)
+
+	// the interaction limits are the org's when repo is empty
+	GetInteractions(ctx context.Context, owner, repo string) (*InteractionRestriction, *github.Response, error)
+	SetInteractions(ctx context.Context, owner, repo string, restriction *InteractionRestriction) (*InteractionRestriction, *github.Response, error)
+	RemoveInteractions(ctx context.Context, owner, repo string) (*github.Response, error)
+
+	StartMigration(ctx context.Context, org string, repos []string, opt *github.MigrationOptions) (*github.Migration, *github.Response, error)
+	ListMigrations(ctx context.Context, org string, opt *github.ListOptions) ([]*github.Migration, *github.Response, error)
+	MigrationStatus(ctx context.Context, org string, id int64) (*github.Migration, *github.Response, error)
+	// MigrationArchive returns the archive's contents, and their size (-1 when unknown)
+	MigrationArchive(ctx context.Context, org string, id int64) (io.ReadCloser, int64, error)
+
+	StartImport(ctx context.Context, owner, repo string, in *github.Import) (*github.Import, *github.Response, error)
+	ImportProgress(ctx context.Context, owner, repo string) (*github.Import, *github.Response, error)
+	UpdateImport(ctx context.Context, owner, repo string, in *github.Import) (*github.Import, *github.Response, error)
+	CancelImport(ctx context.Context, owner, repo string) (*github.Response, error)
+	CommitAuthors(ctx context.Context, owner, repo string) ([]*github.SourceImportAuthor, *github.Response, error)
+	MapCommitAuthor(ctx context.Context, owner, repo string, id int64, author *github.SourceImportAuthor) (*github.SourceImportAuthor, *github.Response, error)
+
+	// the hooks are the org's when repo is empty
+	ListHooks(ctx context.Context, owner, repo string, opt *github.ListOptions) ([]*github.Hook, *github.Response, error)
+	ListHookDeliveries(ctx context.Context, owner, repo string, hookID int64, opt *github.ListOptions) ([]*HookDelivery, *github.Response, error)
+	RedeliverHookDelivery(ctx context.Context, owner, repo string, hookID, deliveryID int64) (*github.Response, error)
+
+	AuditLog(ctx context.Context, org string, query url.Values) ([]json.RawMessage, *github.Response, error)
+	// ListOrgEvents lists the org's public events, conditionally on the etag when set, answering 304 Not Modified
+	// with a nil error when unchanged
+	ListOrgEvents(ctx context.Context, org, etag string, opt *github.ListOptions) ([]*github.Event, *github.Response, error)
+
+	ListSCIMUsers(ctx context.Context, org string, query url.Values) (*SCIMUserList, *github.Response, error)
+	GetSCIMUser(ctx context.Context, org, id string) (*SCIMUser, *github.Response, error)
+	ProvisionSCIMUser(ctx context.Context, org string, user *SCIMUser) (*SCIMUser, *github.Response, error)
+	DeprovisionSCIMUser(ctx context.Context, org, id string) (*github.Response, error)
+
+	// GraphQL runs the query with its variables, decoding the data of the response into out
+	GraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) (*github.Response, error)
+	// ForwardGraphQL runs the request, returning GitHub's response whole, its errors included
+	ForwardGraphQL(ctx context.Context, request *GraphQLRequest) (*GraphQLResponse, *github.Response, error)
+}
+
+// githubService implements GitHubService with a go-github client
+type githubService struct {
+	client *github.Client
+}
+
+// NewGitHubService returns the GitHubService backed by the client
+func NewGitHubService(client *github.Client) GitHubService {
+	return &githubService{client: client}
+}
+
+func (s *githubService) GetUser(ctx context.Context, login string) (*github.User, *github.Response, error) {
+	return s.client.Users.Get(ctx, login)
+}
+
+func (s *githubService) ListRepos(ctx context.Context, owner string, opt *github.RepositoryListOptions) ([]*github.Repository, *github.Response, error) {
+	return s.client.Repositories.List(ctx, owner, opt)
+}
+
+func (s *githubService) SearchRepos(ctx context.Context, query string, opt *github.SearchOptions) (*github.RepositoriesSearchResult, *github.Response, error) {
+	return s.client.Search.Repositories(ctx, query, opt)
+}
+
+func (s *githubService) GetRepo(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	return s.client.Repositories.Get(ctx, owner, repo)
+}
+
+func (s *githubService) SearchIssues(ctx context.Context, query string, opt *github.SearchOptions) (*github.IssuesSearchResult, *github.Response, error) {
+	return s.client.Search.Issues(ctx, query, opt)
+}
+
+func (s *githubService) CreateCommitComment(ctx context.Context, owner, repo, sha string, comment *github.RepositoryComment) (*github.RepositoryComment, *github.Response, error) {
+	return s.client.Repositories.CreateComment(ctx, owner, repo, sha, comment)
+}
+
+// CreatePullComment is go-github's, with the comment's line and side fields
+func (s *githubService) CreatePullComment(ctx context.Context, owner, repo string, number int, comment *ReviewComment) (*ReviewComment, *github.Response, error) {
+	req, err := s.client.NewRequest("POST", fmt.Sprintf("repos/%v/%v/pulls/%d/comments", owner, repo, number), comment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created := new(ReviewComment)
+	resp, err := s.client.Do(ctx, req, created)
+	if err != nil {
+		return nil, resp, err
+	}
+	return created, resp, nil
+}
+
+func (s *githubService) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	return s.client.Issues.CreateComment(ctx, owner, repo, number, comment)
+}
+
+func (s *githubService) GetPull(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	return s.client.PullRequests.Get(ctx, owner, repo, number)
+}
+
+func (s *githubService) ListPullFiles(ctx context.Context, owner, repo string, number int, opt *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return s.client.PullRequests.ListFiles(ctx, owner, repo, number, opt)
+}
+
+func (s *githubService) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) ([]*github.Label, *github.Response, error) {
+	return s.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels)
+}
+
+func (s *githubService) ReplyToPullComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (*ReviewComment, *github.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/comments/%d/replies", owner, repo, number, commentID)
+	req, err := s.client.NewRequest("POST", u, map[string]string{"body": body})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reply := new(ReviewComment)
+	resp, err := s.client.Do(ctx, req, reply)
+	if err != nil {
+		return nil, resp, err
+	}
+	return reply, resp, nil
+}
+
+func (s *githubService) EditCommitComment(ctx context.Context, owner, repo string, id int64, body string) (*github.RepositoryComment, *github.Response, error) {
+	return s.client.Repositories.UpdateComment(ctx, owner, repo, id, &github.RepositoryComment{Body: github.String(body)})
+}
+
+func (s *githubService) DeleteCommitComment(ctx context.Context, owner, repo string, id int64) (*github.Response, error) {
+	return s.client.Repositories.DeleteComment(ctx, owner, repo, id)
+}
+
+func (s *githubService) EditIssueComment(ctx context.Context, owner, repo string, id int64, body string) (*github.IssueComment, *github.Response, error) {
+	return s.client.Issues.EditComment(ctx, owner, repo, id, &github.IssueComment{Body: github.String(body)})
+}
+
+func (s *githubService) DeleteIssueComment(ctx context.Context, owner, repo string, id int64) (*github.Response, error) {
+	return s.client.Issues.DeleteComment(ctx, owner, repo, id)
+}
+
+func (s *githubService) EditPullComment(ctx context.Context, owner, repo string, id int64, body string) (*ReviewComment, *github.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/comments/%d", owner, repo, id)
+	req, err := s.client.NewRequest("PATCH", u, map[string]string{"body": body})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	edited := new(ReviewComment)
+	resp, err := s.client.Do(ctx, req, edited)
+	if err != nil {
+		return nil, resp, err
+	}
+	return edited, resp, nil
+}
+
+func (s *githubService) DeletePullComment(ctx context.Context, owner, repo string, id int64) (*github.Response, error) {
+	return s.client.PullRequests.DeleteComment(ctx, owner, repo, id)
+}
+
+func (s *githubService) ListCommitComments(ctx context.Context, owner, repo, sha string, opt *github.ListOptions) ([]*github.RepositoryComment, *github.Response, error) {
+	if sha == "" {
+		return s.client.Repositories.ListComments(ctx, owner, repo, opt)
+	}
+	return s.client.Repositories.ListCommitComments(ctx, owner, repo, sha, opt)
+}
+
+func (s *githubService) ListIssueComments(ctx context.Context, owner, repo string, number int, opt *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error) {
+	return s.client.Issues.ListComments(ctx, owner, repo, number, opt)
+}
+
+// ListPullComments is go-github's, with the comments' line and side fields
+func (s *githubService) ListPullComments(ctx context.Context, owner, repo string, number int, opt *github.PullRequestListCommentsOptions) ([]*ReviewComment, *github.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/comments", owner, repo)
+	if number != 0 {
+		u = fmt.Sprintf("repos/%v/%v/pulls/%d/comments", owner, repo, number)
+	}
+	if opt == nil {
+		opt = &github.PullRequestListCommentsOptions{}
+	}
+	query := listQuery(&opt.ListOptions)
+	for param, value := range map[string]string{"sort": opt.Sort, "direction": opt.Direction} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	if !opt.Since.IsZero() {
+		query.Set("since", opt.Since.Format(time.RFC3339))
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var comments []*ReviewComment
+	resp, err := s.client.Do(ctx, req, &comments)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comments, resp, nil
+}
+
+// interactionLimitsURL returns the GitHub API path of the org's interaction limits when repo is empty,
+// otherwise the repository's
+func interactionLimitsURL(owner, repo string) string {
+	if repo == "" {
+		return fmt.Sprintf("orgs/%v/interaction-limits", owner)
+	}
+	return fmt.Sprintf("repos/%v/%v/interaction-limits", owner, repo)
+}
+
+func (s *githubService) GetInteractions(ctx context.Context, owner, repo string) (*InteractionRestriction, *github.Response, error) {
+	req, err := s.client.NewRequest("GET", interactionLimitsURL(owner, repo), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", mediaTypeInteractionsPreview)
+
+	restriction := new(InteractionRestriction)
+	resp, err := s.client.Do(ctx, req, restriction)
+	if err != nil {
+		return nil, resp, err
+	}
+	return restriction, resp, nil
+}
+
+func (s *githubService) SetInteractions(ctx context.Context, owner, repo string, restriction *InteractionRestriction) (*InteractionRestriction, *github.Response, error) {
+	req, err := s.client.NewRequest("PUT", interactionLimitsURL(owner, repo), restriction)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", mediaTypeInteractionsPreview)
+
+	updated := new(InteractionRestriction)
+	resp, err := s.client.Do(ctx, req, updated)
+	if err != nil {
+		return nil, resp, err
+	}
+	return updated, resp, nil
+}
+
+func (s *githubService) RemoveInteractions(ctx context.Context, owner, repo string) (*github.Response, error) {
+	req, err := s.client.NewRequest("DELETE", interactionLimitsURL(owner, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mediaTypeInteractionsPreview)
+
+	return s.client.Do(ctx, req, nil)
+}
+
+func (s *githubService) StartMigration(ctx context.Context, org string, repos []string, opt *github.MigrationOptions) (*github.Migration, *github.Response, error) {
+	return s.client.Migrations.StartMigration(ctx, org, repos, opt)
+}
+
+// ListMigrations is go-github's, which doesn't paginate
+func (s *githubService) ListMigrations(ctx context.Context, org string, opt *github.ListOptions) ([]*github.Migration, *github.Response, error) {
+	u := fmt.Sprintf("orgs/%v/migrations", org)
+	if query := listQuery(opt); len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", mediaTypeMigrationsPreview)
+
+	var migrations []*github.Migration
+	resp, err := s.client.Do(ctx, req, &migrations)
+	if err != nil {
+		return nil, resp, err
+	}
+	return migrations, resp, nil
+}
+
+func (s *githubService) ListHooks(ctx context.Context, owner, repo string, opt *github.ListOptions) ([]*github.Hook, *github.Response, error) {
+	if repo == "" {
+		return s.client.Organizations.ListHooks(ctx, owner, opt)
+	}
+	return s.client.Repositories.ListHooks(ctx, owner, repo, opt)
+}
+
+// hooksURL returns the GitHub API path of the org's hook when repo is empty, otherwise the repository's
+func hooksURL(owner, repo string, hookID int64) string {
+	if repo == "" {
+		return fmt.Sprintf("orgs/%v/hooks/%v", owner, hookID)
+	}
+	return fmt.Sprintf("repos/%v/%v/hooks/%v", owner, repo, hookID)
+}
+
+func (s *githubService) ListHookDeliveries(ctx context.Context, owner, repo string, hookID int64, opt *github.ListOptions) ([]*HookDelivery, *github.Response, error) {
+	u := hooksURL(owner, repo, hookID) + "/deliveries"
+	if query := listQuery(opt); len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var deliveries []*HookDelivery
+	resp, err := s.client.Do(ctx, req, &deliveries)
+	if err != nil {
+		return nil, resp, err
+	}
+	return deliveries, resp, nil
+}
+
+func (s *githubService) RedeliverHookDelivery(ctx context.Context, owner, repo string, hookID, deliveryID int64) (*github.Response, error) {
+	req, err := s.client.NewRequest("POST", fmt.Sprintf("%v/deliveries/%v/attempts", hooksURL(owner, repo, hookID), deliveryID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(ctx, req, nil)
+	if _, ok := err.(*github.AcceptedError); ok {
+		// GitHub answers 202 Accepted, redelivering in the background
+		return resp, nil
+	}
+	return resp, err
+}
+
+func (s *githubService) ListOrgEvents(ctx context.Context, org, etag string, opt *github.ListOptions) ([]*github.Event, *github.Response, error) {
+	u := fmt.Sprintf("orgs/%v/events", org)
+	if query := listQuery(opt); len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	var events []*github.Event
+	resp, err := s.client.Do(ctx, req, &events)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return nil, resp, nil
+	}
+	if err != nil {
+		return nil, resp, err
+	}
+	return events, resp, nil
+}
+
+// listQuery returns the query parameters of the list options
+func listQuery(opt *github.ListOptions) url.Values {
+	query := url.Values{}
+	if opt == nil {
+		return query
+	}
+	if opt.Page > 0 {
+		query.Set("page", strconv.Itoa(opt.Page))
+	}
+	if opt.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(opt.PerPage))
+	}
+	return query
+}
+
+func (s *githubService) MigrationStatus(ctx context.Context, org string, id int64) (*github.Migration, *github.Response, error) {
+	return s.client.Migrations.MigrationStatus(ctx, org, id)
+}
+
+func (s *githubService) MigrationArchive(ctx context.Context, org string, id int64) (io.ReadCloser, int64, error) {
+	u, err := s.client.Migrations.MigrationArchiveURL(ctx, org, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the archive url is pre-signed, so it's fetched without the GitHub credentials
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := (&http.Client{Transport: OutboundTransport}).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		// mapped like GitHub's errors, the 5xx ones to 502
+		return nil, 0, &github.ErrorResponse{Response: resp, Message: "Archive download failed: " + resp.Status}
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (s *githubService) StartImport(ctx context.Context, owner, repo string, in *github.Import) (*github.Import, *github.Response, error) {
+	return s.client.Migrations.StartImport(ctx, owner, repo, in)
+}
+
+func (s *githubService) ImportProgress(ctx context.Context, owner, repo string) (*github.Import, *github.Response, error) {
+	return s.client.Migrations.ImportProgress(ctx, owner, repo)
+}
+
+func (s *githubService) UpdateImport(ctx context.Context, owner, repo string, in *github.Import) (*github.Import, *github.Response, error) {
+	return s.client.Migrations.UpdateImport(ctx, owner, repo, in)
+}
+
+func (s *githubService) CancelImport(ctx context.Context, owner, repo string) (*github.Response, error) {
+	return s.client.Migrations.CancelImport(ctx, owner, repo)
+}
+
+func (s *githubService) CommitAuthors(ctx context.Context, owner, repo string) ([]*github.SourceImportAuthor, *github.Response, error) {
+	return s.client.Migrations.CommitAuthors(ctx, owner, repo)
+}
+
+func (s *githubService) MapCommitAuthor(ctx context.Context, owner, repo string, id int64, author *github.SourceImportAuthor) (*github.SourceImportAuthor, *github.Response, error) {
+	return s.client.Migrations.MapCommitAuthor(ctx, owner, repo, id, author)
+}
+
+func (s *githubService) AuditLog(ctx context.Context, org string, query url.Values) ([]json.RawMessage, *github.Response, error) {
+	u := fmt.Sprintf("orgs/%v/audit-log", org)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var events []json.RawMessage
+	resp, err := s.client.Do(ctx, req, &events)
+	if err != nil {
+		return nil, resp, err
+	}
+	return events, resp, nil
+}
+
+func scimUsersURL(org string) string {
+	return fmt.Sprintf("scim/v2/organizations/%v/Users", org)
+}
+
+func (s *githubService) ListSCIMUsers(ctx context.Context, org string, query url.Values) (*SCIMUserList, *github.Response, error) {
+	u := scimUsersURL(org)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list := new(SCIMUserList)
+	resp, err := s.client.Do(ctx, req, list)
+	if err != nil {
+		return nil, resp, err
+	}
+	return list, resp, nil
+}
+
+func (s *githubService) GetSCIMUser(ctx context.Context, org, id string) (*SCIMUser, *github.Response, error) {
+	req, err := s.client.NewRequest("GET", scimUsersURL(org)+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user := new(SCIMUser)
+	resp, err := s.client.Do(ctx, req, user)
+	if err != nil {
+		return nil, resp, err
+	}
+	return user, resp, nil
+}
+
+func (s *githubService) ProvisionSCIMUser(ctx context.Context, org string, user *SCIMUser) (*SCIMUser, *github.Response, error) {
+	req, err := s.client.NewRequest("POST", scimUsersURL(org), user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created := new(SCIMUser)
+	resp, err := s.client.Do(ctx, req, created)
+	if err != nil {
+		return nil, resp, err
+	}
+	return created, resp, nil
+}
+
+func (s *githubService) DeprovisionSCIMUser(ctx context.Context, org, id string) (*github.Response, error) {
+	req, err := s.client.NewRequest("DELETE", scimUsersURL(org)+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Do(ctx, req, nil)
+}
+
+func (s *githubService) GraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) (*github.Response, error) {
+	req, err := s.client.NewRequest("POST", graphqlPath, map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+
+	var body GraphQLResponse
+	resp, err := s.client.Do(ctx, req, &body)
+	if err != nil {
+		return resp, err
+	}
+	if len(body.Errors) > 0 {
+		return resp, body.Errors
+	}
+	return resp, json.Unmarshal(body.Data, out)
+}
+
+func (s *githubService) ForwardGraphQL(ctx context.Context, request *GraphQLRequest) (*GraphQLResponse, *github.Response, error) {
+	req, err := s.client.NewRequest("POST", graphqlPath, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body := &GraphQLResponse{}
+	resp, err := s.client.Do(ctx, req, body)
+	if err != nil {
+		return nil, resp, err
+	}
+	return body, resp, nil
+}
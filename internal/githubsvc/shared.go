@@ -0,0 +1,14 @@
+package githubsvc
+
+import "github.com/feckmore/github-api/internal/middleware"
+
+// shared coordinates the replicas sharing the tokens, when configured
+var shared *middleware.RedisStore
+
+// ShareRateLimits coordinates the replicas of the proxy sharing the tokens through Redis, for them not to trip
+// GitHub's rate limits together: the budget scheduler sees the fewest calls left of each token any replica was
+// told about, and the pacing spaces the writes to each repository and holds them back after a secondary rate limit
+// across the replicas. The replicas fall back to their own view while Redis is unavailable.
+func ShareRateLimits(store *middleware.RedisStore) {
+	shared = store
+}
@@ -0,0 +1,119 @@
+package githubsvc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OutboundTransport carries every outbound request, to GitHub and to the identity provider. It honors the
+// HTTPS_PROXY and NO_PROXY environment variables unless ConfigureOutbound sets an explicit proxy.
+var OutboundTransport http.RoundTripper = http.DefaultTransport
+
+// outbound is the transport configured by ConfigureOutbound, before the proxy's retries, caching and rate limit
+// tracking wrap it
+var outbound = http.DefaultTransport.(*http.Transport)
+
+// OutboundConfig configures the outbound transport's proxy and connections, which Go's defaults keep too few of
+// for the proxy's volume of calls to GitHub. It's only read at startup.
+type OutboundConfig struct {
+	// Proxy is the URL of the proxy the outbound requests are sent through
+	Proxy string `yaml:"proxy"`
+	// CAFile is a PEM encoded CA file whose certificates are trusted on top of the system's, e.g. for a
+	// corporate TLS intercepting proxy
+	CAFile string `yaml:"ca_file"`
+
+	// MaxIdleConns is the number of idle connections kept across the hosts, defaulting to 100
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// MaxIdleConnsPerHost is the number of idle connections kept to each host, defaulting to 100 rather than
+	// Go's 2, which makes the concurrent calls to GitHub dial new connections
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+	// MaxConnsPerHost limits the connections to each host, unlimited by default
+	MaxConnsPerHost int `yaml:"max_conns_per_host"`
+	// IdleConnTimeout is how long the idle connections are kept, defaulting to 90s
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"`
+	// TLSSessionCache is the number of TLS sessions kept to resume them when reconnecting, skipping the full
+	// handshake, defaulting to 256; -1 disables resumption
+	TLSSessionCache int `yaml:"tls_session_cache"`
+	// ForceHTTP2 only speaks HTTP/2, multiplexing the calls to each host on a connection, rather than
+	// negotiating it; hosts without HTTP/2 can't be called
+	ForceHTTP2 bool `yaml:"force_http2"`
+	// HTTP2PingInterval is how long an HTTP/2 connection stays silent before being checked with a ping, and
+	// closed when unanswered, defaulting to 30s
+	HTTP2PingInterval time.Duration `yaml:"http2_ping_interval"`
+}
+
+// ConfigureOutbound configures the transport of the outbound requests: their proxy, the CA certificates they
+// trust and the reuse of their connections
+func ConfigureOutbound(config OutboundConfig) error {
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = 100
+	}
+	if config.MaxIdleConnsPerHost == 0 {
+		config.MaxIdleConnsPerHost = 100
+	}
+	if config.IdleConnTimeout == 0 {
+		config.IdleConnTimeout = 90 * time.Second
+	}
+	if config.TLSSessionCache == 0 {
+		config.TLSSessionCache = 256
+	}
+	if config.HTTP2PingInterval == 0 {
+		config.HTTP2PingInterval = 30 * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = config.MaxConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport.HTTP2 = &http.HTTP2Config{SendPingTimeout: config.HTTP2PingInterval}
+	if config.ForceHTTP2 {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+	}
+
+	if config.Proxy != "" {
+		u, err := url.Parse(config.Proxy)
+		if err != nil {
+			return err
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{}
+	if config.TLSSessionCache > 0 {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(config.TLSSessionCache)
+	}
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("No certificates found in " + config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	outbound = transport
+	OutboundTransport = transport
+	return nil
+}
+
+// OutboundContext returns a context making the oauth2 clients use the outbound transport
+func OutboundContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: OutboundTransport})
+}
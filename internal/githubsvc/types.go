@@ -0,0 +1,113 @@
+package githubsvc
+
+import (
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// ReviewComment is a comment of a pull request's diff, which go-github's PullRequestComment lacks the line and
+// side fields of, placed on the line of the side of the diff, or on the lines from start_line when multi-line,
+// rather than at the legacy position
+type ReviewComment struct {
+	ID        *int64  `json:"id,omitempty"`
+	InReplyTo *int64  `json:"in_reply_to_id,omitempty"`
+	Body      *string `json:"body,omitempty"`
+	CommitID  *string `json:"commit_id,omitempty"`
+	Path      *string `json:"path,omitempty"`
+	Position  *int    `json:"position,omitempty"`
+	// Side and StartSide are LEFT, the deletions, or RIGHT, the additions and unchanged lines
+	Line      *int         `json:"line,omitempty"`
+	Side      *string      `json:"side,omitempty"`
+	StartLine *int         `json:"start_line,omitempty"`
+	StartSide *string      `json:"start_side,omitempty"`
+	DiffHunk  *string      `json:"diff_hunk,omitempty"`
+	User      *github.User `json:"user,omitempty"`
+	HTMLURL   *string      `json:"html_url,omitempty"`
+	CreatedAt *time.Time   `json:"created_at,omitempty"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+}
+
+// GetID returns the comment's ID, or 0
+func (c *ReviewComment) GetID() int64 {
+	if c == nil || c.ID == nil {
+		return 0
+	}
+	return *c.ID
+}
+
+// mediaTypeInteractionsPreview is required while the interactions API is in preview
+const mediaTypeInteractionsPreview = "application/vnd.github.sombra-preview+json"
+
+// InteractionRestriction represents the interaction limits in place for a repository or organization
+type InteractionRestriction struct {
+	// Limit is one of "existing_users", "contributors_only" or "collaborators_only"
+	Limit *string `json:"limit,omitempty"`
+	// Origin is "repository" or "organization", depending on where the limit was set
+	Origin *string `json:"origin,omitempty"`
+	// Expiry is one of "one_day", "three_days", "one_week", "one_month" or "six_months" (only used when setting)
+	Expiry    *string           `json:"expiry,omitempty"`
+	ExpiresAt *github.Timestamp `json:"expires_at,omitempty"`
+}
+
+// GetLimit returns the Limit field if it's non-nil, zero value otherwise
+func (i *InteractionRestriction) GetLimit() string {
+	if i == nil || i.Limit == nil {
+		return ""
+	}
+	return *i.Limit
+}
+
+// mediaTypeMigrationsPreview is required while the migrations API is in preview
+const mediaTypeMigrationsPreview = "application/vnd.github.wyandotte-preview+json"
+
+// SCIMUser represents a user provisioned through the org's SCIM identity provider integration
+type SCIMUser struct {
+	ID         string          `json:"id,omitempty"`
+	ExternalID string          `json:"externalId,omitempty"`
+	UserName   string          `json:"userName"`
+	Name       SCIMUserName    `json:"name"`
+	Emails     []*SCIMUserMail `json:"emails"`
+	Active     *bool           `json:"active,omitempty"`
+	Schemas    []string        `json:"schemas,omitempty"`
+}
+
+// SCIMUserName is the name of a SCIM user
+type SCIMUserName struct {
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+// SCIMUserMail is one of the email addresses of a SCIM user
+type SCIMUserMail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// SCIMUserList is a page of SCIM provisioned users
+type SCIMUserList struct {
+	TotalResults int         `json:"totalResults"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	StartIndex   int         `json:"startIndex"`
+	Resources    []*SCIMUser `json:"Resources"`
+	Schemas      []string    `json:"schemas,omitempty"`
+}
+
+// HookDelivery is a delivery of a hook's event, failed when its status code isn't a 2xx, e.g. 0 after a timeout
+type HookDelivery struct {
+	ID          int64     `json:"id"`
+	GUID        string    `json:"guid"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Redelivery  bool      `json:"redelivery"`
+	Duration    float64   `json:"duration"`
+	Status      string    `json:"status"`
+	StatusCode  int       `json:"status_code"`
+	Event       string    `json:"event"`
+	Action      string    `json:"action,omitempty"`
+}
+
+// Succeeded reports whether the delivery was answered with a 2xx status
+func (d *HookDelivery) Succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
@@ -0,0 +1,257 @@
+package githubsvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// Cassette is the outbound calls recorded by ConfigureRecording, with GitHub's responses, replayed by
+// NewReplayTransport
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded call and its response, sanitized of the credentials they carried: the request
+// headers aren't kept, and the tokens and secrets of the URLs and bodies are replaced with REDACTED
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the recorded call, matched by its method, URL and body when replayed
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	RecordedBody
+}
+
+// RecordedResponse is the recorded response to the call, without its cookies
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	RecordedBody
+}
+
+// RecordedBody is a recorded body, kept as JSON when it is, or as text
+type RecordedBody struct {
+	Body json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+var (
+	// tokenPattern matches the GitHub tokens: personal access, OAuth, user-to-server, server-to-server and
+	// refresh ones
+	tokenPattern = regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{16,}|github_pat_[A-Za-z0-9_]{16,})\b`)
+	// secretFieldPattern matches the JSON fields holding secrets, e.g. the installation tokens' "token"
+	secretFieldPattern = regexp.MustCompile(`("(?:token|access_token|refresh_token|client_secret|private_key|password|secret)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// secretParamPattern matches the URL and form parameters holding secrets, e.g. of the OAuth code exchange
+	secretParamPattern = regexp.MustCompile(`((?:^|[?&])(?:access_token|refresh_token|client_secret|code)=)[^&]*`)
+)
+
+// sanitize replaces the tokens and secrets of the URL or body with REDACTED
+func sanitize(b []byte) []byte {
+	b = tokenPattern.ReplaceAll(b, []byte("REDACTED"))
+	b = secretFieldPattern.ReplaceAll(b, []byte(`$1"REDACTED"`))
+	return secretParamPattern.ReplaceAll(b, []byte("${1}REDACTED"))
+}
+
+// newRecordedBody returns the sanitized body
+func newRecordedBody(body []byte) RecordedBody {
+	body = bytes.TrimSpace(sanitize(body))
+	var compact bytes.Buffer
+	if len(body) > 0 && json.Compact(&compact, body) == nil {
+		return RecordedBody{Body: compact.Bytes()}
+	}
+	return RecordedBody{Text: string(body)}
+}
+
+// bytes returns the recorded body, its JSON compacted
+func (b RecordedBody) bytes() []byte {
+	if len(b.Body) == 0 {
+		return []byte(b.Text)
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, b.Body) != nil {
+		return b.Body
+	}
+	return compact.Bytes()
+}
+
+// readBody reads the request's body, leaving it to be sent
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// ConfigureRecording records the outbound calls and their responses in the cassette file, sanitized, e.g. to
+// replay them in the handlers' tests with NewReplayTransport. The file is replaced, then saved after each call.
+func ConfigureRecording(path string) error {
+	t := &recordingTransport{base: OutboundTransport, path: path}
+	// an unwritable path fails at startup rather than on the first call
+	if err := t.save(); err != nil {
+		return err
+	}
+	OutboundTransport = t
+	return nil
+}
+
+// recordingTransport records the calls it sends and their responses in its cassette
+type recordingTransport struct {
+	base     http.RoundTripper
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := http.Header{}
+	for key, values := range resp.Header {
+		if key != "Set-Cookie" {
+			header[key] = values
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, &Interaction{
+		Request: RecordedRequest{
+			Method:       req.Method,
+			URL:          string(sanitize([]byte(req.URL.String()))),
+			RecordedBody: newRecordedBody(body),
+		},
+		Response: RecordedResponse{Status: resp.StatusCode, Header: header, RecordedBody: newRecordedBody(respBody)},
+	})
+	if err := t.save(); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// save replaces the cassette file with the interactions recorded, through a temporary file for a reader to
+// never see it partly written
+func (t *recordingTransport) save() error {
+	if t.cassette.Interactions == nil {
+		t.cassette.Interactions = []*Interaction{}
+	}
+	b, err := json.MarshalIndent(&t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), filepath.Base(t.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.path)
+}
+
+// NewReplayTransport returns a transport answering the calls with the responses of the cassette recorded by
+// ConfigureRecording, without calling GitHub, e.g. for deterministic handler tests. The calls are matched by
+// their method, URL and body, the identical ones answered in the recorded order, the last response repeated once
+// they are all replayed. The calls recorded by none fail.
+func NewReplayTransport(path string) (http.RoundTripper, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(b, &cassette); err != nil {
+		return nil, fmt.Errorf("Invalid cassette %s: %w", path, err)
+	}
+	return &replayTransport{interactions: cassette.Interactions, replayed: make([]bool, len(cassette.Interactions))}, nil
+}
+
+// replayTransport answers the calls with the recorded responses, keeping track of those replayed
+type replayTransport struct {
+	mu           sync.Mutex
+	interactions []*Interaction
+	replayed     []bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	// the calls are sanitized as they were when recorded
+	url := string(sanitize([]byte(req.URL.String())))
+	recorded := newRecordedBody(body).bytes()
+
+	t.mu.Lock()
+	match := -1
+	for i, interaction := range t.interactions {
+		if interaction.Request.Method != req.Method || interaction.Request.URL != url ||
+			!bytes.Equal(interaction.Request.bytes(), recorded) {
+			continue
+		}
+		// the first one not replayed yet, or the last one
+		match = i
+		if !t.replayed[i] {
+			break
+		}
+	}
+	if match < 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("No interaction recorded for %s %s", req.Method, url)
+	}
+	t.replayed[match] = true
+	t.mu.Unlock()
+
+	resp := t.interactions[match].Response
+	respBody := resp.bytes()
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// the compacted JSON is shorter than the recorded response
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        strconv.Itoa(resp.Status) + " " + http.StatusText(resp.Status),
+		StatusCode:    resp.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
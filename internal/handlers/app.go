@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+// appAuth authenticates as a GitHub App, keeping one client per owner backed by that owner's installation token
+type appAuth struct {
+	id  int64
+	key *rsa.PrivateKey
+
+	// client authenticates as the app itself, which is only allowed to manage installations
+	client *github.Client
+
+	mu      sync.Mutex
+	clients map[string]*github.Client
+	// group shares the installation lookup of an owner between its concurrent callers
+	group singleflight.Group
+}
+
+// NewApp function, initiates and returns a Github datastore instance authenticated as a GitHub App.
+// Installation tokens are minted per owner on first use, and refreshed once they expire.
+func NewApp(appID int64, privateKeyPEM []byte) (*Datastore, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("Private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(githubsvc.OutboundContext(context.Background()))
+	app := &appAuth{
+		id:      appID,
+		key:     key,
+		clients: map[string]*github.Client{},
+	}
+	app.client = githubsvc.NewClient(&http.Client{Transport: &appTransport{app: app}})
+
+	return &Datastore{
+		Context: ctx,
+		cancel:  cancel,
+		Client:  app.client,
+		Service: app.client.Git,
+		app:     app,
+	}, nil
+}
+
+// clientFor returns the client authenticated as the app's installation on the owner's account. The lock is only
+// held to look the clients up, the installation lookup and the first token being shared by the concurrent
+// callers of the same owner.
+func (app *appAuth) clientFor(ctx context.Context, owner string) (*github.Client, error) {
+	app.mu.Lock()
+	client, ok := app.clients[owner]
+	app.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	// the callers sharing the lookup mustn't be failed by the first one's cancellation
+	ctx = context.WithoutCancel(ctx)
+	v, err, _ := app.group.Do(owner, func() (interface{}, error) {
+		app.mu.Lock()
+		client, ok := app.clients[owner]
+		app.mu.Unlock()
+		if ok {
+			return client, nil
+		}
+
+		installation, resp, err := app.client.Apps.FindOrganizationInstallation(ctx, owner)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			installation, _, err = app.client.Apps.FindUserInstallation(ctx, owner)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ts := oauth2.ReuseTokenSource(nil, &installationTokenSource{
+			ctx:    ctx,
+			id:     installation.GetID(),
+			client: app.client,
+		})
+		if _, err := ts.Token(); err != nil {
+			return nil, err
+		}
+		client = githubsvc.NewClient(oauth2.NewClient(ctx, ts))
+
+		app.mu.Lock()
+		app.clients[owner] = client
+		app.mu.Unlock()
+		return client, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*github.Client), nil
+}
+
+// jwt returns a signed token identifying the app, valid for the maximum of 10 minutes
+func (app *appAuth) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		// issued in the past to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(app.id, 10),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, app.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// appTransport authorizes requests with the app's JWT
+type appTransport struct {
+	app *appAuth
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	return githubsvc.OutboundTransport.RoundTrip(req)
+}
+
+// installationTokenSource mints installation access tokens, which expire after an hour
+type installationTokenSource struct {
+	ctx    context.Context
+	id     int64
+	client *github.Client
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(s.ctx, s.id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+		Expiry:      token.GetExpiresAt(),
+	}, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// auditLogParams are the query parameters passed through to the audit log API
+var auditLogParams = []string{"phrase", "include", "after", "before", "order", "per_page"}
+
+// GetAuditLog returns the org audit log events, filtered by the "phrase" query parameter.
+// The cursors for the adjacent pages are returned in the X-Next-Cursor and X-Prev-Cursor
+// headers, and are passed back as the "after" and "before" query parameters respectively. With
+// Accept: application/x-ndjson, the events of all the following pages are streamed instead.
+func GetAuditLog(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		org := mux.Vars(r)["org"]
+
+		query := url.Values{}
+		for _, param := range auditLogParams {
+			if v := r.URL.Query().Get(param); v != "" {
+				query.Set(param, v)
+			}
+		}
+
+		if WantsNDJSON(r) {
+			StreamNDJSON(w, r, func(ctx context.Context, cursor string) ([]json.RawMessage, string, error) {
+				if cursor != "" {
+					query.Set("after", cursor)
+					query.Del("before")
+				}
+				events, resp, err := svc.AuditLog(ctx, org, query)
+				if err != nil {
+					return nil, "", err
+				}
+				return events, linkCursors(resp.Header.Get("Link"))["next"], nil
+			})
+			return
+		}
+
+		events, resp, err := svc.AuditLog(r.Context(), org, query)
+		if WriteError(w, err) {
+			return
+		}
+
+		links := linkCursors(resp.Header.Get("Link"))
+		if cursor := links["next"]; cursor != "" {
+			w.Header().Set("X-Next-Cursor", cursor)
+		}
+		if cursor := links["prev"]; cursor != "" {
+			w.Header().Set("X-Prev-Cursor", cursor)
+		}
+
+		WriteJSON(w, r, http.StatusOK, events)
+	}
+}
+
+// linkCursors parses a Link header, returning the "after" or "before" cursor of each relation
+func linkCursors(header string) map[string]string {
+	cursors := map[string]string{}
+	for _, link := range strings.Split(header, ",") {
+		segments := strings.Split(strings.TrimSpace(link), ";")
+		if len(segments) < 2 {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(segments[0]), "<>"))
+		if err != nil {
+			continue
+		}
+		cursor := u.Query().Get("after")
+		if cursor == "" {
+			cursor = u.Query().Get("before")
+		}
+		for _, segment := range segments[1:] {
+			segment = strings.TrimSpace(segment)
+			if strings.HasPrefix(segment, "rel=") {
+				cursors[strings.Trim(strings.TrimPrefix(segment, "rel="), `"`)] = cursor
+			}
+		}
+	}
+	return cursors
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// BatchConfig limits the sub-requests of the batch requests
+type BatchConfig struct {
+	// MaxRequests is the number of sub-requests of a batch at most, defaulting to 20
+	MaxRequests int `yaml:"max_requests"`
+	// Concurrency is the number of sub-requests served at once, defaulting to 4
+	Concurrency int `yaml:"concurrency"`
+}
+
+// BatchRequest is a sub-request of a batch, e.g. {"method": "GET", "path": "/v1/octocat/repos/count"}
+type BatchRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the response to a sub-request, whose body is a JSON value, or a string when it isn't JSON
+type BatchResult struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Batch serves an array of sub-requests with bounded concurrency on the datastore's workers, returning the array of their results in the
+// same order. Sub-requests are served by the handler with the credentials of the batch request, each being
+// authorized, rate limited and audited on its own.
+func Batch(data *Datastore, handler http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config := data.batch
+		if config.MaxRequests <= 0 {
+			config.MaxRequests = 20
+		}
+		if config.Concurrency <= 0 {
+			config.Concurrency = 4
+		}
+
+		var requests []BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(requests) > config.MaxRequests {
+			middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("at most %v requests can be batched", config.MaxRequests))
+			return
+		}
+		for i, sub := range requests {
+			if sub.Method == "" || !strings.HasPrefix(sub.Path, "/") {
+				middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("request %v needs a method and a path", i))
+				return
+			}
+			if _, path := middleware.SplitVersion(sub.Path); strings.HasPrefix(path, "/batch") {
+				middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("batches can't be nested"))
+				return
+			}
+		}
+
+		results := make([]*BatchResult, len(requests))
+		// the sub-requests' own fan-outs run on their worker
+		g, ctx := data.workers.Group(r.Context(), "batch", config.Concurrency)
+		for i, sub := range requests {
+			g.Go(func() error {
+				results[i] = serveBatched(handler, r.WithContext(ctx), sub)
+				return nil
+			})
+		}
+		g.Wait()
+
+		WriteJSON(w, r, http.StatusOK, results)
+	}
+}
+
+// serveBatched serves the sub-request with the headers of the batch request. Its panics are recovered as a 500
+// result, since they would otherwise crash the process from the worker serving it.
+func serveBatched(handler http.Handler, r *http.Request, sub BatchRequest) (result *BatchResult) {
+	req, err := http.NewRequestWithContext(r.Context(), strings.ToUpper(sub.Method), sub.Path, bytes.NewReader(sub.Body))
+	if err != nil {
+		body, _ := json.Marshal(&middleware.ErrorBody{Error: err.Error()})
+		return &BatchResult{Status: http.StatusBadRequest, Body: body}
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Del("Content-Length")
+	req.Header.Del("Accept")
+	if len(sub.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.RemoteAddr = r.RemoteAddr
+
+	rec := &batchRecorder{header: http.Header{}, status: http.StatusOK}
+	// identifies the error bodies, as RequestID does
+	rec.header.Set("X-Request-ID", middleware.RequestIDFrom(r.Context()))
+	defer func() {
+		if p := recover(); p != nil {
+			result = panicResult(req, p)
+		}
+	}()
+	handler.ServeHTTP(rec, req)
+
+	return rec.result()
+}
+
+// panicResult logs the panic recovered from serving the request off the server's goroutine, e.g. a sub-request
+// or a job, and returns its 500 result
+func panicResult(r *http.Request, p interface{}) *BatchResult {
+	slog.ErrorContext(r.Context(), "handler panicked",
+		"panic", fmt.Sprint(p),
+		"stack", string(debug.Stack()),
+		"request_id", middleware.RequestIDFrom(r.Context()),
+		"method", r.Method,
+		"path", r.URL.Path,
+	)
+	body, _ := json.Marshal(&middleware.ErrorBody{Error: "Internal server error"})
+	header := http.Header{}
+	header.Set("X-Request-ID", middleware.RequestIDFrom(r.Context()))
+	return &BatchResult{Status: http.StatusInternalServerError, Header: header, Body: body}
+}
+
+// batchRecorder records the response to a sub-request
+type batchRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *batchRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *batchRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
+
+func (w *batchRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+// result returns the recorded response, embedding the bodies that aren't JSON as strings
+func (w *batchRecorder) result() *BatchResult {
+	body := w.body.Bytes()
+	if len(body) > 0 && !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	return &BatchResult{Status: w.status, Header: w.header, Body: body}
+}
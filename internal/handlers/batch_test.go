@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestBatchRecoversPanics(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/panic":
+			panic("boom")
+		case "/v1/partial":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"data":`))
+			panic("boom")
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"path": r.URL.Path})
+	})
+	data := NewWithService(nil)
+	t.Cleanup(data.Close)
+
+	body := `[{"method": "GET", "path": "/v1/ok"}, {"method": "GET", "path": "/v1/panic"}, {"method": "GET", "path": "/v1/partial"}]`
+	resp, b := serve(Batch(data, handler), "POST", "/v1/batch", body, map[string]string{"X-API-Version": "v1"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, b)
+	}
+	var batch struct {
+		Data []*BatchResult `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(b), &batch); err != nil {
+		t.Fatalf("%v: %s", err, b)
+	}
+	want := []int{http.StatusOK, http.StatusInternalServerError, http.StatusInternalServerError}
+	if len(batch.Data) != len(want) {
+		t.Fatalf("%d results, want %d: %s", len(batch.Data), len(want), b)
+	}
+	for i, status := range want {
+		if batch.Data[i].Status != status {
+			t.Errorf("result %d: status %d, want %d: %s", i, batch.Data[i].Status, status, batch.Data[i].Body)
+		}
+	}
+}
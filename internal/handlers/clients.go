@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
+)
+
+// ServiceFor returns the GitHubService used to serve the request, backed by ClientFor's client unless the
+// datastore was created with NewWithService
+func (data *Datastore) ServiceFor(r *http.Request) (githubsvc.GitHubService, error) {
+	if data.service != nil {
+		return data.service, nil
+	}
+
+	client, err := data.ClientFor(r)
+	if err != nil {
+		return nil, err
+	}
+	return githubsvc.NewGitHubService(client), nil
+}
+
+// ClientFor returns the Github client used to serve the request, in order of precedence:
+//   - a client for the token passed in the request's X-GitHub-Token or Authorization header
+//   - the logged in user's client when the request has an OAuth session
+//   - the client for the token mapped to the request's owner or org
+//   - the client for the request's owner or org when authenticating as a GitHub App
+//   - the datastore's client, authenticated with the TOKEN environment variable
+func (data *Datastore) ClientFor(r *http.Request) (*github.Client, error) {
+	if token := requestToken(r); token != "" {
+		return newTokenClient(data.Context, token), nil
+	}
+	if data.OAuth != nil {
+		if client := data.OAuth.clientFor(r); client != nil {
+			return client, nil
+		}
+	}
+
+	return data.clientForOwner(requestOwner(r))
+}
+
+// ServiceForOwner returns the GitHubService acting for the owner or org outside of the requests, e.g. in the
+// webhook automations, backed by ClientFor's owner or default client
+func (data *Datastore) ServiceForOwner(owner string) (githubsvc.GitHubService, error) {
+	if data.service != nil {
+		return data.service, nil
+	}
+
+	client, err := data.clientForOwner(owner)
+	if err != nil {
+		return nil, err
+	}
+	return githubsvc.NewGitHubService(client), nil
+}
+
+// clientForOwner returns the client of the token mapped to the owner, or of the owner's GitHub App
+// installation, or else the datastore's client
+func (data *Datastore) clientForOwner(owner string) (*github.Client, error) {
+	if client, ok := data.owners[strings.ToLower(owner)]; ok {
+		return client, nil
+	}
+	if data.app == nil || owner == "" {
+		return data.Client, nil
+	}
+	return data.app.clientFor(data.Context, owner)
+}
+
+// MapOwnerTokens sets the tokens used for the requests of specific owners or orgs, instead of the default client
+func (data *Datastore) MapOwnerTokens(tokens map[string]string) {
+	data.owners = map[string]*github.Client{}
+	for owner, token := range tokens {
+		data.owners[strings.ToLower(owner)] = newTokenClient(data.Context, token)
+	}
+}
+
+// ParseOwnerTokens parses a list of owner to token mappings, e.g. "org1=token1,org2=token2"
+func ParseOwnerTokens(s string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, mapping := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(mapping), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid owner token mapping %q", mapping)
+		}
+		tokens[parts[0]] = parts[1]
+	}
+	return tokens, nil
+}
+
+// ownCredentials reports whether the request is served with the consumer's own GitHub credentials, its token or
+// OAuth session, rather than the proxy's
+func (data *Datastore) ownCredentials(r *http.Request) bool {
+	return requestToken(r) != "" || data.OAuth != nil && data.OAuth.clientFor(r) != nil
+}
+
+// requestOwner returns the owner or org route variable of the request
+func requestOwner(r *http.Request) string {
+	vars := mux.Vars(r)
+	if owner := vars["owner"]; owner != "" {
+		return owner
+	}
+	return vars["org"]
+}
+
+// requestToken returns the Github token of an "X-GitHub-Token: <token>", "Authorization: token <token>" or
+// "Authorization: Bearer <token>" header. The Authorization header isn't used when it carried the consumer's
+// validated bearer token.
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get("X-GitHub-Token"); token != "" {
+		return token
+	}
+	if middleware.ClaimsFrom(r.Context()) != nil {
+		return ""
+	}
+
+	parts := strings.Fields(r.Header.Get("Authorization"))
+	if len(parts) != 2 {
+		return ""
+	}
+	switch strings.ToLower(parts[0]) {
+	case "token", "bearer":
+		return parts[1]
+	}
+	return ""
+}
+
+// newTokenClient returns a Github client authenticated with the personal access or OAuth token
+func newTokenClient(ctx context.Context, token string) *github.Client {
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	return githubsvc.NewClient(oauth2.NewClient(ctx, ts))
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+// commentKind is the kind of the comments edited and deleted by their ID
+type commentKind string
+
+const (
+	commitComments commentKind = "commit"
+	issueComments  commentKind = "issue"
+	pullComments   commentKind = "pull"
+)
+
+// commentPaths are the route segments of the comments of each kind, GitHub's
+var commentPaths = map[commentKind]string{
+	commitComments: "comments",
+	issueComments:  "issues/comments",
+	pullComments:   "pulls/comments",
+}
+
+// EditComment replaces the body of the commit, issue or pull request review comment, e.g. {"body": "Fixed typo"}
+func EditComment(data *Datastore, kind commentKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		id, _ := strconv.ParseInt(vars["comment_id"], 10, 64)
+
+		var in struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		v := &validator{}
+		if v.required("body", in.Body) {
+			v.maxLength("body", in.Body, maxCommentLength)
+		}
+		if WriteError(w, v.err()) {
+			return
+		}
+
+		var cmt interface{}
+		switch kind {
+		case commitComments:
+			cmt, _, err = svc.EditCommitComment(r.Context(), owner, repo, id, in.Body)
+		case issueComments:
+			cmt, _, err = svc.EditIssueComment(r.Context(), owner, repo, id, in.Body)
+		case pullComments:
+			cmt, _, err = svc.EditPullComment(r.Context(), owner, repo, id, in.Body)
+		}
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, cmt)
+	}
+}
+
+// DeleteComment deletes the commit, issue or pull request review comment
+func DeleteComment(data *Datastore, kind commentKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		id, _ := strconv.ParseInt(vars["comment_id"], 10, 64)
+
+		switch kind {
+		case commitComments:
+			_, err = svc.DeleteCommitComment(r.Context(), owner, repo, id)
+		case issueComments:
+			_, err = svc.DeleteIssueComment(r.Context(), owner, repo, id)
+		case pullComments:
+			_, err = svc.DeletePullComment(r.Context(), owner, repo, id)
+		}
+		if WriteError(w, err) {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// ListComments lists the comments of the repository, or of the commit, issue or pull request of the route, e.g. for
+// the bots to find their own before commenting again. The issue and review comments are filtered by ?since=, an
+// RFC 3339 timestamp, and sorted by ?sort=created|updated and ?direction=asc|desc, which GitHub doesn't support for
+// the commit comments. The pages are walked with ?page= and ?per_page=, or all at once with ?all=true.
+func ListComments(data *Datastore, kind commentKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		number, _ := strconv.Atoi(vars["number"])
+
+		filter, err := commentListOptions(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		switch kind {
+		case commitComments:
+			if filter.Sort != "" || filter.Direction != "" || !filter.Since.IsZero() {
+				middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("The commit comments can't be filtered or sorted"))
+				return
+			}
+			listComments(w, r, data, func(ctx context.Context, opt github.ListOptions) ([]*github.RepositoryComment, *github.Response, error) {
+				return svc.ListCommitComments(ctx, owner, repo, vars["commit"], &opt)
+			})
+		case issueComments:
+			listComments(w, r, data, func(ctx context.Context, opt github.ListOptions) ([]*github.IssueComment, *github.Response, error) {
+				filtered := *filter
+				filtered.ListOptions = opt
+				return svc.ListIssueComments(ctx, owner, repo, number, &filtered)
+			})
+		case pullComments:
+			listComments(w, r, data, func(ctx context.Context, opt github.ListOptions) ([]*githubsvc.ReviewComment, *github.Response, error) {
+				return svc.ListPullComments(ctx, owner, repo, number, &github.PullRequestListCommentsOptions{
+					Sort:        filter.Sort,
+					Direction:   filter.Direction,
+					Since:       filter.Since,
+					ListOptions: opt,
+				})
+			})
+		}
+	}
+}
+
+// listComments writes the page of the comments asked for, or all of them with ?all=true
+func listComments[T any](w http.ResponseWriter, r *http.Request, data *Datastore, list func(ctx context.Context, opt github.ListOptions) ([]T, *github.Response, error)) {
+	if AllPages(r) {
+		WriteAll(w, r, data, list)
+		return
+	}
+
+	opt, err := ListOptions(r)
+	if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+		return
+	}
+
+	comments, resp, err := list(r.Context(), opt)
+	if WriteError(w, err) {
+		return
+	}
+
+	WritePagination(w, r, resp)
+	WriteJSON(w, r, http.StatusOK, comments)
+}
+
+// commentListOptions returns the since, sort and direction query parameters of the request, passed through to
+// GitHub
+func commentListOptions(r *http.Request) (*github.IssueListCommentsOptions, error) {
+	opt := &github.IssueListCommentsOptions{}
+	var err error
+	if opt.Sort, err = QueryChoice(r, "sort", "created", "updated"); err != nil {
+		return nil, err
+	}
+	if opt.Direction, err = QueryChoice(r, "direction", "asc", "desc"); err != nil {
+		return nil, err
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		if opt.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, fmt.Errorf("invalid since %q, expecting an RFC 3339 timestamp", since)
+		}
+	}
+	return opt, nil
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// CommentsConfig configures the templates of the comments, e.g. "Thanks {{.Author}}, {{len .Files}} files
+// changed"
+type CommentsConfig struct {
+	// Pull is the template of the pull request comments posted without a body
+	Pull string `yaml:"pull"`
+}
+
+// CommentVars are the variables of the comment templates
+type CommentVars struct {
+	Owner  string
+	Repo   string
+	Number int
+	// Author is the login of the pull request's author, and Title its title
+	Author string
+	Title  string
+	// Commit, Path and Position or Line are the location of the review comments
+	Commit   string
+	Path     string
+	Position int
+	Line     int
+	// Files are the pull request's changed files, when the template uses them
+	Files []string
+}
+
+// CommentTemplate renders the comments with their variables
+type CommentTemplate struct {
+	tmpl *template.Template
+	// files is set when the template uses the changed files, which are only listed then
+	files bool
+}
+
+// commentFuncs are the functions of the comment templates, e.g. {{join .Files ", "}}
+var commentFuncs = template.FuncMap{"join": strings.Join}
+
+// ParseCommentTemplate parses the text/template of the comment
+func ParseCommentTemplate(name, text string) (*CommentTemplate, error) {
+	tmpl, err := template.New(name).Funcs(commentFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %v comment template: %v", name, err)
+	}
+	return &CommentTemplate{tmpl: tmpl, files: strings.Contains(text, ".Files")}, nil
+}
+
+// Render returns the comment with the variables
+func (t *CommentTemplate) Render(vars CommentVars) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
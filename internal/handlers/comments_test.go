@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+)
+
+const testSHA = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+
+// countingTransport counts the calls sent to GitHub
+type countingTransport struct {
+	base  http.RoundTripper
+	calls atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return t.base.RoundTrip(req)
+}
+
+// newTestRouter returns the router of a datastore answering its GitHub calls with the cassette of testdata, once
+// configured by setup, and the transport counting the calls
+func newTestRouter(t *testing.T, cassette string, setup func(data *Datastore)) (http.Handler, *countingTransport) {
+	t.Helper()
+	replay, err := githubsvc.NewReplayTransport(filepath.Join("testdata", cassette))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &countingTransport{base: replay}
+	data := NewWithService(githubsvc.NewGitHubService(githubsvc.NewClient(&http.Client{Transport: transport})))
+	t.Cleanup(data.Close)
+	if setup != nil {
+		setup(data)
+	}
+	return NewRouter(data), transport
+}
+
+// serve serves the request, returning the response and its body
+func serve(handler http.Handler, method, path, body string, header map[string]string) (*http.Response, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	resp := rec.Result()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+// TestCommentCalls guards the GitHub calls each comment costs: GitHub attributes the comments to the token's
+// user, so they're created and listed without looking the user up first
+func TestCommentCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		calls  int32
+	}{
+		{name: "commit comment", method: "POST", path: "/v1/octocat/repos/hello-world/" + testSHA + "/comment",
+			body: `{"body": "Looks good"}`, status: http.StatusCreated, calls: 1},
+		{name: "pull request comment", method: "POST", path: "/v1/octocat/repos/hello-world/pulls/1/comments",
+			body:   `{"body": "Typo", "commit_id": "` + testSHA + `", "path": "README.md", "line": 12, "side": "RIGHT"}`,
+			status: http.StatusCreated, calls: 1},
+		{name: "page of issue comments", method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?page=2&per_page=1",
+			status: http.StatusOK, calls: 1},
+		{name: "all commit comments", method: "GET", path: "/v1/octocat/repos/hello-world/commits/" + testSHA + "/comments?all=true",
+			status: http.StatusOK, calls: 1},
+		{name: "all issue comments, a call per page", method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?all=true",
+			status: http.StatusOK, calls: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router, transport := newTestRouter(t, "comments.json", nil)
+			resp, body := serve(router, test.method, test.path, test.body, nil)
+			if resp.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, test.status, body)
+			}
+			if calls := transport.calls.Load(); calls != test.calls {
+				t.Errorf("%d GitHub calls, want %d", calls, test.calls)
+			}
+		})
+	}
+}
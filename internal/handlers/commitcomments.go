@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// CommitComment comments the commit with the body, e.g. {"body": "Looks good"}, on the line of the diff at the
+// position of the path when set
+func CommitComment(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		commit := vars["commit"]
+
+		in := new(commitCommentRequest)
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		v := &validator{}
+		v.sha("commit", commit)
+		if WriteError(w, v.err()) || WriteError(w, in.validate()) {
+			return
+		}
+
+		cmt, resp, err := svc.CreateCommitComment(r.Context(), owner, repo, commit, &github.RepositoryComment{
+			Body:     github.String(in.Body),
+			Path:     in.Path,
+			Position: in.Position,
+		})
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, responseStatus(resp, http.StatusCreated), cmt)
+	}
+}
+
+// commitCommentRequest is the body of the commit comments
+type commitCommentRequest struct {
+	Body     string  `json:"body"`
+	Path     *string `json:"path"`
+	Position *int    `json:"position"`
+}
+
+func (in *commitCommentRequest) validate() error {
+	v := &validator{}
+	if v.required("body", in.Body) {
+		v.maxLength("body", in.Body, maxCommentLength)
+	}
+	if in.Position != nil {
+		if in.Path == nil {
+			v.fail("path", "is required with position")
+		}
+		v.atLeast("position", *in.Position, 1)
+	}
+	return v.err()
+}
+
+// responseStatus returns the status of GitHub's response, or the status when there's none
+func responseStatus(resp *github.Response, status int) int {
+	if resp == nil || resp.Response == nil {
+		return status
+	}
+	return resp.StatusCode
+}
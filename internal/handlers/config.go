@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the proxy configuration, read from a YAML file. Tokens and consumer authentication settings missing
+// from the file fall back to the TOKEN, TOKENS, TOKEN_ROTATION, OWNER_TOKENS, API_KEYS, JWT_ISSUER, JWT_AUDIENCE,
+// JWT_JWKS_URL, CORS_ORIGINS, REDIS_URL, MOCK_FIXTURES, OUTBOUND_PROXY and OUTBOUND_CA_FILE environment variables,
+// and ReadOnly is set by READ_ONLY=true.
+type Config struct {
+	// Token is a personal access token, or Tokens a pool of them rotated according to TokenRotation
+	Token         string   `yaml:"token"`
+	Tokens        []string `yaml:"tokens"`
+	TokenRotation string   `yaml:"token_rotation"`
+	// TokenSecret fetches the token from Vault or AWS Secrets Manager instead, when it has a provider
+	TokenSecret SecretConfig `yaml:"token_secret"`
+	// OwnerTokens maps owners and orgs to the tokens used for their requests
+	OwnerTokens map[string]string `yaml:"owner_tokens"`
+	// Mock answers the GitHub calls with the JSON fixtures of the directory instead of calling GitHub, e.g.
+	// GET/repos/octocat/hello-world.json, or with the responses of a recorded cassette file, without a token
+	// (env MOCK_FIXTURES)
+	Mock string `yaml:"mock"`
+
+	// Routes enables or disables groups of routes by name, e.g. "scim: false"; groups are enabled by default, but
+	// the groups of write routes, e.g. "discussions-write", which need the write scopes
+	Routes map[string]bool `yaml:"routes"`
+
+	// APIKeys are the keys consumers authenticate with; the proxy is open when there are none
+	APIKeys []*middleware.APIKey `yaml:"api_keys"`
+	// Roles maps consumer identities, e.g. API key names, to one of the "read-only", "comment" or "admin" roles,
+	// and DefaultRole is granted to the other consumers. Roles are only enforced when there are any.
+	Roles       map[string]string `yaml:"roles"`
+	DefaultRole string            `yaml:"default_role"`
+	// JWT enables authenticating consumers with bearer tokens from an identity provider, whose "sub" claim
+	// identifies them
+	JWT middleware.JWTConfig `yaml:"jwt"`
+
+	// CORS allows browser dashboards to call the proxy, when there are allowed origins
+	CORS middleware.CORSConfig `yaml:"cors"`
+
+	// Timeouts are the request timeouts by route group, e.g. "migrations: 10m", with a "default" for the others
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
+
+	// RawResponses serves the response bodies as they were before the {"data": ...} envelope, for compatibility
+	RawResponses bool `yaml:"raw_responses"`
+
+	// ReadOnly serves every write request as a dry run, reporting the GitHub writes it would send instead
+	ReadOnly bool `yaml:"read_only"`
+
+	// Audit records the write requests to a file, SQLite database, the storage or syslog
+	Audit middleware.AuditConfig `yaml:"audit"`
+
+	// Debug serves the pprof profiles on /debug/pprof/ and the runtime stats on /debug/runtime, to the consumers
+	// with the admin role only
+	Debug bool `yaml:"debug"`
+
+	// Storage is the database shared by the stores whose driver is "storage"
+	Storage middleware.StorageConfig `yaml:"storage"`
+
+	// Redis is shared by the replicas for the response cache and the consumer rate limits, when it has a URL
+	Redis middleware.RedisConfig `yaml:"redis"`
+
+	// Outbound configures the proxy and the connections of the outbound calls
+	Outbound githubsvc.OutboundConfig `yaml:"outbound"`
+
+	// Retry configures retrying the GitHub calls failing transiently
+	Retry githubsvc.RetryConfig `yaml:"retry"`
+
+	// Pacing spaces the writes to GitHub, to stay clear of its secondary rate limits
+	Pacing githubsvc.PacingConfig `yaml:"pacing"`
+
+	// Breaker fails the GitHub calls fast while GitHub is degraded
+	Breaker githubsvc.BreakerConfig `yaml:"breaker"`
+
+	// Budget keeps the end of the rate limits for the interactive requests
+	Budget githubsvc.BudgetConfig `yaml:"budget"`
+
+	// Prefetch refreshes routes in the background, for them to be served from the cache
+	Prefetch PrefetchConfig `yaml:"prefetch"`
+
+	// Pagination limits the pages walked for the ?all=true list requests
+	Pagination PaginationConfig `yaml:"pagination"`
+
+	// Batch limits the sub-requests of POST /v1/batch
+	Batch BatchConfig `yaml:"batch"`
+	// Inventory keeps the metadata of the orgs' repositories, refreshed in the background
+	Inventory InventoryConfig `yaml:"inventory"`
+	// GraphQL allow-lists the queries proxied by /v1/graphql
+	GraphQL GraphQLConfig `yaml:"graphql"`
+	// Workers bounds the tasks of the paginations, batches and reports run at once across the requests
+	Workers WorkersConfig `yaml:"workers"`
+	// Shedding rejects the requests once the proxy or GitHub's rate limits are saturated
+	Shedding SheddingConfig `yaml:"shedding"`
+
+	// Comments are the templates of the comments posted by the routes
+	Comments CommentsConfig `yaml:"comments"`
+
+	// Jobs serves the requests sent with Prefer: respond-async in the background
+	Jobs JobsConfig `yaml:"jobs"`
+
+	// Webhooks receives GitHub's webhooks on /webhooks/github, when they have a secret
+	Webhooks WebhooksConfig `yaml:"webhooks"`
+
+	// Idempotency replays the responses of the write requests retried with the same Idempotency-Key
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+
+	Cache     CacheConfig                `yaml:"cache"`
+	RateLimit middleware.RateLimitConfig `yaml:"rate_limit"`
+}
+
+// CacheConfig configures the caching of GitHub responses
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+	// Routes overrides the TTL by route group, e.g. "count: 5m"; a TTL of 0 disables caching the group
+	Routes map[string]time.Duration `yaml:"routes"`
+	// Store is "storage" to keep the responses in the shared storage rather than in memory, or in Redis when
+	// configured
+	Store string `yaml:"store"`
+}
+
+// LoadConfig reads the YAML config file, and completes it from the environment
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(b, config); err != nil {
+			return nil, fmt.Errorf("Invalid config file %v: %v", path, err)
+		}
+	}
+
+	if config.Token == "" && len(config.Tokens) == 0 {
+		if s := os.Getenv("TOKENS"); s != "" {
+			config.Tokens = strings.Split(s, ",")
+		} else {
+			config.Token = os.Getenv("TOKEN")
+		}
+	}
+	if config.Mock == "" {
+		config.Mock = os.Getenv("MOCK_FIXTURES")
+	}
+	if config.Outbound.Proxy == "" {
+		config.Outbound.Proxy = os.Getenv("OUTBOUND_PROXY")
+	}
+	if config.Outbound.CAFile == "" {
+		config.Outbound.CAFile = os.Getenv("OUTBOUND_CA_FILE")
+	}
+	if config.TokenRotation == "" {
+		config.TokenRotation = os.Getenv("TOKEN_ROTATION")
+	}
+	if config.OwnerTokens == nil {
+		if s := os.Getenv("OWNER_TOKENS"); s != "" {
+			tokens, err := ParseOwnerTokens(s)
+			if err != nil {
+				return nil, err
+			}
+			config.OwnerTokens = tokens
+		}
+	}
+	if config.JWT.Issuer == "" {
+		config.JWT = middleware.JWTConfig{
+			Issuer:   os.Getenv("JWT_ISSUER"),
+			Audience: os.Getenv("JWT_AUDIENCE"),
+			JWKSURL:  os.Getenv("JWT_JWKS_URL"),
+		}
+	}
+	if config.CORS.AllowedOrigins == nil {
+		if s := os.Getenv("CORS_ORIGINS"); s != "" {
+			config.CORS.AllowedOrigins = strings.Split(s, ",")
+		}
+	}
+	if !config.ReadOnly {
+		config.ReadOnly, _ = strconv.ParseBool(os.Getenv("READ_ONLY"))
+	}
+	if !config.Debug {
+		config.Debug, _ = strconv.ParseBool(os.Getenv("DEBUG_ENDPOINTS"))
+	}
+	if config.Redis.URL == "" {
+		config.Redis.URL = os.Getenv("REDIS_URL")
+	}
+	if config.Webhooks.Secret == "" {
+		config.Webhooks.Secret = os.Getenv("GITHUB_WEBHOOK_SECRET")
+	}
+	if config.APIKeys == nil {
+		// keys from the environment are allowed to call every route
+		for _, key := range strings.Split(os.Getenv("API_KEYS"), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				config.APIKeys = append(config.APIKeys, &middleware.APIKey{Key: key})
+			}
+		}
+	}
+	return config, nil
+}
+
+// WatchConfig calls onChange with the reloaded config every time the config file is written. Invalid
+// changes are logged and ignored.
+func WatchConfig(path string, onChange func(*Config)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// editors usually replace the file rather than writing it, so the directory is watched instead
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				config, err := LoadConfig(path)
+				if err != nil {
+					slog.Error("config reload failed", "path", path, "error", err)
+					continue
+				}
+				slog.Info("config reloaded", "path", path)
+				onChange(config)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("config watcher failed", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// NewFromConfig initiates and returns a Github datastore for the configured tokens and routes, authenticating as
+// a GitHub App instead when the APP_ID environment variable is set, or with a token from a secret manager, or
+// answering from the mock fixtures. Tokens are validated before being used.
+func NewFromConfig(config *Config) (*Datastore, error) {
+	var data *Datastore
+	var err error
+	tokens := config.Tokens
+	if config.Mock != "" {
+		tokens = nil
+		data, err = NewMock(config.Mock)
+	} else if appID := os.Getenv("APP_ID"); appID != "" {
+		tokens = nil
+		data, err = newAppFromEnv(appID, os.Getenv("APP_PRIVATE_KEY_PATH"))
+	} else if config.TokenSecret.Provider != "" {
+		tokens = nil
+		if data, err = NewFromSecret(config.TokenSecret); err == nil {
+			err = ValidateToken(data.Context, data.Client, requiredScopes(config.Routes))
+			if err != nil {
+				data.Close()
+			}
+		}
+	} else if len(tokens) > 0 {
+		data, err = NewPool(tokens, config.TokenRotation)
+	} else {
+		tokens = []string{config.Token}
+		data, err = New(config.Token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// fail fast on bad tokens, rather than on every request (GitHub App tokens are minted with their permissions)
+	required := requiredScopes(config.Routes)
+	for _, token := range tokens {
+		if err := ValidateToken(data.Context, newTokenClient(data.Context, token), required); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	data.tokens = tokens
+
+	if config.OwnerTokens != nil {
+		data.MapOwnerTokens(config.OwnerTokens)
+	}
+	data.routes = config.Routes
+	data.apiKeys = config.APIKeys
+	for identity, role := range config.Roles {
+		if !middleware.ValidRole(role) {
+			data.Close()
+			return nil, fmt.Errorf("Unknown role %q of %v", role, identity)
+		}
+	}
+	if !middleware.ValidRole(config.DefaultRole) && config.DefaultRole != "" {
+		data.Close()
+		return nil, fmt.Errorf("Unknown default role %q", config.DefaultRole)
+	}
+	data.roles = config.Roles
+	data.defaultRole = config.DefaultRole
+	if err := config.CORS.Validate(); err != nil {
+		data.Close()
+		return nil, err
+	}
+	data.cors = config.CORS
+	data.rateLimit = config.RateLimit
+	data.timeouts = config.Timeouts
+	for group, attempts := range config.Retry.Routes {
+		if attempts < 1 {
+			data.Close()
+			return nil, fmt.Errorf("Invalid retry attempts %d of %v, 1 disables retries", attempts, group)
+		}
+	}
+	data.attempts = config.Retry.Routes
+	data.rawResponses = config.RawResponses
+	data.readOnly = config.ReadOnly
+	data.debug = config.Debug
+	data.backgroundRoutes = config.Budget.BackgroundRoutes
+	data.pagination = config.Pagination
+	data.batch = config.Batch
+	data.workers = NewWorkerPool(config.Workers)
+	data.shedder = NewLoadShedder(config.Shedding, data.ownCredentials)
+	if data.storage, err = middleware.OpenStorage(config.Storage); err != nil {
+		data.Close()
+		return nil, err
+	}
+	if config.Comments.Pull != "" {
+		if data.pullComment, err = ParseCommentTemplate("pull", config.Comments.Pull); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	if config.Jobs.Enabled {
+		data.jobs = NewMemoryJobs(config.Jobs.TTL)
+		if config.Jobs.Store != "" {
+			db, err := sharedStorage("jobs", config.Jobs.Store, data.storage)
+			if err == nil {
+				data.jobs, err = NewSQLJobs(db, config.Jobs.TTL)
+			}
+			if err != nil {
+				data.Close()
+				return nil, err
+			}
+		}
+	}
+	if config.Webhooks.Secret != "" || len(config.Webhooks.Poll.Orgs) > 0 {
+		if config.Webhooks.Secret != "" {
+			data.webhookSecret = []byte(config.Webhooks.Secret)
+		}
+		if data.webhooks, err = NewWebhookPipeline(data, config.Webhooks); err != nil {
+			data.Close()
+			return nil, err
+		}
+		StartPolling(data, config.Webhooks.Poll)
+	}
+	data.graphql = newGraphQLQueries(config.GraphQL)
+	if len(config.Inventory.Orgs) > 0 {
+		if data.inventory, err = StartInventory(data, config.Inventory); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	if config.Redis.URL != "" {
+		if data.redis, err = middleware.NewRedisStore(config.Redis); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	if config.Cache.Enabled {
+		data.cache = middleware.NewMemoryCache(config.Cache.MaxEntries)
+		if data.redis != nil {
+			data.cache = data.redis
+		}
+		if config.Cache.Store != "" {
+			db, err := sharedStorage("cache", config.Cache.Store, data.storage)
+			if err == nil {
+				data.cache, err = middleware.NewSQLCache(db, "cache_entries", config.Cache.MaxEntries)
+			}
+			if err != nil {
+				data.Close()
+				return nil, err
+			}
+		}
+		data.cacheConfig = config.Cache
+	}
+	if config.Idempotency.Enabled {
+		maxEntries := config.Idempotency.MaxEntries
+		if maxEntries <= 0 {
+			maxEntries = 10000
+		}
+		store := middleware.NewMemoryCache(maxEntries)
+		if config.Idempotency.Store != "" {
+			db, err := sharedStorage("idempotency", config.Idempotency.Store, data.storage)
+			if err == nil {
+				store, err = middleware.NewSQLCache(db, "idempotency_keys", maxEntries)
+			}
+			if err != nil {
+				data.Close()
+				return nil, err
+			}
+		} else if data.redis != nil {
+			store = data.redis
+		}
+		data.idempotency = newIdempotencyKeys(store, config.Idempotency)
+	}
+
+	if config.Audit.Sink != "" {
+		if data.audit, err = middleware.NewAuditSink(config.Audit, data.storage); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+
+	if config.JWT.Issuer != "" {
+		if data.jwt, err = middleware.NewJWTVerifier(data.Context, config.JWT); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Enabled reports whether the group of routes is enabled by the config
+func (data *Datastore) Enabled(group string) bool {
+	enabled, ok := data.routes[group]
+	return !ok || enabled
+}
+
+// sharedStorage returns the storage of the stores kept in the shared storage, "storage" being the only store
+// besides their default one
+func sharedStorage(kind, store string, storage *middleware.Database) (*middleware.Database, error) {
+	if store != "storage" {
+		return nil, fmt.Errorf("Unknown %v store %q", kind, store)
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("The %v store requires the storage to be configured", kind)
+	}
+	return storage, nil
+}
+
+// defaultTimeouts are the request timeouts of the route groups missing from the config
+var defaultTimeouts = map[string]time.Duration{
+	"default": 30 * time.Second,
+	// archives can take a while to download
+	"migrations": 10 * time.Minute,
+	// the event streams stay open
+	"events": 0,
+}
+
+// timeout returns the request timeout of the group of routes
+func (data *Datastore) timeout(group string) time.Duration {
+	for _, timeouts := range []map[string]time.Duration{data.timeouts, defaultTimeouts} {
+		if timeout, ok := timeouts[group]; ok {
+			return timeout
+		}
+	}
+	if timeout, ok := data.timeouts["default"]; ok {
+		return timeout
+	}
+	return defaultTimeouts["default"]
+}
+
+// cacheTTL returns how long the GET responses of the group of routes are cached, or 0 when they aren't
+func (data *Datastore) cacheTTL(group string) time.Duration {
+	if data.cache == nil || group == "jobs" || group == "events" {
+		// the jobs change until done, and the events are streamed
+		return 0
+	}
+	if ttl, ok := data.cacheConfig.Routes[group]; ok {
+		return ttl
+	}
+	return data.cacheConfig.TTL
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// WantsCSV reports whether the consumer asked for a CSV body, with Accept: text/csv or ?format=csv
+func WantsCSV(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "csv" {
+		return true
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if strings.TrimSpace(strings.Split(accepted, ";")[0]) == "text/csv" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCSV encodes v as CSV, one row per item of a list, for spreadsheets. The columns are the dotted paths of
+// the ?fields= query parameter in order, e.g. "name,owner.login", or else every top-level field. Objects and
+// lists are encoded as JSON in their cell.
+func writeCSV(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if WriteError(w, err) {
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var value interface{}
+	if WriteError(w, dec.Decode(&value)) {
+		return
+	}
+
+	rows, ok := value.([]interface{})
+	if !ok {
+		rows = []interface{}{value}
+	}
+	columns := csvColumns(r, rows)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(status)
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = csvCell(row, column)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
+
+// csvColumns returns the selected columns, or the sorted top-level fields of the rows
+func csvColumns(r *http.Request, rows []interface{}) []string {
+	var columns []string
+	if fields := r.URL.Query().Get("fields"); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				columns = append(columns, field)
+			}
+		}
+		return columns
+	}
+
+	seen := map[string]bool{}
+	for _, row := range rows {
+		object, ok := row.(map[string]interface{})
+		if !ok {
+			// lists of scalars, e.g. a count, have a single "value" column
+			return []string{"value"}
+		}
+		for field := range object {
+			if !seen[field] {
+				seen[field] = true
+				columns = append(columns, field)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// csvCell returns the value of the row at the dotted path
+func csvCell(row interface{}, path string) string {
+	value := row
+	if _, ok := row.(map[string]interface{}); ok {
+		for _, name := range strings.Split(path, ".") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			value = object[name]
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
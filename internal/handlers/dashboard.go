@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// dashboardWeeks is the weeks of commit activity of the dashboards, unless asked for with ?weeks=
+	dashboardWeeks = 4
+	// maxDashboardWeeks is the most weeks of commit activity, each counted by its own history connection
+	maxDashboardWeeks = 12
+)
+
+// RepoDashboard is the overview of a repository for the portals: its stats, forks, the CI status of its default
+// branch's head and its commits of the recent weeks
+type RepoDashboard struct {
+	RepoStats
+	Forks int `json:"forks_count"`
+	// CIStatus is the combined status of the checks and statuses of the head commit: "success", "failure",
+	// "pending", "error" or "expected", or "none" without any
+	CIStatus       string          `json:"ci_status"`
+	LastCommit     *RepoCommit     `json:"last_commit"`
+	CommitActivity []*WeekActivity `json:"commit_activity"`
+}
+
+// RepoCommit is the head commit of a repository's default branch
+type RepoCommit struct {
+	SHA     string     `json:"sha"`
+	Message string     `json:"message"`
+	Date    *time.Time `json:"date"`
+	HTMLURL string     `json:"html_url"`
+}
+
+// WeekActivity is the number of commits made to the default branch in the week starting at Week
+type WeekActivity struct {
+	Week    time.Time `json:"week"`
+	Commits int       `json:"commits"`
+}
+
+// repoDashboardNode is a repository selected by repoDashboardQuery, along with its repoStatsFragment
+type repoDashboardNode struct {
+	repoStatsNode
+	Forks  int `json:"forks_count"`
+	Branch *struct {
+		Target json.RawMessage `json:"target"`
+	} `json:"branch"`
+}
+
+// dashboardCommit is the head commit selected by repoDashboardQuery, its weeks' history aside
+type dashboardCommit struct {
+	OID           string     `json:"oid"`
+	Message       string     `json:"messageHeadline"`
+	CommittedDate *time.Time `json:"committedDate"`
+	URL           string     `json:"url"`
+	Rollup        *struct {
+		State string `json:"state"`
+	} `json:"statusCheckRollup"`
+}
+
+// GetRepoDashboard returns the stats of the repository along with its forks, the CI status and the head commit of
+// its default branch and its commits of each of the last 4 weeks, or of ?weeks= up to 12, e.g. GET
+// /v1/octocat/repos/hello-world/dashboard, from a single GraphQL query rather than a REST call each.
+func GetRepoDashboard(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+		weeks := dashboardWeeks
+		if s := r.URL.Query().Get("weeks"); s != "" {
+			if weeks, err = strconv.Atoi(s); err != nil || weeks < 1 || weeks > maxDashboardWeeks {
+				middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("The weeks must be between 1 and %d", maxDashboardWeeks))
+				return
+			}
+		}
+
+		vars := mux.Vars(r)
+		// the weeks start at the seconds sent to GitHub
+		now := time.Now().UTC().Truncate(time.Second)
+		result, _, err := svc.ForwardGraphQL(r.Context(), repoDashboardQuery(vars["owner"], vars["repo"], now, weeks))
+		if WriteError(w, err) {
+			return
+		}
+		dashboard, err := readRepoDashboard(result, now, weeks)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, dashboard)
+	}
+}
+
+// repoDashboardQuery returns the query of the repository, counting its default branch's commits of each week up
+// to now, aliased w0 for the last one
+func repoDashboardQuery(owner, name string, now time.Time, weeks int) *githubsvc.GraphQLRequest {
+	var params, history strings.Builder
+	variables := map[string]interface{}{"owner": owner, "name": name}
+	for i := 0; i < weeks; i++ {
+		n := strconv.Itoa(i)
+		until := now.Add(-time.Duration(i) * 7 * 24 * time.Hour)
+		variables["s"+n] = until.Add(-7 * 24 * time.Hour).Format(time.RFC3339)
+		variables["u"+n] = until.Format(time.RFC3339)
+		fmt.Fprintf(&params, ", $s%v: GitTimestamp!, $u%v: GitTimestamp!", n, n)
+		fmt.Fprintf(&history, "          w%v: history(since: $s%v, until: $u%v) { totalCount }\n", n, n, n)
+	}
+	query := `query($owner: String!, $name: String!` + params.String() + `) {
+  repository(owner: $owner, name: $name) {
+    ...repoStats
+    forks_count: forkCount
+    branch: defaultBranchRef {
+      target {
+        ... on Commit {
+          oid
+          messageHeadline
+          committedDate
+          url
+          statusCheckRollup { state }
+` + history.String() + `        }
+      }
+    }
+  }
+}
+` + repoStatsFragment
+	return &githubsvc.GraphQLRequest{Query: query, Variables: variables}
+}
+
+// readRepoDashboard returns the dashboard of the response, or its errors when the repository wasn't returned
+func readRepoDashboard(result *githubsvc.GraphQLResponse, now time.Time, weeks int) (*RepoDashboard, error) {
+	var repo struct {
+		Repository *repoDashboardNode `json:"repository"`
+	}
+	if len(result.Data) > 0 && string(result.Data) != "null" {
+		if err := json.Unmarshal(result.Data, &repo); err != nil {
+			return nil, err
+		}
+	}
+	if repo.Repository == nil {
+		if len(result.Errors) > 0 {
+			return nil, result.Errors
+		}
+		return nil, errors.New("The repository wasn't returned")
+	}
+
+	node := repo.Repository
+	dashboard := &RepoDashboard{Forks: node.Forks, CIStatus: "none", CommitActivity: []*WeekActivity{}}
+	node.stats(&dashboard.RepoStats)
+	if node.Branch == nil || len(node.Branch.Target) == 0 || string(node.Branch.Target) == "null" {
+		// the repository is empty
+		return dashboard, nil
+	}
+
+	// the weeks' history is aliased, so it's read from the fields by name
+	var head dashboardCommit
+	var history map[string]json.RawMessage
+	if err := json.Unmarshal(node.Branch.Target, &head); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(node.Branch.Target, &history); err != nil {
+		return nil, err
+	}
+	dashboard.LastCommit = &RepoCommit{SHA: head.OID, Message: head.Message, Date: head.CommittedDate, HTMLURL: head.URL}
+	if head.Rollup != nil {
+		dashboard.CIStatus = strings.ToLower(head.Rollup.State)
+	}
+	for i := weeks - 1; i >= 0; i-- {
+		var count totalCount
+		if b, ok := history["w"+strconv.Itoa(i)]; ok {
+			if err := json.Unmarshal(b, &count); err != nil {
+				return nil, err
+			}
+		}
+		week := now.Add(-time.Duration(i+1) * 7 * 24 * time.Hour)
+		dashboard.CommitActivity = append(dashboard.CommitActivity, &WeekActivity{Week: week, Commits: count.TotalCount})
+	}
+	return dashboard, nil
+}
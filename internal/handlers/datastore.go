@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+// Datastore serves the routes, calling GitHub with its clients or its service
+type Datastore struct {
+	// Context is the shared context of the datastore's requests, cancelled by Close
+	Context context.Context
+	cancel  context.CancelFunc
+	Client  *github.Client
+	Service *github.GitService
+
+	// background is the context of the datastore's background work, cancelled by StopBackground
+	background     context.Context
+	stopBackground context.CancelFunc
+
+	// service, when set, serves every request instead of the clients
+	service githubsvc.GitHubService
+
+	// app is set when authenticating as a GitHub App, in which case each owner has its own client
+	app *appAuth
+	// tokens are the configured tokens, reported by SelfCheck
+	tokens []string
+	// owners maps lowercase owners and orgs to clients using their own token
+	owners map[string]*github.Client
+	// routes enables or disables groups of routes, which are enabled when missing
+	routes map[string]bool
+	// apiKeys are the keys consumers must authenticate with, when there are any
+	apiKeys []*middleware.APIKey
+	// rateLimit limits the requests of each consumer
+	rateLimit middleware.RateLimitConfig
+	// redis is shared by the replicas, when configured
+	redis *middleware.RedisStore
+	// storage is the database shared by the persistent stores, when configured
+	storage *middleware.Database
+	// cache keeps the GET responses of the route groups with a cache TTL, when enabled
+	cache       middleware.CacheStore
+	cacheConfig CacheConfig
+	// idempotency replays the responses of the retried write requests, when enabled
+	idempotency *idempotencyKeys
+	// backgroundRoutes are the route groups whose GitHub calls are background ones
+	backgroundRoutes []string
+	// rawResponses serves the response bodies without the envelope
+	rawResponses bool
+	// readOnly serves every write request as a dry run
+	readOnly bool
+	// debug serves the profiling and runtime endpoints to the admins
+	debug bool
+	// pagination limits the pages walked for the ?all=true list requests
+	pagination PaginationConfig
+	// batch limits the sub-requests of the batch requests
+	batch BatchConfig
+	// inventory keeps the metadata of the orgs' repositories, when configured
+	inventory *RepoInventory
+	// graphql is the queries allowed through the GraphQL proxy, when configured
+	graphql *graphqlQueries
+	// workers run the tasks of the fan-outs, e.g. the pages of the lists walked and the batches' sub-requests
+	workers *WorkerPool
+	// shedder rejects the requests once the proxy or GitHub's rate limits are saturated, when configured
+	shedder *LoadShedder
+	// jobs keeps the requests served asynchronously, when enabled
+	jobs JobStore
+	// pullComment renders the comments of the pull requests' route
+	pullComment *CommentTemplate
+	// webhookSecret validates the webhooks handed to the pipeline, when set
+	webhookSecret []byte
+	webhooks      *WebhookPipeline
+	// audit records the write requests, when set
+	audit middleware.AuditSink
+	// timeouts are the request timeouts of the route groups, with a "default" for the others
+	timeouts map[string]time.Duration
+	// attempts are the attempts of the GitHub calls of the route groups with their own retry policy
+	attempts map[string]int
+	// cors allows browsers to call the proxy from its allowed origins
+	cors middleware.CORSConfig
+	// jwt validates the consumers' bearer tokens, when set
+	jwt *oidc.IDTokenVerifier
+	// roles maps consumer identities to their role, with defaultRole granted to the others; every consumer
+	// can call every route when there are no roles
+	roles       map[string]string
+	defaultRole string
+	// OAuth is set when users can log in through the OAuth web flow, for the proxy to act as each user
+	OAuth *oauthFlow
+}
+
+// New function, initiates and returns a Github datastore instance
+func New(authToken string) (*Datastore, error) {
+	return NewWithTransport(authToken, githubsvc.OutboundTransport)
+}
+
+// NewWithTransport function, initiates and returns a Github datastore instance sending its requests through
+// the transport, e.g. to stub or instrument the GitHub API
+func NewWithTransport(authToken string, transport http.RoundTripper) (*Datastore, error) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport}))
+
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: authToken},
+	)
+	return newWithTokenSource(ctx, cancel, ts)
+}
+
+// newWithTokenSource returns a datastore authenticating its requests with the token source, sharing the context
+func newWithTokenSource(ctx context.Context, cancel context.CancelFunc, ts oauth2.TokenSource) (*Datastore, error) {
+	tc := oauth2.NewClient(ctx, ts)
+	if tc == nil {
+		cancel()
+		return nil, errors.New("Access Token Invalid")
+	}
+
+	client := githubsvc.NewClient(tc)
+	if client == nil {
+		cancel()
+		return nil, errors.New("Error creating Github client")
+	}
+
+	return &Datastore{
+		Context: ctx,
+		cancel:  cancel,
+		Client:  client,
+		Service: client.Git,
+	}, nil
+}
+
+// NewMock function, initiates and returns a Github datastore instance answering its GitHub calls with the JSON
+// fixtures of the directory, or replaying the cassette file recorded with -record, instead of calling GitHub,
+// without a token
+func NewMock(path string) (*Datastore, error) {
+	transport, err := githubsvc.NewMockTransport(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithTransport("mock", transport)
+}
+
+// NewWithService function, returns a datastore instance serving every request with the service, e.g. a fake
+// for testing the handlers
+func NewWithService(svc githubsvc.GitHubService) *Datastore {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Datastore{
+		Context: ctx,
+		cancel:  cancel,
+		service: svc,
+	}
+}
+
+// Close cancels the datastore's shared context, aborting its requests in flight, and closes its audit sink
+// and Redis connections
+func (data *Datastore) Close() {
+	data.StopBackground()
+	data.cancel()
+	if data.audit != nil {
+		data.audit.Close()
+	}
+	if data.redis != nil {
+		data.redis.Close()
+	}
+	if data.webhooks != nil {
+		data.webhooks.Close()
+	}
+	if data.inventory != nil {
+		data.inventory.Close()
+	}
+	if data.storage != nil {
+		data.storage.Close()
+	}
+}
+
+// backgroundContext returns the context of the datastore's background work, its pollers and inventory refreshes, whose GitHub calls
+// are background ones
+func (data *Datastore) backgroundContext() context.Context {
+	if data.background == nil {
+		data.background, data.stopBackground = context.WithCancel(githubsvc.Background(data.Context))
+	}
+	return data.background
+}
+
+// StopBackground stops the datastore's background work, while its requests in flight complete, e.g. once it's
+// replaced on reload
+func (data *Datastore) StopBackground() {
+	if data.stopBackground != nil {
+		data.stopBackground()
+	}
+}
+
+// newAppFromEnv creates a GitHub App datastore from the APP_ID and the path of the app's private key
+func newAppFromEnv(appID, keyPath string) (*Datastore, error) {
+	id, err := strconv.ParseInt(appID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid APP_ID: %v", err)
+	}
+	key, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewApp(id, key)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// started is when the process started, for its uptime
+var started = time.Now()
+
+// RuntimeStats are the Go runtime's figures of the process, to follow its memory and goroutines over time
+type RuntimeStats struct {
+	GoVersion  string `json:"go_version"`
+	Uptime     int64  `json:"uptime_seconds"`
+	Goroutines int    `json:"goroutines"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	// the heap's bytes allocated, in use by spans and obtained from the OS, and its live objects
+	HeapAlloc   uint64 `json:"heap_alloc_bytes"`
+	HeapInuse   uint64 `json:"heap_inuse_bytes"`
+	HeapSys     uint64 `json:"heap_sys_bytes"`
+	HeapObjects uint64 `json:"heap_objects"`
+	// Sys is the memory obtained from the OS, the heap and the stacks among others
+	Sys          uint64     `json:"sys_bytes"`
+	StackInuse   uint64     `json:"stack_inuse_bytes"`
+	NumGC        uint32     `json:"num_gc"`
+	PauseTotal   uint64     `json:"gc_pause_total_ns"`
+	LastGC       *time.Time `json:"last_gc"`
+	NextGC       uint64     `json:"next_gc_bytes"`
+	CgoCalls     int64      `json:"cgo_calls"`
+	TotalAlloc   uint64     `json:"total_alloc_bytes"`
+	Mallocs      uint64     `json:"mallocs"`
+	Frees        uint64     `json:"frees"`
+	GCCPUPercent float64    `json:"gc_cpu_percent"`
+}
+
+// GetRuntimeStats returns the runtime stats of the process on GET /debug/runtime, stopping the world briefly to
+// read them. The profiles of /debug/pprof/ tell where the memory and the goroutines come from.
+func GetRuntimeStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		stats := &RuntimeStats{
+			GoVersion:    runtime.Version(),
+			Uptime:       int64(time.Since(started).Seconds()),
+			Goroutines:   runtime.NumGoroutine(),
+			GOMAXPROCS:   runtime.GOMAXPROCS(0),
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			HeapSys:      mem.HeapSys,
+			HeapObjects:  mem.HeapObjects,
+			Sys:          mem.Sys,
+			StackInuse:   mem.StackInuse,
+			NumGC:        mem.NumGC,
+			PauseTotal:   mem.PauseTotalNs,
+			NextGC:       mem.NextGC,
+			CgoCalls:     runtime.NumCgoCall(),
+			TotalAlloc:   mem.TotalAlloc,
+			Mallocs:      mem.Mallocs,
+			Frees:        mem.Frees,
+			GCCPUPercent: mem.GCCPUFraction * 100,
+		}
+		if mem.LastGC > 0 {
+			last := time.Unix(0, int64(mem.LastGC))
+			stats.LastGC = &last
+		}
+
+		WriteJSON(w, r, http.StatusOK, stats)
+	}
+}
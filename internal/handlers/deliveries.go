@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// FailedDelivery is a failed delivery of a hook of the org, or of one of its repositories
+type FailedDelivery struct {
+	// Repo is the repository of the hook, or empty for the org's hooks
+	Repo    string `json:"repo,omitempty"`
+	HookID  int64  `json:"hook_id"`
+	HookURL string `json:"hook_url"`
+	*githubsvc.HookDelivery
+}
+
+// hookRef is a hook of the org, when repo is empty, or of one of its repositories
+type hookRef struct {
+	repo string
+	hook *github.Hook
+}
+
+// ListFailedDeliveries returns the failed deliveries of the hooks of the org and of its repositories, or of the
+// comma separated repos query parameter, since the since query parameter, e.g. "6h", defaulting to 24h. The
+// last 100 deliveries of each hook are checked, and the failed ones since redelivered successfully are left out.
+func ListFailedDeliveries(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+		repos, since, err := deliveriesQuery(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		failed, err := failedDeliveries(r.Context(), data, svc, mux.Vars(r)["org"], repos, since)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, failed)
+	}
+}
+
+// redeliveryRequest is a delivery to redeliver
+type redeliveryRequest struct {
+	Repo       string `json:"repo"`
+	HookID     int64  `json:"hook_id"`
+	DeliveryID int64  `json:"delivery_id"`
+}
+
+// redeliveryResult is the outcome of a redelivery
+type redeliveryResult struct {
+	redeliveryRequest
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RedeliverFailed redelivers the deliveries of the body, e.g. [{"repo": "api", "hook_id": 1, "delivery_id": 2}],
+// or without a body every failed delivery listed by ListFailedDeliveries with the same query parameters,
+// returning the outcome of each redelivery
+func RedeliverFailed(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		org := mux.Vars(r)["org"]
+		var requests []redeliveryRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil && !errors.Is(err, io.EOF) {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(requests) == 0 {
+			repos, since, err := deliveriesQuery(r)
+			if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+				return
+			}
+			failed, err := failedDeliveries(r.Context(), data, svc, org, repos, since)
+			if WriteError(w, err) {
+				return
+			}
+			for _, delivery := range failed {
+				requests = append(requests, redeliveryRequest{Repo: delivery.Repo, HookID: delivery.HookID, DeliveryID: delivery.ID})
+			}
+		}
+
+		results := make([]redeliveryResult, len(requests))
+		g, _ := data.workers.Group(r.Context(), "redeliveries", data.pagination.Concurrency)
+		for i, request := range requests {
+			g.Go(func() error {
+				results[i].redeliveryRequest = request
+				resp, err := svc.RedeliverHookDelivery(r.Context(), org, request.Repo, request.HookID, request.DeliveryID)
+				if resp != nil {
+					results[i].Status = resp.StatusCode
+				}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+				return nil
+			})
+		}
+		g.Wait()
+
+		WriteJSON(w, r, http.StatusOK, results)
+	}
+}
+
+// failedDeliveries returns the failed deliveries since the time of the hooks of the org and of the repos, or of
+// all the org's repositories without any, newest first
+func failedDeliveries(ctx context.Context, data *Datastore, svc githubsvc.GitHubService, org string, repos []string, since time.Time) ([]*FailedDelivery, error) {
+	if len(repos) == 0 {
+		all, _, err := FetchAll(ctx, data, func(ctx context.Context, opt github.ListOptions) ([]*github.Repository, *github.Response, error) {
+			return svc.ListRepos(ctx, org, &github.RepositoryListOptions{ListOptions: opt})
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range all {
+			repos = append(repos, repo.GetName())
+		}
+	}
+
+	var mu sync.Mutex
+	var hooks []hookRef
+	g, gctx := data.workers.Group(ctx, "hooks", data.pagination.Concurrency)
+	// the org's hooks are listed with an empty repo
+	for _, repo := range append([]string{""}, repos...) {
+		g.Go(func() error {
+			listed, resp, err := svc.ListHooks(gctx, org, repo, &github.ListOptions{PerPage: maxPerPage})
+			if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+				// the hooks of the repositories the token doesn't administer are skipped
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, hook := range listed {
+				hooks = append(hooks, hookRef{repo: repo, hook: hook})
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var failed []*FailedDelivery
+	g, gctx = data.workers.Group(ctx, "deliveries", data.pagination.Concurrency)
+	for _, ref := range hooks {
+		g.Go(func() error {
+			deliveries, _, err := svc.ListHookDeliveries(gctx, org, ref.repo, ref.hook.GetID(), &github.ListOptions{PerPage: maxPerPage})
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, hookFailures(ref, deliveries, since)...)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(failed, func(i, j int) bool { return failed[i].DeliveredAt.After(failed[j].DeliveredAt) })
+	if failed == nil {
+		failed = []*FailedDelivery{}
+	}
+	return failed, nil
+}
+
+// hookFailures returns the hook's failed deliveries since the time, unless redelivered successfully
+func hookFailures(ref hookRef, deliveries []*githubsvc.HookDelivery, since time.Time) []*FailedDelivery {
+	succeeded := map[string]bool{}
+	for _, delivery := range deliveries {
+		if delivery.Succeeded() {
+			succeeded[delivery.GUID] = true
+		}
+	}
+
+	var failed []*FailedDelivery
+	for _, delivery := range deliveries {
+		if delivery.Succeeded() || succeeded[delivery.GUID] || delivery.DeliveredAt.Before(since) {
+			continue
+		}
+		failed = append(failed, &FailedDelivery{
+			Repo:         ref.repo,
+			HookID:       ref.hook.GetID(),
+			HookURL:      fmt.Sprint(ref.hook.Config["url"]),
+			HookDelivery: delivery,
+		})
+	}
+	return failed
+}
+
+// deliveriesQuery returns the comma separated repos and the since query parameters of the request, since
+// defaulting to 24h
+func deliveriesQuery(r *http.Request) ([]string, time.Time, error) {
+	var repos []string
+	for _, repo := range strings.Split(r.URL.Query().Get("repos"), ",") {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			repos = append(repos, repo)
+		}
+	}
+
+	since := 24 * time.Hour
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		if since, err = time.ParseDuration(value); err != nil || since <= 0 {
+			return nil, time.Time{}, fmt.Errorf("Invalid since: %v", value)
+		}
+	}
+	return repos, time.Now().Add(-since), nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+// discussionsQuery lists the discussions of a repository, most recent first, aliasing the fields to the snake
+// case of the REST API
+const discussionsQuery = `query($owner: String!, $repo: String!, $first: Int, $after: String, $last: Int, $before: String) {
+  repository(owner: $owner, name: $repo) {
+    discussions(first: $first, after: $after, last: $last, before: $before, orderBy: {field: CREATED_AT, direction: DESC}) {
+      ` + pageInfoFields + `
+      nodes {
+        id number title url
+        created_at: createdAt updated_at: updatedAt answer_chosen_at: answerChosenAt
+        author { login }
+        category { name }
+        comments { total_count: totalCount }
+      }
+    }
+  }
+}`
+
+// ListDiscussions returns a page of the repository's discussions. The opaque cursors of the neighbouring pages
+// are returned in the X-Next-Cursor and X-Prev-Cursor headers, and passed back as the cursor query parameter.
+func ListDiscussions(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+
+		variables, err := ConnectionPage(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		variables["owner"], variables["repo"] = vars["owner"], vars["repo"]
+
+		var result struct {
+			Repository struct {
+				Discussions struct {
+					PageInfo PageInfo          `json:"pageInfo"`
+					Nodes    []json.RawMessage `json:"nodes"`
+				} `json:"discussions"`
+			} `json:"repository"`
+		}
+		_, err = svc.GraphQL(r.Context(), discussionsQuery, variables, &result)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteCursors(w, result.Repository.Discussions.PageInfo)
+		WriteJSON(w, r, http.StatusOK, result.Repository.Discussions.Nodes)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// DryRunReport is the response to a dry run, e.g. {"dry_run": true, "requests": [{"method": "POST", "url":
+// "https://api.github.com/repos/octocat/hello/issues/3/comments", "body": {"body": "Looks good"}}]}
+type DryRunReport struct {
+	DryRun bool `json:"dry_run"`
+	// Requests are the GitHub writes the request would have sent, in order
+	Requests []*middleware.DryRunRequest `json:"requests"`
+}
+
+// DryRun serves the write requests with ?dry_run=true, or every write request when readOnly, as dry runs: they
+// are validated and their GitHub reads are sent, but their GitHub writes are answered with a DryRunReport rather
+// than sent. The requests failing before any write, e.g. invalid ones, are answered with their error.
+func DryRun(readOnly bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+			// the nested dry runs, e.g. of the batches' sub-requests, are reported by the outer one
+			if !middleware.IsWrite(r.Method) || !dryRun && !readOnly || middleware.DryRunFrom(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := middleware.WithDryRun(r.Context())
+			rec := &batchRecorder{header: http.Header{}, status: http.StatusOK}
+			rec.header.Set("X-Request-ID", middleware.RequestIDFrom(r.Context()))
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			requests := middleware.DryRunFrom(ctx).Requests()
+			if len(requests) == 0 && rec.status >= 400 {
+				for key, values := range rec.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(rec.status)
+				w.Write(rec.body.Bytes())
+				return
+			}
+			WriteJSON(w, r, http.StatusOK, &DryRunReport{DryRun: true, Requests: requests})
+		})
+	}
+}
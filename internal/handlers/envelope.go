@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// Envelope is the JSON body of the successful responses:
+//
+//	{
+//	  "data": ...,
+//	  "pagination": {"next_cursor": "...", "prev_cursor": "..."} or {"next_page": 3, "last_page": 10, ...},
+//	  "rate_limit": {"limit": 5000, "remaining": 4999, "reset": 1372700873},
+//	  "request_id": "..."
+//	}
+//
+// Pagination and rate_limit are omitted when the response isn't paginated, or GitHub wasn't called.
+type Envelope struct {
+	Data       interface{}        `json:"data"`
+	Pagination *Pagination        `json:"pagination,omitempty"`
+	RateLimit  *EnvelopeRateLimit `json:"rate_limit,omitempty"`
+	RequestID  string             `json:"request_id,omitempty"`
+}
+
+// Pagination holds the cursors or numbers of the neighbouring pages
+type Pagination struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	FirstPage  int    `json:"first_page,omitempty"`
+	PrevPage   int    `json:"prev_page,omitempty"`
+	NextPage   int    `json:"next_page,omitempty"`
+	LastPage   int    `json:"last_page,omitempty"`
+}
+
+// EnvelopeRateLimit is GitHub's rate limit after the request, reset being a unix timestamp
+type EnvelopeRateLimit struct {
+	Limit     int   `json:"limit"`
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"`
+}
+
+type contextKey string
+
+const rawResponsesKey contextKey = "rawResponses"
+
+// RawResponses serves the response bodies without the envelope, as they were before it, for the consumers that
+// haven't migrated yet
+func RawResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rawResponsesKey, true)))
+	})
+}
+
+// WriteJSON encodes v as the data of the response envelope with the given status code, or as the whole body
+// when serving raw responses. The pagination is taken from the X-Next-Cursor and X-Prev-Cursor headers, or the
+// page headers set by WritePagination. v is pruned to the fields selected by the ?fields= query parameter, or
+// written as CSV when asked for with WantsCSV.
+func WriteJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if WantsCSV(r) {
+		writeCSV(w, r, status, v)
+		return
+	}
+
+	v = SelectFields(r, v)
+	if raw, _ := r.Context().Value(rawResponsesKey).(bool); raw {
+		writeJSON(w, status, v)
+		return
+	}
+
+	envelope := &Envelope{
+		Data:      v,
+		RequestID: middleware.RequestIDFrom(r.Context()),
+	}
+	pagination := Pagination{NextCursor: w.Header().Get("X-Next-Cursor"), PrevCursor: w.Header().Get("X-Prev-Cursor")}
+	pagination.FirstPage, _ = strconv.Atoi(w.Header().Get("X-First-Page"))
+	pagination.PrevPage, _ = strconv.Atoi(w.Header().Get("X-Prev-Page"))
+	pagination.NextPage, _ = strconv.Atoi(w.Header().Get("X-Next-Page"))
+	pagination.LastPage, _ = strconv.Atoi(w.Header().Get("X-Last-Page"))
+	if pagination != (Pagination{}) {
+		envelope.Pagination = &pagination
+	}
+	if limit, remaining, reset, ok := middleware.GitHubRateLimit(r.Context()); ok {
+		envelope.RateLimit = &EnvelopeRateLimit{Limit: limit, Remaining: remaining, Reset: reset}
+	}
+	writeJSON(w, status, envelope)
+}
+
+// writeJSON encodes v as the JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+)
+
+// WriteError writes err as a JSON error body, returning true if there was an error. GitHub's client errors are
+// passed through with their status, e.g. 404 or 422, and GitHub's server errors are answered with a 502, or a 503
+// once the circuit breaker tripped.
+func WriteError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var errResp *github.ErrorResponse
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var circuitErr *githubsvc.CircuitOpenError
+	var budgetErr *githubsvc.BudgetExhaustedError
+	var graphqlErrs githubsvc.GraphQLErrors
+	var validationErr ValidationError
+	switch {
+	case errors.As(err, &errResp):
+		writeGitHubError(w, errResp.Response, &middleware.GitHubError{
+			Message:          errResp.Message,
+			Errors:           errResp.Errors,
+			DocumentationURL: errResp.DocumentationURL,
+		})
+	case errors.As(err, &rateErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(rateErr.Rate.Reset.Time).Seconds()))))
+		writeGitHubError(w, rateErr.Response, &middleware.GitHubError{Message: rateErr.Message})
+	case errors.As(err, &abuseErr):
+		if abuseErr.RetryAfter != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(abuseErr.RetryAfter.Seconds())))
+		}
+		writeGitHubError(w, abuseErr.Response, &middleware.GitHubError{Message: abuseErr.Message})
+	case errors.As(err, &circuitErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(circuitErr.RetryAfter.Seconds()))))
+		middleware.WriteErrorBody(w, http.StatusServiceUnavailable, &middleware.ErrorBody{Error: circuitErr.Error()})
+	case errors.As(err, &budgetErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(budgetErr.RetryAfter.Seconds()))))
+		middleware.WriteErrorBody(w, http.StatusTooManyRequests, &middleware.ErrorBody{Error: budgetErr.Error()})
+	case errors.As(err, &graphqlErrs):
+		middleware.WriteStatusError(w, graphqlErrs.Status(), err)
+	case errors.As(err, &validationErr):
+		middleware.WriteErrorBody(w, http.StatusBadRequest, &middleware.ErrorBody{Error: "Invalid request", Fields: validationErr})
+	case errors.Is(err, context.DeadlineExceeded):
+		middleware.WriteStatusError(w, http.StatusGatewayTimeout, err)
+	default:
+		middleware.WriteStatusError(w, http.StatusInternalServerError, err)
+	}
+	return true
+}
+
+// writeGitHubError writes GitHub's error response, with its status unless GitHub itself failed
+func writeGitHubError(w http.ResponseWriter, resp *http.Response, ghErr *middleware.GitHubError) {
+	status := http.StatusBadGateway
+	if resp != nil {
+		ghErr.Status = resp.StatusCode
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			status = resp.StatusCode
+		}
+	}
+	middleware.WriteErrorBody(w, status, &middleware.ErrorBody{Error: ghErr.Message, GitHub: ghErr})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// replayBatch is the number of stored events read at a time while replaying them
+const replayBatch = 100
+
+// ListEvents returns the stored webhook events after the ID of the after query parameter, until the ID of the
+// until one, selected by the comma separated events, owner, repo and org query parameters. The Link header's
+// next page continues after the last event.
+func ListEvents(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opt, err := ListOptions(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		if opt.PerPage == 0 {
+			opt.PerPage = 30
+		}
+		query := EventQuery{EventFilter: queryFilter(r), Limit: opt.PerPage}
+		for param, id := range map[string]*int64{"after": &query.After, "until": &query.Until} {
+			if value := r.URL.Query().Get(param); value != "" {
+				if *id, err = strconv.ParseInt(value, 10, 64); err != nil {
+					middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("Invalid %v: %v", param, value))
+					return
+				}
+			}
+		}
+
+		stored, err := data.webhooks.store.List(r.Context(), query)
+		if WriteError(w, err) {
+			return
+		}
+		if stored == nil {
+			stored = []*StoredEvent{}
+		}
+		if len(stored) == query.Limit {
+			next := r.URL.Query()
+			next.Set("after", strconv.FormatInt(stored[len(stored)-1].ID, 10))
+			w.Header().Set("Link", fmt.Sprintf(`<%v?%v>; rel="next"`, r.URL.Path, next.Encode()))
+		}
+
+		WriteJSON(w, r, http.StatusOK, stored)
+	}
+}
+
+// replayRequest selects the stored events to replay, after the ID and until the ID, when set
+type replayRequest struct {
+	EventFilter
+	After int64 `json:"after"`
+	Until int64 `json:"until"`
+}
+
+// replayResult is the number of replayed events, and the ID of the last one
+type replayResult struct {
+	Replayed int   `json:"replayed"`
+	LastID   int64 `json:"last_id,omitempty"`
+}
+
+// ReplayEvents hands the stored events selected by the body, e.g. {"after": 1200, "until": 1500, "events":
+// ["push"]}, to the configured sinks again, in order, e.g. after a downstream outage. The replay stops at the
+// first event a sink fails, to be resumed after the last replayed one; long replays can be sent with Prefer:
+// respond-async.
+func ReplayEvents(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body replayRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		result := replayResult{}
+		query := EventQuery{EventFilter: body.EventFilter, After: body.After, Until: body.Until, Limit: replayBatch}
+		for {
+			stored, err := data.webhooks.store.List(r.Context(), query)
+			if WriteError(w, err) {
+				return
+			}
+			for _, event := range stored {
+				webhook, err := event.event()
+				if err == nil {
+					err = data.webhooks.Replay(r.Context(), webhook)
+				}
+				if err != nil {
+					WriteError(w, fmt.Errorf("Replaying event %v failed after %v replayed: %w", event.ID, result.Replayed, err))
+					return
+				}
+				result.Replayed++
+				result.LastID = event.ID
+			}
+			if len(stored) < query.Limit {
+				break
+			}
+			query.After = result.LastID
+		}
+
+		WriteJSON(w, r, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// eventHistory is the number of recent events kept for the streams resuming after a disconnection
+const eventHistory = 1000
+
+// events broadcasts the webhook events to the live streams. It outlives the config reloads, for the streams to
+// keep receiving events.
+var events = &eventHub{subscribers: map[*subscription]bool{}}
+
+// StreamedEvent is a webhook event numbered in the order it was received, for streams to resume after it
+type StreamedEvent struct {
+	ID    uint64
+	Event *WebhookEvent
+}
+
+// EventFilter selects the streamed events by their pattern, e.g. "pull_request.opened", owner of their
+// repository or org, repository and org, empty lists selecting all of them
+type EventFilter struct {
+	Events []string `json:"events"`
+	Owners []string `json:"owners"`
+	Repos  []string `json:"repos"`
+	Orgs   []string `json:"orgs"`
+}
+
+// Matches reports whether the filter selects the event
+func (f *EventFilter) Matches(event *WebhookEvent) bool {
+	return matchesAny(f.Events, func(pattern string) bool { return matchesPattern(pattern, event) }) &&
+		matchesAny(f.Owners, func(owner string) bool { return strings.EqualFold(owner, eventOwner(event)) }) &&
+		matchesAny(f.Repos, func(repo string) bool { return strings.EqualFold(repo, event.Repo) }) &&
+		matchesAny(f.Orgs, func(org string) bool { return strings.EqualFold(org, event.Org) })
+}
+
+// eventOwner returns the owner of the event's repository, or its org
+func eventOwner(event *WebhookEvent) string {
+	if owner, _, ok := strings.Cut(event.Repo, "/"); ok {
+		return owner
+	}
+	return event.Org
+}
+
+func matchesAny(values []string, match func(string) bool) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPattern reports whether the event matches the pattern of a WebhookRegistry
+func matchesPattern(pattern string, event *WebhookEvent) bool {
+	return pattern == "*" || pattern == event.Type || pattern == event.Type+"."+event.Action
+}
+
+// eventHub numbers the events, keeping the recent ones for the streams resuming
+type eventHub struct {
+	mu          sync.Mutex
+	last        uint64
+	recent      []*StreamedEvent
+	subscribers map[*subscription]bool
+}
+
+// subscription receives the events selected by its filter, until its channel is closed because it fell behind
+type subscription struct {
+	filter EventFilter
+	events chan *StreamedEvent
+}
+
+// HandleWebhook broadcasts the event to the subscriptions
+func (h *eventHub) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last++
+	streamed := &StreamedEvent{ID: h.last, Event: event}
+	h.recent = append(h.recent, streamed)
+	if len(h.recent) > eventHistory {
+		h.recent = h.recent[len(h.recent)-eventHistory:]
+	}
+	for sub := range h.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- streamed:
+		default:
+			// the slow streams are dropped rather than holding the webhooks back, and resume from their last ID
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+	return nil
+}
+
+// subscribe returns a subscription to the events selected by the filter, and the recent ones after the ID
+func (h *eventHub) subscribe(filter EventFilter, after uint64) (*subscription, []*StreamedEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []*StreamedEvent
+	if after > 0 {
+		for _, streamed := range h.recent {
+			if streamed.ID > after && filter.Matches(streamed.Event) {
+				missed = append(missed, streamed)
+			}
+		}
+	}
+	sub := &subscription{filter: filter, events: make(chan *StreamedEvent, 64)}
+	h.subscribers[sub] = true
+	return sub, missed
+}
+
+func (h *eventHub) unsubscribe(sub *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[sub] {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// EventStoreConfig configures the store of the received webhook events
+type EventStoreConfig struct {
+	// Driver is "sqlite" or "postgres", and DSN the SQLite database's path or the Postgres connection string, or
+	// "storage" for the shared storage
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+	// Retention is how long the events are kept, defaulting to 7 days
+	Retention time.Duration `yaml:"retention"`
+}
+
+// StoredEvent is a received webhook event, numbered in the order it was stored
+type StoredEvent struct {
+	ID         int64           `json:"id"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Type       string          `json:"type"`
+	Action     string          `json:"action,omitempty"`
+	DeliveryID string          `json:"delivery_id"`
+	Repo       string          `json:"repo,omitempty"`
+	Org        string          `json:"org,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// EventQuery selects the stored events by the filter, after the ID and until the ID, when set, in ID order
+type EventQuery struct {
+	EventFilter
+	After int64
+	Until int64
+	Limit int
+}
+
+// EventStore keeps the received webhook events for the retention
+type EventStore interface {
+	Save(ctx context.Context, event *WebhookEvent) error
+	List(ctx context.Context, query EventQuery) ([]*StoredEvent, error)
+	Close() error
+}
+
+// NewEventStore opens the configured store, creating its webhook_events table when missing
+func NewEventStore(config EventStoreConfig, storage *middleware.Database) (EventStore, error) {
+	retention := config.Retention
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+
+	db, err := middleware.OpenDatabase(config.Driver, config.DSN, storage)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Migrate(`CREATE TABLE IF NOT EXISTS webhook_events (
+		id `+db.AutoIncrement()+`,
+		received_at TIMESTAMP NOT NULL,
+		type TEXT NOT NULL,
+		action TEXT NOT NULL,
+		delivery_id TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		org TEXT NOT NULL,
+		payload TEXT NOT NULL
+	)`, `CREATE INDEX IF NOT EXISTS webhook_events_received_at ON webhook_events (received_at)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlEventStore{db: db, retention: retention}, nil
+}
+
+// sqlEventStore keeps the events in the webhook_events table of a SQLite or Postgres database
+type sqlEventStore struct {
+	db        *middleware.Database
+	retention time.Duration
+
+	mu sync.Mutex
+	// pruned is when the expired events were last deleted
+	pruned time.Time
+}
+
+func (s *sqlEventStore) Save(ctx context.Context, event *WebhookEvent) error {
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, s.db.Bind(`INSERT INTO webhook_events
+		(received_at, type, action, delivery_id, repo, org, payload) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		now, event.Type, event.Action, event.DeliveryID, event.Repo, event.Org, string(event.Raw))
+	if err != nil {
+		return err
+	}
+
+	// the expired events are deleted once a minute rather than on every event
+	s.mu.Lock()
+	prune := now.Sub(s.pruned) > time.Minute
+	if prune {
+		s.pruned = now
+	}
+	s.mu.Unlock()
+	if prune {
+		_, err = s.db.ExecContext(ctx, s.db.Bind(`DELETE FROM webhook_events WHERE received_at < ?`), now.Add(-s.retention))
+	}
+	return err
+}
+
+func (s *sqlEventStore) List(ctx context.Context, query EventQuery) ([]*StoredEvent, error) {
+	var where []string
+	var args []interface{}
+	anyOf := func(values []string, condition func(value string) string) {
+		if len(values) == 0 {
+			return
+		}
+		conditions := make([]string, len(values))
+		for i, value := range values {
+			conditions[i] = condition(value)
+		}
+		where = append(where, "("+strings.Join(conditions, " OR ")+")")
+	}
+
+	where = append(where, "id > ?")
+	args = append(args, query.After)
+	if query.Until > 0 {
+		where = append(where, "id <= ?")
+		args = append(args, query.Until)
+	}
+	anyOf(query.Events, func(pattern string) string {
+		if pattern == "*" {
+			return "1 = 1"
+		}
+		eventType, action, ok := strings.Cut(pattern, ".")
+		args = append(args, eventType)
+		if !ok {
+			return "type = ?"
+		}
+		args = append(args, action)
+		return "(type = ? AND action = ?)"
+	})
+	anyOf(query.Owners, func(owner string) string {
+		args = append(args, strings.ToLower(owner)+"/%", strings.ToLower(owner))
+		return "(LOWER(repo) LIKE ? OR (repo = '' AND LOWER(org) = ?))"
+	})
+	anyOf(query.Repos, func(repo string) string {
+		args = append(args, strings.ToLower(repo))
+		return "LOWER(repo) = ?"
+	})
+	anyOf(query.Orgs, func(org string) string {
+		args = append(args, strings.ToLower(org))
+		return "LOWER(org) = ?"
+	})
+	args = append(args, query.Limit)
+
+	rows, err := s.db.QueryContext(ctx, s.db.Bind(`SELECT id, received_at, type, action, delivery_id, repo, org, payload
+		FROM webhook_events WHERE `+strings.Join(where, " AND ")+` ORDER BY id LIMIT ?`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stored []*StoredEvent
+	for rows.Next() {
+		event := &StoredEvent{}
+		var payload string
+		if err := rows.Scan(&event.ID, &event.ReceivedAt, &event.Type, &event.Action, &event.DeliveryID,
+			&event.Repo, &event.Org, &payload); err != nil {
+			return nil, err
+		}
+		event.Payload = json.RawMessage(payload)
+		stored = append(stored, event)
+	}
+	return stored, rows.Err()
+}
+
+func (s *sqlEventStore) Close() error {
+	return s.db.Close()
+}
+
+// event returns the webhook event of the stored event, for its replay
+func (e *StoredEvent) event() (*WebhookEvent, error) {
+	payload, err := parsePayload(e.Type, e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookEvent{
+		Type:       e.Type,
+		Action:     e.Action,
+		DeliveryID: e.DeliveryID,
+		Repo:       e.Repo,
+		Org:        e.Org,
+		Payload:    payload,
+		Raw:        e.Payload,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// fieldTree is the set of fields selected at each level of a JSON value, an empty tree selecting all of them
+type fieldTree map[string]fieldTree
+
+// parseFields parses the fields query parameter, a comma separated list of dotted paths, e.g.
+// "name,owner.login"
+func parseFields(r *http.Request) fieldTree {
+	fields := r.URL.Query().Get("fields")
+	if fields == "" {
+		return nil
+	}
+	return newFieldTree(strings.Split(fields, ","))
+}
+
+// newFieldTree returns the tree of the dotted paths
+func newFieldTree(paths []string) fieldTree {
+	tree := fieldTree{}
+	for _, path := range paths {
+		node := tree
+		for _, name := range strings.Split(strings.TrimSpace(path), ".") {
+			if name == "" {
+				break
+			}
+			if node[name] == nil {
+				node[name] = fieldTree{}
+			}
+			node = node[name]
+		}
+	}
+	return tree
+}
+
+// SelectFields prunes v to the fields selected by the ?fields= query parameter, each item of the lists being
+// pruned, since dashboards mostly ignore the many fields of GitHub's objects. v is returned as is without the
+// parameter.
+func SelectFields(r *http.Request, v interface{}) interface{} {
+	tree := parseFields(r)
+	if len(tree) == 0 {
+		return v
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// numbers are kept as they are, e.g. the 64 bit IDs
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return v
+	}
+	return tree.prune(value)
+}
+
+// prune keeps the selected fields of the objects, and of the objects in lists
+func (tree fieldTree) prune(value interface{}) interface{} {
+	if len(tree) == 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = tree.prune(item)
+		}
+		return v
+	case map[string]interface{}:
+		pruned := map[string]interface{}{}
+		for name, subtree := range tree {
+			if field, ok := v[name]; ok {
+				pruned[name] = subtree.prune(field)
+			}
+		}
+		return pruned
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+)
+
+// ForwardConfig configures forwarding the webhook events to an internal HTTP endpoint
+type ForwardConfig struct {
+	URL string `yaml:"url"`
+	// Secret signs the forwarded payloads in the X-Hub-Signature-256 header, as GitHub does
+	Secret string `yaml:"secret"`
+	// MaxAttempts is the number of attempts of a delivery, defaulting to 3, retried after 1s then 2s, ...
+	MaxAttempts int `yaml:"max_attempts"`
+	// Events are the patterns of the events forwarded, defaulting to every event, e.g. "pull_request.opened"
+	Events []string `yaml:"events"`
+}
+
+// forwardSink posts the webhook events to the endpoint, retrying the failed deliveries
+type forwardSink struct {
+	config ForwardConfig
+	client *http.Client
+}
+
+// NewForwardSink returns a sink posting the webhook events to the configured endpoint
+func NewForwardSink(config ForwardConfig) WebhookSink {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	return &forwardSink{config: config, client: &http.Client{Transport: githubsvc.OutboundTransport, Timeout: 10 * time.Second}}
+}
+
+func (s *forwardSink) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	var err error
+	for attempt := 0; attempt < s.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(1<<(attempt-1)) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err = s.deliver(ctx, event); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// deliver posts the event once, failing on errors and the statuses other than 2xx
+func (s *forwardSink) deliver(ctx context.Context, event *WebhookEvent) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.URL, bytes.NewReader(event.Raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event.Type)
+	req.Header.Set("X-GitHub-Delivery", event.DeliveryID)
+	if s.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.config.Secret))
+		mac.Write(event.Raw)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("forwarding the webhook to %v failed with %v", s.config.URL, resp.Status)
+	}
+	return nil
+}
+
+func (s *forwardSink) Close() error {
+	return nil
+}
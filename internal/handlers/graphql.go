@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// PageInfo is the pagination of a GraphQL connection
+type PageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+}
+
+// pageInfoFields selects the PageInfo of a connection
+const pageInfoFields = "pageInfo { hasNextPage hasPreviousPage startCursor endCursor }"
+
+// ConnectionPage returns the variables selecting the page of a GraphQL connection, "first" and "after" or "last"
+// and "before", from the opaque cursor and the per_page query parameters. The connections of the queries take
+// these four variables.
+func ConnectionPage(r *http.Request) (map[string]interface{}, error) {
+	opt, err := ListOptions(r)
+	if err != nil {
+		return nil, err
+	}
+	if opt.PerPage == 0 {
+		opt.PerPage = 30
+	}
+
+	variables := map[string]interface{}{"first": opt.PerPage}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		direction, value, ok := strings.Cut(string(b), ":")
+		if err != nil || !ok || (direction != "next" && direction != "prev") {
+			return nil, errors.New("invalid cursor")
+		}
+		if direction == "next" {
+			variables["after"] = value
+		} else {
+			delete(variables, "first")
+			variables["last"] = opt.PerPage
+			variables["before"] = value
+		}
+	}
+	return variables, nil
+}
+
+// WriteCursors sets the opaque cursors of the neighbouring pages of the connection in the X-Next-Cursor and
+// X-Prev-Cursor headers, enveloped by WriteJSON and passed back as the cursor query parameter
+func WriteCursors(w http.ResponseWriter, info PageInfo) {
+	if info.HasNextPage {
+		w.Header().Set("X-Next-Cursor", base64.RawURLEncoding.EncodeToString([]byte("next:"+info.EndCursor)))
+	}
+	if info.HasPreviousPage {
+		w.Header().Set("X-Prev-Cursor", base64.RawURLEncoding.EncodeToString([]byte("prev:"+info.StartCursor)))
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// GraphQLConfig configures the GraphQL proxy of /v1/graphql, which runs the persisted queries only unless
+// AllowAny is set
+type GraphQLConfig struct {
+	// Queries are the persisted queries by name, run by their name or by sending their text, leading and trailing
+	// spaces aside
+	Queries map[string]string `yaml:"queries"`
+	// AllowAny runs every query sent, not only the persisted ones
+	AllowAny bool `yaml:"allow_any"`
+}
+
+// graphqlCost is the rate limit cost of the GraphQL queries, by persisted query, "adhoc" for the others
+var graphqlCost = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "github_api_graphql_cost_total",
+	Help: "GraphQL rate limit points spent by the queries run through /v1/graphql, by persisted query.",
+}, []string{"query"})
+
+// costField is added to the selection of the queries, for GitHub to report their cost along with their data
+const costField = "_proxyCost: rateLimit { cost }"
+
+// graphqlQueries are the persisted queries allowed, by name and by the SHA-256 of their text
+type graphqlQueries struct {
+	byName   map[string]string
+	byHash   map[[32]byte]string
+	allowAny bool
+}
+
+// newGraphQLQueries returns the configured queries, or nil when the GraphQL proxy isn't configured
+func newGraphQLQueries(config GraphQLConfig) *graphqlQueries {
+	if len(config.Queries) == 0 && !config.AllowAny {
+		return nil
+	}
+	queries := &graphqlQueries{byName: config.Queries, byHash: map[[32]byte]string{}, allowAny: config.AllowAny}
+	for name, query := range config.Queries {
+		queries.byHash[sha256.Sum256([]byte(strings.TrimSpace(query)))] = name
+	}
+	return queries
+}
+
+// graphqlProxyRequest is the body of the GraphQL requests, running the persisted query of the ID or the query
+type graphqlProxyRequest struct {
+	githubsvc.GraphQLRequest
+	ID string `json:"id,omitempty"`
+}
+
+// graphqlProxyResponse is GitHub's GraphQL response, with the cost of the query in its extensions
+type graphqlProxyResponse struct {
+	*githubsvc.GraphQLResponse
+	Extensions *graphqlExtensions `json:"extensions,omitempty"`
+}
+
+type graphqlExtensions struct {
+	Cost int `json:"cost"`
+}
+
+// GraphQL runs GraphQL queries with the credentials of the request, e.g. POST /v1/graphql {"id": "repo-overview",
+// "variables": {"owner": "octocat"}}, or {"query": "...", "variables": {...}} with the text of a persisted query
+// or any query when allowed; the persisted queries, but not mutations, can also be run with GET
+// /v1/graphql?id=repo-overview&variables={...}. The response is GitHub's, {"data": ..., "errors": [...]}, as the
+// GraphQL clients expect, with the rate limit cost of the queries in extensions.cost.
+func GraphQL(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		in := &graphqlProxyRequest{}
+		if r.Method == "GET" {
+			in.ID = r.URL.Query().Get("id")
+			in.OperationName = r.URL.Query().Get("operationName")
+			if variables := r.URL.Query().Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &in.Variables); err != nil {
+					middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("Invalid variables: %v", err))
+					return
+				}
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		name, err := data.graphql.resolve(in)
+		if middleware.WriteStatusError(w, http.StatusForbidden, err) {
+			return
+		}
+		kind, at := graphqlOperation(in.Query, in.OperationName)
+		if kind == "" {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("The query has no such operation"))
+			return
+		}
+		if r.Method == "GET" && kind != "query" {
+			middleware.WriteStatusError(w, http.StatusMethodNotAllowed, fmt.Errorf("A %v can't be run with GET", kind))
+			return
+		}
+
+		request := in.GraphQLRequest
+		if kind == "query" {
+			request.Query = in.Query[:at] + " " + costField + " " + in.Query[at:]
+		}
+		result, _, err := svc.ForwardGraphQL(r.Context(), &request)
+		if WriteError(w, err) {
+			return
+		}
+
+		out := &graphqlProxyResponse{GraphQLResponse: result}
+		var cost int
+		if kind == "query" {
+			result.Data, cost = takeCost(result.Data)
+			out.Extensions = &graphqlExtensions{Cost: cost}
+			graphqlCost.WithLabelValues(name).Add(float64(cost))
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// resolve sets the query of the request's persisted query ID, and returns the name of its persisted query, or
+// "adhoc" for the queries allowed without being persisted
+func (queries *graphqlQueries) resolve(in *graphqlProxyRequest) (string, error) {
+	if in.ID != "" {
+		query, ok := queries.byName[in.ID]
+		if !ok {
+			return "", fmt.Errorf("Unknown persisted query %q", in.ID)
+		}
+		in.Query = query
+		return in.ID, nil
+	}
+	if name, ok := queries.byHash[sha256.Sum256([]byte(strings.TrimSpace(in.Query)))]; ok {
+		return name, nil
+	}
+	if !queries.allowAny || in.Query == "" {
+		return "", errors.New("Only the persisted queries can be run")
+	}
+	return "adhoc", nil
+}
+
+// graphqlOperation returns the kind of the operation of the document, "query", "mutation" or "subscription",
+// and the offset of its selection set's content, or an empty kind when there's no such operation. The operation
+// is the only one of the document, or the one named operationName.
+func graphqlOperation(document, operationName string) (string, int) {
+	depth, parens := 0, 0
+	// words are the names of the definition before its selection set, e.g. "query", "Repo" and a directive's
+	var words []string
+	for i := 0; i < len(document); i++ {
+		c := document[i]
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(document[i:], `"""`):
+			end := strings.Index(document[i+3:], `"""`)
+			if end < 0 {
+				return "", 0
+			}
+			i += end + 5
+		case c == '"':
+			for i++; i < len(document) && document[i] != '"'; i++ {
+				if document[i] == '\\' {
+					i++
+				}
+			}
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case c == '{' && parens == 0:
+			if depth == 0 {
+				kind, name := "query", ""
+				if len(words) > 0 {
+					kind = words[0]
+				}
+				if len(words) > 1 {
+					name = words[1]
+				}
+				if kind != "fragment" && (operationName == "" || operationName == name) {
+					return kind, i + 1
+				}
+				words = nil
+			}
+			depth++
+		case c == '}' && parens == 0:
+			depth--
+		case depth == 0 && parens == 0 && (c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'):
+			start := i
+			for i+1 < len(document) && isNameChar(document[i+1]) {
+				i++
+			}
+			words = append(words, document[start:i+1])
+		}
+	}
+	return "", 0
+}
+
+// isNameChar reports whether the character can continue a GraphQL name
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// takeCost removes the cost selected by costField from the data, the first field as it was selected first, and
+// returns the data without it and the cost
+func takeCost(data json.RawMessage) (json.RawMessage, int) {
+	var compact bytes.Buffer
+	if json.Compact(&compact, data) != nil {
+		return data, 0
+	}
+	prefix := []byte(`{"_proxyCost":`)
+	b := compact.Bytes()
+	if !bytes.HasPrefix(b, prefix) {
+		return data, 0
+	}
+
+	var rateLimit struct {
+		Cost int `json:"cost"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b[len(prefix):]))
+	if dec.Decode(&rateLimit) != nil {
+		return data, 0
+	}
+	rest := b[len(prefix)+int(dec.InputOffset()):]
+	if bytes.HasPrefix(rest, []byte(",")) {
+		rest = rest[1:]
+	}
+	return append([]byte("{"), rest...), rateLimit.Cost
+}
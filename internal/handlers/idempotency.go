@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// IdempotencyConfig configures replaying the responses of the write requests retried with the same
+// Idempotency-Key header
+type IdempotencyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long the responses are kept for the retries, 24h by default
+	TTL time.Duration `yaml:"ttl"`
+	// MaxEntries bounds the responses kept in memory or in the storage, 10000 by default, unless they're kept in
+	// Redis
+	MaxEntries int `yaml:"max_entries"`
+	// Store is "storage" to keep the responses in the shared storage, rather than in Redis when configured, or
+	// in memory
+	Store string `yaml:"store"`
+}
+
+// maxIdempotencyKey is the length of the longest Idempotency-Key header accepted
+const maxIdempotencyKey = 255
+
+// idempotencyClaimTTL bounds how long a key stays claimed by a request in flight, for the key of a replica
+// stopped while serving it not to be answered 409 until its TTL
+const idempotencyClaimTTL = 5 * time.Minute
+
+// idempotentResponse is the response to a write request, as kept in the CacheStore for its retries
+type idempotentResponse struct {
+	// Request is the hash of the request, for the key not to be reused by another one
+	Request string      `json:"request"`
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+}
+
+// idempotencyKeys keeps the responses of the write requests by consumer and Idempotency-Key, and claims the keys
+// of the requests in flight, in the store shared by the replicas
+type idempotencyKeys struct {
+	store middleware.ClaimStore
+	ttl   time.Duration
+}
+
+// newIdempotencyKeys returns the keys kept in the store, e.g. Redis when the proxy has replicas
+func newIdempotencyKeys(store middleware.ClaimStore, config IdempotencyConfig) *idempotencyKeys {
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &idempotencyKeys{store: store, ttl: ttl}
+}
+
+// Idempotent replays the response of the POST, PUT, PATCH and DELETE requests sent again with the same
+// Idempotency-Key header, e.g. by the webhook-driven callers retrying, instead of creating the comment or
+// release twice. The responses are kept for the TTL, except the server errors and rate limits, which may be
+// retried. Reusing a key for a different request is answered 422, and retrying while the request is in flight,
+// on any of the replicas, 409.
+func (keys *idempotencyKeys) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		switch {
+		case idempotencyKey == "" || r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS":
+			next.ServeHTTP(w, r)
+			return
+		case middleware.DryRunFrom(r.Context()) != nil:
+			// the dry runs write nothing to replay
+			next.ServeHTTP(w, r)
+			return
+		case len(idempotencyKey) > maxIdempotencyKey:
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("The Idempotency-Key header is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		key := "idempotency:" + consumerHash(r) + ":" + idempotencyKey
+		request := requestHash(r, body)
+
+		if keys.replay(w, r, key, request) {
+			return
+		}
+
+		claimed, err := keys.store.Claim(r.Context(), "claim:"+key, []byte(request), idempotencyClaimTTL)
+		if err != nil {
+			middleware.WriteStatusError(w, http.StatusServiceUnavailable, errors.New("The Idempotency-Key can't be claimed, the store is unavailable"))
+			return
+		}
+		if !claimed {
+			middleware.WriteStatusError(w, http.StatusConflict, errors.New("The request with this Idempotency-Key is in progress"))
+			return
+		}
+		// released once the response is kept, for the retries to replay it
+		defer keys.store.Delete(context.WithoutCancel(r.Context()), "claim:"+key)
+		// the request claimed may have been served since the response was looked up
+		if keys.replay(w, r, key, request) {
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= 500 || rec.status == http.StatusTooManyRequests {
+			return
+		}
+
+		saved := &idempotentResponse{Request: request, Status: rec.status, Header: http.Header{}, Body: rec.body.Bytes()}
+		for _, header := range append(cachedHeaders, "Location") {
+			if value := w.Header().Get(header); value != "" {
+				saved.Header.Set(header, value)
+			}
+		}
+		if b, err := json.Marshal(saved); err == nil {
+			keys.store.Set(r.Context(), key, b, keys.ttl)
+		}
+	})
+}
+
+// replay writes the response kept for the key, or 422 when it's kept for another request, reporting whether it
+// was kept
+func (keys *idempotencyKeys) replay(w http.ResponseWriter, r *http.Request, key, request string) bool {
+	b, ok := keys.store.Get(r.Context(), key)
+	if !ok {
+		return false
+	}
+	var saved idempotentResponse
+	if json.Unmarshal(b, &saved) != nil {
+		return false
+	}
+	if saved.Request != request {
+		middleware.WriteStatusError(w, http.StatusUnprocessableEntity, errors.New("The Idempotency-Key was used for another request"))
+		return true
+	}
+	writeIdempotent(w, r, &saved)
+	return true
+}
+
+// requestHash identifies the request by its method, url and body
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(strings.Join([]string{r.Method, r.URL.RequestURI(), ""}, "\n")))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeIdempotent replays the saved response, identified by the current request's ID
+func writeIdempotent(w http.ResponseWriter, r *http.Request, saved *idempotentResponse) {
+	for header, values := range saved.Header {
+		w.Header()[header] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(saved.Status)
+	w.Write(replayedBody(r, saved.Body))
+}
+
+// idempotencyRecorder records the status and body of the response as it's written
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotencyRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+func TestIdempotentClaims(t *testing.T) {
+	stores := map[string]func(t *testing.T) middleware.ClaimStore{
+		"memory": func(t *testing.T) middleware.ClaimStore { return middleware.NewMemoryCache(100) },
+		"storage": func(t *testing.T) middleware.ClaimStore {
+			db, err := middleware.OpenDatabase("sqlite", filepath.Join(t.TempDir(), "storage.db"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			store, err := middleware.NewSQLCache(db, "idempotency_keys", 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			// the replicas share the store, the first one serving the request until released
+			started, release := make(chan struct{}), make(chan struct{})
+			var served atomic.Int32
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if served.Add(1) == 1 {
+					close(started)
+					<-release
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":1}`))
+			})
+			replicas := []http.Handler{
+				newIdempotencyKeys(store, IdempotencyConfig{Enabled: true}).Idempotent(next),
+				newIdempotencyKeys(store, IdempotencyConfig{Enabled: true}).Idempotent(next),
+			}
+			send := func(replica http.Handler) *httptest.ResponseRecorder {
+				req := httptest.NewRequest("POST", "/v1/octocat/repos/hello-world/issues/1/comments", strings.NewReader(`{"body": "Looks good"}`))
+				req.Header.Set("Idempotency-Key", "comment-1")
+				rec := httptest.NewRecorder()
+				replica.ServeHTTP(rec, req)
+				return rec
+			}
+
+			first := make(chan *httptest.ResponseRecorder)
+			go func() { first <- send(replicas[0]) }()
+			<-started
+			if rec := send(replicas[1]); rec.Code != http.StatusConflict {
+				t.Errorf("retry in flight on the other replica: status %d, want 409: %s", rec.Code, rec.Body)
+			}
+			close(release)
+			if rec := <-first; rec.Code != http.StatusCreated {
+				t.Fatalf("first request: status %d: %s", rec.Code, rec.Body)
+			}
+
+			rec := send(replicas[1])
+			if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" {
+				t.Errorf("retry once served: status %d, replayed %q", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+			}
+			if n := served.Load(); n != 1 {
+				t.Errorf("served %d times, want once", n)
+			}
+		})
+	}
+}
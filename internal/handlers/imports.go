@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// StartImport begins importing a repository from another VCS, e.g. {"vcs": "git", "vcs_url": "https://..."}
+func StartImport(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+
+		in := new(github.Import)
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if in.GetVCSURL() == "" {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("vcs_url is required"))
+			return
+		}
+
+		imp, _, err := svc.StartImport(r.Context(), owner, repo, in)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusCreated, imp)
+	}
+}
+
+// ImportProgress returns the status of the repository's source import
+func ImportProgress(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+
+		imp, _, err := svc.ImportProgress(r.Context(), owner, repo)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, imp)
+	}
+}
+
+// UpdateImport updates the credentials or project choice of a source import, restarting it
+func UpdateImport(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+
+		in := new(github.Import)
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		imp, _, err := svc.UpdateImport(r.Context(), owner, repo, in)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, imp)
+	}
+}
+
+// CancelImport stops the repository's source import
+func CancelImport(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+
+		_, err = svc.CancelImport(r.Context(), owner, repo)
+		if WriteError(w, err) {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ImportAuthors lists the commit authors found by the source import, for mapping onto GitHub users
+func ImportAuthors(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+
+		authors, _, err := svc.CommitAuthors(r.Context(), owner, repo)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, authors)
+	}
+}
+
+// MapImportAuthor updates an imported commit author, e.g. {"email": "...", "name": "..."}
+func MapImportAuthor(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		body := new(github.SourceImportAuthor)
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		// only the email and name of an author can be changed
+		author := &github.SourceImportAuthor{
+			Email: body.Email,
+			Name:  body.Name,
+		}
+		author, _, err = svc.MapCommitAuthor(r.Context(), owner, repo, id, author)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, author)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+// interactionTarget returns the owner and repository of the interaction limits, the repository being empty
+// for the org routes
+func interactionTarget(r *http.Request) (string, string) {
+	vars := mux.Vars(r)
+	if org, ok := vars["org"]; ok {
+		return org, ""
+	}
+	return vars["owner"], vars["repo"]
+}
+
+// GetInteractions returns the interaction restrictions currently in place
+func GetInteractions(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		owner, repo := interactionTarget(r)
+		restriction, _, err := svc.GetInteractions(r.Context(), owner, repo)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, restriction)
+	}
+}
+
+// SetInteractions limits interactions, e.g. {"limit": "collaborators_only", "expiry": "one_day"}
+func SetInteractions(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		body := new(githubsvc.InteractionRestriction)
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.GetLimit() == "" {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("limit is required"))
+			return
+		}
+
+		owner, repo := interactionTarget(r)
+		restriction, _, err := svc.SetInteractions(r.Context(), owner, repo, &githubsvc.InteractionRestriction{
+			Limit:  body.Limit,
+			Expiry: body.Expiry,
+		})
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, restriction)
+	}
+}
+
+// RemoveInteractions removes any interaction restrictions
+func RemoveInteractions(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		owner, repo := interactionTarget(r)
+		_, err = svc.RemoveInteractions(r.Context(), owner, repo)
+		if WriteError(w, err) {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
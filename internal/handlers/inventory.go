@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// InventoryConfig configures the inventory of the orgs' repositories: their metadata kept in a store and
+// refreshed in the background, for GET /v1/orgs/{org}/inventory to be served without calling GitHub
+type InventoryConfig struct {
+	Orgs []string `yaml:"orgs"`
+	// Interval is the time between two refreshes, saving the repositories updated since the last one,
+	// defaulting to 10m
+	Interval time.Duration `yaml:"interval"`
+	// FullInterval is the time between two refreshes walking every repository, dropping the deleted, renamed
+	// and transferred ones, defaulting to 24h
+	FullInterval time.Duration `yaml:"full_interval"`
+	// Driver is "sqlite" or "postgres", and DSN the SQLite database's path or the Postgres connection string, or
+	// "storage" for the shared storage, for the inventory to be served right after a restart; it's kept in memory
+	// without a driver
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+// InventoryRepo is the metadata of a repository kept in the inventory
+type InventoryRepo struct {
+	Org           string    `json:"org"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description,omitempty"`
+	DefaultBranch string    `json:"default_branch"`
+	Topics        []string  `json:"topics"`
+	Visibility    string    `json:"visibility"`
+	Archived      bool      `json:"archived"`
+	Fork          bool      `json:"fork"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// InventoryQuery selects the repositories of the org having the topic, the visibility, and the archived and fork
+// flags, when set, and whose name contains Name, ignoring the case
+type InventoryQuery struct {
+	Org        string
+	Topic      string
+	Visibility string
+	Archived   *bool
+	Fork       *bool
+	Name       string
+}
+
+// InventoryStore keeps the metadata of the orgs' repositories
+type InventoryStore interface {
+	// Save adds or replaces the repositories, seen by the refresh at the time
+	Save(ctx context.Context, repos []*InventoryRepo, seen time.Time) error
+	// Prune removes the org's repositories last seen before the time
+	Prune(ctx context.Context, org string, before time.Time) error
+	// List returns the selected repositories, by name
+	List(ctx context.Context, query InventoryQuery) ([]*InventoryRepo, error)
+	Close() error
+}
+
+// NewInventoryStore opens the configured store, creating its repo_inventory table when missing, or a store in
+// memory without a driver
+func NewInventoryStore(config InventoryConfig, storage *middleware.Database) (InventoryStore, error) {
+	if config.Driver == "" {
+		return &memoryInventory{repos: map[string]*seenRepo{}}, nil
+	}
+
+	db, err := middleware.OpenDatabase(config.Driver, config.DSN, storage)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Migrate(`CREATE TABLE IF NOT EXISTS repo_inventory (
+		repo TEXT PRIMARY KEY,
+		org TEXT NOT NULL,
+		name TEXT NOT NULL,
+		full_name TEXT NOT NULL,
+		description TEXT NOT NULL,
+		default_branch TEXT NOT NULL,
+		topics TEXT NOT NULL,
+		visibility TEXT NOT NULL,
+		archived BOOLEAN NOT NULL,
+		fork BOOLEAN NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		seen_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlInventory{db: db}, nil
+}
+
+// memoryInventory keeps the repositories in memory, by lowercase full name
+type memoryInventory struct {
+	mu    sync.RWMutex
+	repos map[string]*seenRepo
+}
+
+// seenRepo is a repository, last seen by a refresh at the time
+type seenRepo struct {
+	repo *InventoryRepo
+	seen time.Time
+}
+
+func (s *memoryInventory) Save(ctx context.Context, repos []*InventoryRepo, seen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, repo := range repos {
+		s.repos[strings.ToLower(repo.FullName)] = &seenRepo{repo: repo, seen: seen}
+	}
+	return nil
+}
+
+func (s *memoryInventory) Prune(ctx context.Context, org string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.repos {
+		if strings.EqualFold(entry.repo.Org, org) && entry.seen.Before(before) {
+			delete(s.repos, key)
+		}
+	}
+	return nil
+}
+
+func (s *memoryInventory) List(ctx context.Context, query InventoryQuery) ([]*InventoryRepo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var repos []*InventoryRepo
+	for _, entry := range s.repos {
+		if query.matches(entry.repo) {
+			repos = append(repos, entry.repo)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool { return strings.ToLower(repos[i].Name) < strings.ToLower(repos[j].Name) })
+	return repos, nil
+}
+
+func (s *memoryInventory) Close() error {
+	return nil
+}
+
+// matches reports whether the query selects the repository
+func (query InventoryQuery) matches(repo *InventoryRepo) bool {
+	if !strings.EqualFold(repo.Org, query.Org) ||
+		query.Visibility != "" && repo.Visibility != query.Visibility ||
+		query.Archived != nil && repo.Archived != *query.Archived ||
+		query.Fork != nil && repo.Fork != *query.Fork ||
+		!strings.Contains(strings.ToLower(repo.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	if query.Topic == "" {
+		return true
+	}
+	for _, topic := range repo.Topics {
+		if topic == query.Topic {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlInventory keeps the repositories in the repo_inventory table of a SQLite or Postgres database, by lowercase
+// full name, their topics comma separated and enclosed in commas to be matched with LIKE, e.g. ",go,api,"
+type sqlInventory struct {
+	db *middleware.Database
+}
+
+func (s *sqlInventory) Save(ctx context.Context, repos []*InventoryRepo, seen time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, repo := range repos {
+		_, err := tx.ExecContext(ctx, s.db.Bind(`INSERT INTO repo_inventory
+			(repo, org, name, full_name, description, default_branch, topics, visibility, archived, fork, updated_at, seen_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (repo) DO UPDATE SET name = excluded.name, full_name = excluded.full_name, description = excluded.description,
+			default_branch = excluded.default_branch, topics = excluded.topics, visibility = excluded.visibility,
+			archived = excluded.archived, fork = excluded.fork, updated_at = excluded.updated_at, seen_at = excluded.seen_at`),
+			strings.ToLower(repo.FullName), repo.Org, repo.Name, repo.FullName, repo.Description, repo.DefaultBranch,
+			","+strings.Join(repo.Topics, ",")+",", repo.Visibility, repo.Archived, repo.Fork, repo.UpdatedAt.UTC(), seen.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlInventory) Prune(ctx context.Context, org string, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, s.db.Bind(`DELETE FROM repo_inventory WHERE LOWER(org) = ? AND seen_at < ?`),
+		strings.ToLower(org), before.UTC())
+	return err
+}
+
+func (s *sqlInventory) List(ctx context.Context, query InventoryQuery) ([]*InventoryRepo, error) {
+	where := []string{"LOWER(org) = ?", "LOWER(name) LIKE ?"}
+	args := []interface{}{strings.ToLower(query.Org), "%" + strings.ToLower(query.Name) + "%"}
+	if query.Topic != "" {
+		where = append(where, "topics LIKE ?")
+		args = append(args, "%,"+query.Topic+",%")
+	}
+	if query.Visibility != "" {
+		where = append(where, "visibility = ?")
+		args = append(args, query.Visibility)
+	}
+	if query.Archived != nil {
+		where = append(where, "archived = ?")
+		args = append(args, *query.Archived)
+	}
+	if query.Fork != nil {
+		where = append(where, "fork = ?")
+		args = append(args, *query.Fork)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.db.Bind(`SELECT org, name, full_name, description, default_branch, topics,
+		visibility, archived, fork, updated_at FROM repo_inventory WHERE `+strings.Join(where, " AND ")+` ORDER BY LOWER(name)`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*InventoryRepo
+	for rows.Next() {
+		repo := &InventoryRepo{}
+		var topics string
+		if err := rows.Scan(&repo.Org, &repo.Name, &repo.FullName, &repo.Description, &repo.DefaultBranch, &topics,
+			&repo.Visibility, &repo.Archived, &repo.Fork, &repo.UpdatedAt); err != nil {
+			return nil, err
+		}
+		repo.Topics = []string{}
+		if topics = strings.Trim(topics, ","); topics != "" {
+			repo.Topics = strings.Split(topics, ",")
+		}
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+func (s *sqlInventory) Close() error {
+	return s.db.Close()
+}
+
+// RepoInventory refreshes the inventory of the orgs' repositories in the background, until the datastore is
+// closed
+type RepoInventory struct {
+	data   *Datastore
+	config InventoryConfig
+	store  InventoryStore
+
+	mu sync.Mutex
+	// refreshed is when each lowercase org was last refreshed, missing until its first refresh
+	refreshed map[string]time.Time
+}
+
+// StartInventory opens the configured store, closed by Close, and refreshes the orgs' repositories in it with
+// background priority, walking them all at first, until the datastore's background work is stopped
+func StartInventory(data *Datastore, config InventoryConfig) (*RepoInventory, error) {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Minute
+	}
+	if config.FullInterval <= 0 {
+		config.FullInterval = 24 * time.Hour
+	}
+	store, err := NewInventoryStore(config, data.storage)
+	if err != nil {
+		return nil, err
+	}
+	inventory := &RepoInventory{data: data, config: config, store: store, refreshed: map[string]time.Time{}}
+	for _, org := range config.Orgs {
+		go inventory.run(data.backgroundContext(), org)
+	}
+	return inventory, nil
+}
+
+// Close closes the store
+func (inv *RepoInventory) Close() error {
+	return inv.store.Close()
+}
+
+// Refreshed returns when the org was last refreshed, and whether it's inventoried at all
+func (inv *RepoInventory) Refreshed(org string) (time.Time, bool) {
+	for _, inventoried := range inv.config.Orgs {
+		if strings.EqualFold(inventoried, org) {
+			inv.mu.Lock()
+			defer inv.mu.Unlock()
+			return inv.refreshed[strings.ToLower(org)], true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (inv *RepoInventory) run(ctx context.Context, org string) {
+	// since is the newest update saved, the refreshes after the full ones only walking the repositories
+	// updated after it
+	var since, full time.Time
+	for {
+		start := time.Now()
+		after := since
+		if start.Sub(full) >= inv.config.FullInterval {
+			after = time.Time{}
+		}
+		newest, err := inv.refresh(ctx, org, after)
+		if err != nil {
+			slog.WarnContext(ctx, "refreshing the repository inventory failed", "org", org, "error", err)
+		} else {
+			if after.IsZero() {
+				full = start
+			}
+			if newest.After(since) {
+				since = newest
+			}
+			inv.mu.Lock()
+			inv.refreshed[strings.ToLower(org)] = start
+			inv.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(inv.config.Interval):
+		}
+	}
+}
+
+// refresh saves the org's repositories updated after the time, walking them most recently updated first, or
+// saves them all when the time is zero, pruning the others, and returns the newest update saved
+func (inv *RepoInventory) refresh(ctx context.Context, org string, after time.Time) (time.Time, error) {
+	svc, err := inv.data.ServiceForOwner(org)
+	if err != nil {
+		return after, err
+	}
+
+	seen := time.Now()
+	newest := after
+	// the full walks are by name, for the repositories updated meanwhile not to move across the pages
+	opt := &github.RepositoryListOptions{Sort: "full_name", ListOptions: github.ListOptions{PerPage: maxPerPage}}
+	if !after.IsZero() {
+		opt.Sort, opt.Direction = "updated", "desc"
+	}
+	for page := 1; ; page++ {
+		opt.Page = page
+		repos, resp, err := svc.ListRepos(ctx, org, opt)
+		if err != nil {
+			return after, err
+		}
+		var saved []*InventoryRepo
+		done := false
+		for _, repo := range repos {
+			updated := repo.GetUpdatedAt().Time
+			if !after.IsZero() && !updated.After(after) {
+				done = true
+				break
+			}
+			if updated.After(newest) {
+				newest = updated
+			}
+			saved = append(saved, inventoryRepo(org, repo))
+		}
+		if err := inv.store.Save(ctx, saved, seen); err != nil {
+			return after, err
+		}
+		if done || resp.NextPage == 0 {
+			break
+		}
+	}
+	if after.IsZero() {
+		return newest, inv.store.Prune(ctx, org, seen)
+	}
+	return newest, nil
+}
+
+// inventoryRepo returns the inventory metadata of the org's repository
+func inventoryRepo(org string, repo *github.Repository) *InventoryRepo {
+	visibility := "public"
+	if repo.GetPrivate() {
+		visibility = "private"
+	}
+	topics := repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	return &InventoryRepo{
+		Org:           org,
+		Name:          repo.GetName(),
+		FullName:      repo.GetFullName(),
+		Description:   repo.GetDescription(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Topics:        topics,
+		Visibility:    visibility,
+		Archived:      repo.GetArchived(),
+		Fork:          repo.GetFork(),
+		UpdatedAt:     repo.GetUpdatedAt().Time,
+	}
+}
+
+// GetInventory returns the org's repositories kept in the inventory, by name, selected by the topic, visibility
+// (public or private), archived, fork and name (contained in theirs) query parameters, without calling GitHub.
+// X-Inventory-Refreshed-At is the time of the last refresh. The inventory is only served with the proxy's
+// credentials, as it lists the repositories they see.
+func GetInventory(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org := mux.Vars(r)["org"]
+		if data.ownCredentials(r) {
+			middleware.WriteStatusError(w, http.StatusForbidden, errors.New("The inventory is only served with the proxy's credentials"))
+			return
+		}
+		refreshed, ok := data.inventory.Refreshed(org)
+		if !ok {
+			middleware.WriteStatusError(w, http.StatusNotFound, fmt.Errorf("%v isn't inventoried", org))
+			return
+		}
+
+		query := InventoryQuery{Org: org, Topic: r.URL.Query().Get("topic"), Name: r.URL.Query().Get("name")}
+		var err error
+		if query.Visibility, err = QueryChoice(r, "visibility", "public", "private"); middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		for param, flag := range map[string]**bool{"archived": &query.Archived, "fork": &query.Fork} {
+			if value := r.URL.Query().Get(param); value != "" {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("invalid %v %q, expecting true or false", param, value))
+					return
+				}
+				*flag = &b
+			}
+		}
+
+		repos, err := data.inventory.store.List(r.Context(), query)
+		if WriteError(w, err) {
+			return
+		}
+		if repos == nil {
+			if refreshed.IsZero() {
+				w.Header().Set("Retry-After", "10")
+				middleware.WriteStatusError(w, http.StatusServiceUnavailable, fmt.Errorf("The inventory of %v isn't refreshed yet", org))
+				return
+			}
+			repos = []*InventoryRepo{}
+		}
+		if !refreshed.IsZero() {
+			w.Header().Set("X-Inventory-Refreshed-At", refreshed.UTC().Format(time.RFC3339))
+		}
+
+		WriteJSON(w, r, http.StatusOK, repos)
+	}
+}
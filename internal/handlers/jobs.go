@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+// JobsConfig configures the requests served asynchronously with Prefer: respond-async
+type JobsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long the results of the jobs are kept once done, defaulting to 1h
+	TTL time.Duration `yaml:"ttl"`
+	// Store is "storage" to keep the jobs in the shared storage, for the replicas to serve the results of each
+	// other's jobs; they're kept in memory by default
+	Store string `yaml:"store"`
+}
+
+// Job is a request served in the background, whose result is the response to the request once done
+type Job struct {
+	ID string `json:"id"`
+	// Status is "running" or "done", the status of the response telling whether the request succeeded
+	Status    string       `json:"status"`
+	Method    string       `json:"method"`
+	Path      string       `json:"path"`
+	CreatedAt time.Time    `json:"created_at"`
+	DoneAt    *time.Time   `json:"done_at,omitempty"`
+	Result    *BatchResult `json:"result,omitempty"`
+
+	// consumer restricts the job to the credentials that submitted it
+	consumer string
+}
+
+// JobStore keeps the jobs until their results expire
+type JobStore interface {
+	Save(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id string) (*Job, bool)
+}
+
+// NewMemoryJobs returns a JobStore keeping the jobs in memory, for ttl once done
+func NewMemoryJobs(ttl time.Duration) JobStore {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &memoryJobs{ttl: ttl, jobs: map[string]*Job{}}
+}
+
+type memoryJobs struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func (s *memoryJobs) Save(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, done := range s.jobs {
+		if done.DoneAt != nil && time.Since(*done.DoneAt) > s.ttl {
+			delete(s.jobs, id)
+		}
+	}
+	saved := *job
+	s.jobs[job.ID] = &saved
+	return nil
+}
+
+func (s *memoryJobs) Get(ctx context.Context, id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || job.DoneAt != nil && time.Since(*job.DoneAt) > s.ttl {
+		return nil, false
+	}
+	found := *job
+	return &found, true
+}
+
+// NewSQLJobs returns a JobStore keeping the jobs in the jobs table of the database, created when missing, for
+// ttl once done
+func NewSQLJobs(db *middleware.Database, ttl time.Duration) (JobStore, error) {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	err := db.Migrate(`CREATE TABLE IF NOT EXISTS jobs (
+		id TEXT PRIMARY KEY,
+		consumer TEXT NOT NULL,
+		job TEXT NOT NULL,
+		expires_at TIMESTAMP
+	)`, `CREATE INDEX IF NOT EXISTS jobs_expires_at ON jobs (expires_at)`)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlJobs{db: db, ttl: ttl}, nil
+}
+
+// sqlJobs keeps the jobs as JSON, expiring once done for the ttl
+type sqlJobs struct {
+	db  *middleware.Database
+	ttl time.Duration
+
+	mu sync.Mutex
+	// pruned is when the expired jobs were last deleted
+	pruned time.Time
+}
+
+func (s *sqlJobs) Save(ctx context.Context, job *Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	var expires *time.Time
+	if job.DoneAt != nil {
+		at := job.DoneAt.Add(s.ttl).UTC()
+		expires = &at
+	}
+	_, err = s.db.ExecContext(ctx, s.db.Bind(`INSERT INTO jobs (id, consumer, job, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET job = excluded.job, expires_at = excluded.expires_at`),
+		job.ID, job.consumer, string(b), expires)
+	if err != nil {
+		return err
+	}
+
+	// the expired jobs are deleted once a minute rather than on every save
+	now := time.Now().UTC()
+	s.mu.Lock()
+	prune := now.Sub(s.pruned) > time.Minute
+	if prune {
+		s.pruned = now
+	}
+	s.mu.Unlock()
+	if prune {
+		_, err = s.db.ExecContext(ctx, s.db.Bind(`DELETE FROM jobs WHERE expires_at < ?`), now)
+	}
+	return err
+}
+
+func (s *sqlJobs) Get(ctx context.Context, id string) (*Job, bool) {
+	var consumer, b string
+	var expires sql.NullTime
+	err := s.db.QueryRowContext(ctx, s.db.Bind(`SELECT consumer, job, expires_at FROM jobs WHERE id = ?`), id).
+		Scan(&consumer, &b, &expires)
+	if err != nil || expires.Valid && time.Now().After(expires.Time) {
+		return nil, false
+	}
+	job := &Job{consumer: consumer}
+	if json.Unmarshal([]byte(b), job) != nil {
+		return nil, false
+	}
+	return job, true
+}
+
+// Async serves the requests sent with Prefer: respond-async in the background, with background priority,
+// answering them at once with 202 Accepted and the job, whose Location is polled for the result, e.g. of long
+// lists walked with ?all=true. The request timeout of the route's group still applies.
+func Async(store JobStore) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Prefer"), "respond-async") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the body is gone once the request is answered
+			body, err := io.ReadAll(r.Body)
+			if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+				return
+			}
+			b := make([]byte, 16)
+			rand.Read(b)
+			job := &Job{
+				ID:        hex.EncodeToString(b),
+				Status:    "running",
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				CreatedAt: time.Now(),
+				consumer:  consumerHash(r),
+			}
+			if WriteError(w, store.Save(r.Context(), job)) {
+				return
+			}
+
+			req := r.Clone(githubsvc.Background(context.WithoutCancel(r.Context())))
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.Header.Del("Prefer")
+			go func() {
+				done := *job
+				// the job is done even when its request panics, with a 500 result
+				defer func() {
+					if p := recover(); p != nil {
+						done.Result = panicResult(req, p)
+					}
+					now := time.Now()
+					done.Status, done.DoneAt = "done", &now
+					store.Save(req.Context(), &done)
+				}()
+				rec := &batchRecorder{header: http.Header{}, status: http.StatusOK}
+				rec.header.Set("X-Request-ID", middleware.RequestIDFrom(req.Context()))
+				next.ServeHTTP(rec, req)
+				done.Result = rec.result()
+			}()
+
+			w.Header().Set("Location", "/"+middleware.APIVersion(r.Context())+"/jobs/"+job.ID)
+			WriteJSON(w, r, http.StatusAccepted, job)
+		})
+	}
+}
+
+// GetJob returns the job of the consumer, with its result once done
+func GetJob(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := data.jobs.Get(r.Context(), mux.Vars(r)["id"])
+		if !ok || job.consumer != consumerHash(r) {
+			middleware.WriteStatusError(w, http.StatusNotFound, errors.New("Job not found"))
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, job)
+	}
+}
+
+// consumerHash identifies the consumer by its credentials
+func consumerHash(r *http.Request) string {
+	var session string
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		session = cookie.Value
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		r.Header.Get("X-API-Key"),
+		r.Header.Get("X-GitHub-Token"),
+		r.Header.Get("Authorization"),
+		session,
+	}, "\n")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAsyncJobs(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/panic" {
+			panic("boom")
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"path": r.URL.Path})
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{path: "/v1/ok", status: http.StatusCreated},
+		{path: "/v1/panic", status: http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			store := NewMemoryJobs(time.Minute)
+			resp, b := serve(Async(store)(handler), "POST", test.path, "{}", map[string]string{"Prefer": "respond-async"})
+			if resp.StatusCode != http.StatusAccepted {
+				t.Fatalf("status %d, want 202: %s", resp.StatusCode, b)
+			}
+			var accepted struct {
+				Data *Job `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(b), &accepted); err != nil {
+				t.Fatalf("%v: %s", err, b)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				job, ok := store.Get(context.Background(), accepted.Data.ID)
+				if !ok {
+					t.Fatal("job missing")
+				}
+				if job.Status == "done" {
+					if job.Result == nil || job.Result.Status != test.status {
+						t.Fatalf("result %+v, want status %d", job.Result, test.status)
+					}
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("job still %v", job.Status)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
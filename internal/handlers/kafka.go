@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaConfig configures publishing the webhook events to Kafka, when it has brokers
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers"`
+	// TopicPrefix prefixes the event types naming the topics, defaulting to "github.", e.g. "github.push"
+	TopicPrefix string `yaml:"topic_prefix"`
+	// Events are the patterns of the events published, defaulting to every event, e.g. "pull_request.opened"
+	Events []string `yaml:"events"`
+}
+
+// kafkaSink publishes the webhook events on a topic per event type, keyed by repository so that the events of
+// a repository stay in order
+type kafkaSink struct {
+	writer *kafka.Writer
+	prefix string
+}
+
+// NewKafkaSink returns a sink publishing the webhook events to the configured brokers
+func NewKafkaSink(config KafkaConfig) WebhookSink {
+	prefix := config.TopicPrefix
+	if prefix == "" {
+		prefix = "github."
+	}
+	return &kafkaSink{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(config.Brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+		prefix: prefix,
+	}
+}
+
+func (s *kafkaSink) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	key := event.Repo
+	if key == "" {
+		key = event.Org
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Topic: s.prefix + event.Type,
+		Key:   []byte(key),
+		Value: event.Raw,
+		Headers: []kafka.Header{
+			{Key: "X-GitHub-Event", Value: []byte(event.Type)},
+			{Key: "X-GitHub-Delivery", Value: []byte(event.DeliveryID)},
+			{Key: "action", Value: []byte(event.Action)},
+		},
+	})
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// LabelRule labels the opened issues and pull requests matching any of its patterns
+type LabelRule struct {
+	Labels []string `yaml:"labels"`
+	// Paths are the patterns of the files changed by the pull requests, e.g. "docs/*", a pattern ending with /
+	// matching the files of the directory and its subdirectories, e.g. "docs/"
+	Paths []string `yaml:"paths"`
+	// Title and Body are regular expressions, e.g. "(?i)\\bbug\\b"
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+}
+
+// labeler adds the labels of the rules matching the issues and pull requests opened
+type labeler struct {
+	data  *Datastore
+	rules []labelRule
+}
+
+type labelRule struct {
+	LabelRule
+	title, body *regexp.Regexp
+}
+
+// newLabeler compiles the rules' regular expressions and validates their patterns
+func newLabeler(data *Datastore, rules []LabelRule) (*labeler, error) {
+	l := &labeler{data: data}
+	for i, rule := range rules {
+		compiled := labelRule{LabelRule: rule}
+		var err error
+		if rule.Title != "" {
+			if compiled.title, err = regexp.Compile(rule.Title); err != nil {
+				return nil, fmt.Errorf("Invalid title of label rule %v: %v", i+1, err)
+			}
+		}
+		if rule.Body != "" {
+			if compiled.body, err = regexp.Compile(rule.Body); err != nil {
+				return nil, fmt.Errorf("Invalid body of label rule %v: %v", i+1, err)
+			}
+		}
+		for _, pattern := range rule.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Invalid path %q of label rule %v", pattern, i+1)
+			}
+		}
+		l.rules = append(l.rules, compiled)
+	}
+	return l, nil
+}
+
+// register handles the issues.opened and pull_request.opened events
+func (l *labeler) register(registry *WebhookRegistry) {
+	registry.Handle("issues.opened", l)
+	registry.Handle("pull_request.opened", l)
+}
+
+func (l *labeler) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	var number int
+	var title, body string
+	var files func() ([]string, error)
+	switch payload := event.Payload.(type) {
+	case *github.IssuesEvent:
+		number, title, body = payload.GetIssue().GetNumber(), payload.GetIssue().GetTitle(), payload.GetIssue().GetBody()
+	case *github.PullRequestEvent:
+		number, title, body = payload.GetNumber(), payload.GetPullRequest().GetTitle(), payload.GetPullRequest().GetBody()
+		files = func() ([]string, error) { return pullFiles(ctx, l.data, event.Repo, number) }
+	default:
+		return nil
+	}
+
+	var labels []string
+	var changed []string
+	listed := false
+	for _, rule := range l.rules {
+		matched := rule.title != nil && rule.title.MatchString(title) || rule.body != nil && rule.body.MatchString(body)
+		if !matched && len(rule.Paths) > 0 && files != nil {
+			// the pull request's files are only listed for the rules matching them
+			if !listed {
+				var err error
+				if changed, err = files(); err != nil {
+					return err
+				}
+				listed = true
+			}
+			matched = rule.matchesFiles(changed)
+		}
+		if matched {
+			labels = append(labels, rule.Labels...)
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+
+	owner, repo, _ := strings.Cut(event.Repo, "/")
+	svc, err := l.data.ServiceForOwner(owner)
+	if err != nil {
+		return err
+	}
+	_, _, err = svc.AddLabels(ctx, owner, repo, number, labels)
+	return err
+}
+
+// pullFiles returns the filenames of the pull request's changed files
+func pullFiles(ctx context.Context, data *Datastore, fullName string, number int) ([]string, error) {
+	owner, repo, _ := strings.Cut(fullName, "/")
+	svc, err := data.ServiceForOwner(owner)
+	if err != nil {
+		return nil, err
+	}
+	files, _, err := FetchAll(ctx, data, func(ctx context.Context, opt github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+		opt.PerPage = maxPerPage
+		return svc.ListPullFiles(ctx, owner, repo, number, &opt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.GetFilename()
+	}
+	return names, nil
+}
+
+// matchesFiles reports whether any of the files matches any of the rule's paths
+func (rule *labelRule) matchesFiles(files []string) bool {
+	for _, file := range files {
+		for _, pattern := range rule.Paths {
+			if strings.HasSuffix(pattern, "/") && strings.HasPrefix(file, pattern) {
+				return true
+			}
+			if matched, _ := path.Match(pattern, file); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
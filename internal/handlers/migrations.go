@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// MigrationRequest is the request body for starting an organization migration
+type MigrationRequest struct {
+	Repositories       []string `json:"repositories"`
+	LockRepositories   bool     `json:"lock_repositories"`
+	ExcludeAttachments bool     `json:"exclude_attachments"`
+}
+
+// StartMigration begins generating a migration archive of the given org repositories
+func StartMigration(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		org := mux.Vars(r)["org"]
+
+		body := new(MigrationRequest)
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(body.Repositories) == 0 {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("at least one repository is required"))
+			return
+		}
+
+		opt := &github.MigrationOptions{
+			LockRepositories:   body.LockRepositories,
+			ExcludeAttachments: body.ExcludeAttachments,
+		}
+		migration, _, err := svc.StartMigration(r.Context(), org, body.Repositories, opt)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusCreated, migration)
+	}
+}
+
+// ListMigrations returns a page of the most recent migrations of the org, as selected by the page and per_page
+// query parameters, or all of them with ?all=true
+func ListMigrations(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		org := mux.Vars(r)["org"]
+
+		if AllPages(r) {
+			WriteAll(w, r, data, func(ctx context.Context, opt github.ListOptions) ([]*github.Migration, *github.Response, error) {
+				return svc.ListMigrations(ctx, org, &opt)
+			})
+			return
+		}
+
+		opt, err := ListOptions(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		migrations, resp, err := svc.ListMigrations(r.Context(), org, &opt)
+		if WriteError(w, err) {
+			return
+		}
+
+		WritePagination(w, r, resp)
+
+		WriteJSON(w, r, http.StatusOK, migrations)
+	}
+}
+
+// MigrationStatus returns the migration, whose state is one of "pending", "exporting", "exported" or "failed"
+func MigrationStatus(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		org := vars["org"]
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		migration, _, err := svc.MigrationStatus(r.Context(), org, id)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, migration)
+	}
+}
+
+// MigrationArchive streams the exported migration archive through the proxy
+func MigrationArchive(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		org := vars["org"]
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		archive, size, err := svc.MigrationArchive(r.Context(), org, id)
+		if WriteError(w, err) {
+			return
+		}
+		defer archive.Close()
+
+		// the archives take longer to stream than the server's write timeout, so the write deadline is the
+		// migrations' request timeout instead
+		deadline := time.Time{}
+		if timeout := data.timeout("migrations"); timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		http.NewResponseController(w).SetWriteDeadline(deadline)
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"migration_archive_"+vars["id"]+".tar.gz\"")
+		if size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		if n, err := io.Copy(w, archive); err != nil {
+			// the status is sent, so the consumer only sees the archive cut short
+			slog.ErrorContext(r.Context(), "streaming the migration archive failed", "org", org, "id", id, "bytes", n, "error", err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMigrationArchiveErrors(t *testing.T) {
+	tests := []struct {
+		org    string
+		status int
+		error  string
+	}{
+		{org: "octocat", status: http.StatusNotFound, error: `"error":"Not Found"`},
+		{org: "private", status: http.StatusForbidden, error: `"error":"Must have admin rights to Repository."`},
+	}
+	for _, test := range tests {
+		t.Run(test.org, func(t *testing.T) {
+			router, _ := newTestRouter(t, "migrations.json", nil)
+			resp, body := serve(router, "GET", "/v1/orgs/"+test.org+"/migrations/1/archive", "", nil)
+			if resp.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, test.status, body)
+			}
+			if !strings.Contains(body, test.error) {
+				t.Errorf("body missing %v: %s", test.error, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSConfig configures publishing the webhook events to NATS, when it has a URL
+type NATSConfig struct {
+	URL string `yaml:"url"`
+	// SubjectPrefix prefixes the event types naming the subjects, defaulting to "github.", e.g. "github.push"
+	SubjectPrefix string `yaml:"subject_prefix"`
+	// Events are the patterns of the events published, defaulting to every event, e.g. "pull_request.opened"
+	Events []string `yaml:"events"`
+}
+
+// natsSink publishes the webhook events on a subject per event type
+type natsSink struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATSSink connects to the NATS server, reconnecting whenever the connection is lost
+func NewNATSSink(config NATSConfig) (WebhookSink, error) {
+	prefix := config.SubjectPrefix
+	if prefix == "" {
+		prefix = "github."
+	}
+	conn, err := nats.Connect(config.URL, nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &natsSink{conn: conn, prefix: prefix}, nil
+}
+
+func (s *natsSink) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	msg := nats.NewMsg(s.prefix + event.Type)
+	msg.Data = event.Raw
+	msg.Header.Set("X-GitHub-Event", event.Type)
+	msg.Header.Set("X-GitHub-Delivery", event.DeliveryID)
+	if err := s.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// the message is only known to have reached the server once flushed
+	return s.conn.FlushWithContext(ctx)
+}
+
+func (s *natsSink) Close() error {
+	return s.conn.Drain()
+}
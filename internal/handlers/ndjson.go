@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// ndjsonType is the media type of the streamed lists, one JSON item per line
+const ndjsonType = "application/x-ndjson"
+
+// WantsNDJSON reports whether the consumer asked for the list to be streamed with Accept: application/x-ndjson
+func WantsNDJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if strings.TrimSpace(strings.Split(accepted, ";")[0]) == ndjsonType {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamNDJSON writes the items of every page of a list, one JSON item per line, flushing each page as soon as
+// it's fetched rather than buffering the whole list. fetch returns the items of the page and the token of the
+// next one, the first page having an empty token and the last page returning an empty next token.
+// As the status is sent with the first page, an error fetching a later one is written as a last {"error": ...}
+// line. The items are pruned to the fields selected by the ?fields= query parameter.
+func StreamNDJSON[T any](w http.ResponseWriter, r *http.Request, fetch func(ctx context.Context, page string) ([]T, string, error)) {
+	items, next, err := fetch(r.Context(), "")
+	if WriteError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonType)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	rc := http.NewResponseController(w)
+	for {
+		for _, item := range items {
+			if err := enc.Encode(SelectFields(r, item)); err != nil {
+				// the consumer went away
+				return
+			}
+		}
+		rc.Flush()
+		if next == "" {
+			return
+		}
+
+		items, next, err = fetch(r.Context(), next)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "streaming failed", "error", err)
+			enc.Encode(&middleware.ErrorBody{Error: err.Error(), RequestID: w.Header().Get("X-Request-ID")})
+			return
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "session"
+	stateCookie   = "oauth_state"
+	// sessionTTL is how long the users stay logged in, unless their token expires first
+	sessionTTL = 24 * time.Hour
+)
+
+// oauthFlow implements the OAuth web application flow, keeping the token of each logged in user by session
+type oauthFlow struct {
+	config *oauth2.Config
+
+	mu       sync.RWMutex
+	sessions map[string]*oauthSession
+	// pruned is when the expired sessions were last deleted
+	pruned time.Time
+}
+
+// oauthSession is the client of a logged in user, until the session expires
+type oauthSession struct {
+	client  *github.Client
+	expires time.Time
+}
+
+// NewOAuth returns the OAuth web flow for the OAuth app with the given credentials and callback url
+func NewOAuth(clientID, clientSecret, redirectURL string, scopes []string) *oauthFlow {
+	return &oauthFlow{
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+			Endpoint:     githubsvc.OAuthEndpoint(),
+		},
+		sessions: map[string]*oauthSession{},
+	}
+}
+
+// clientFor returns the client of the user logged in with the request's session, or nil if there's none
+func (o *oauthFlow) clientFor(r *http.Request) *github.Client {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	session := o.sessions[cookie.Value]
+	if session == nil || time.Now().After(session.expires) {
+		return nil
+	}
+	return session.client
+}
+
+// login starts the session of the client, deleting the expired sessions once a minute rather than on every login
+func (o *oauthFlow) login(id string, session *oauthSession) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	if now.Sub(o.pruned) > time.Minute {
+		o.pruned = now
+		for id, session := range o.sessions {
+			if now.After(session.expires) {
+				delete(o.sessions, id)
+			}
+		}
+	}
+	o.sessions[id] = session
+}
+
+// logout ends the session
+func (o *oauthFlow) logout(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.sessions, id)
+}
+
+// Login redirects the user to GitHub to authorize the proxy
+func Login(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := randomString()
+		if WriteError(w, err) {
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     stateCookie,
+			Value:    state,
+			Path:     "/auth",
+			Expires:  time.Now().Add(10 * time.Minute),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, data.OAuth.config.AuthCodeURL(state), http.StatusFound)
+	}
+}
+
+// Callback exchanges the authorization code for the user's token, and starts the user's session
+func Callback(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := r.Cookie(stateCookie)
+		if err != nil || state.Value == "" || state.Value != r.URL.Query().Get("state") {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("Invalid OAuth state"))
+			return
+		}
+		if msg := r.URL.Query().Get("error_description"); msg != "" {
+			middleware.WriteStatusError(w, http.StatusUnauthorized, errors.New(msg))
+			return
+		}
+
+		ctx := githubsvc.OutboundContext(r.Context())
+		token, err := data.OAuth.config.Exchange(ctx, r.URL.Query().Get("code"))
+		if middleware.WriteStatusError(w, http.StatusUnauthorized, err) {
+			return
+		}
+
+		// the session's client outlives the request, and the datastore replaced on reload
+		client := githubsvc.NewClient(data.OAuth.config.Client(context.WithoutCancel(data.Context), token))
+		user, _, err := client.Users.Get(ctx, "")
+		if WriteError(w, err) {
+			return
+		}
+
+		id, err := randomString()
+		if WriteError(w, err) {
+			return
+		}
+		expires := time.Now().Add(sessionTTL)
+		if !token.Expiry.IsZero() && token.RefreshToken == "" && token.Expiry.Before(expires) {
+			expires = token.Expiry
+		}
+		data.OAuth.login(id, &oauthSession{client: client, expires: expires})
+
+		http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/auth", MaxAge: -1})
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    id,
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		scope, _ := token.Extra("scope").(string)
+		WriteJSON(w, r, http.StatusOK, map[string]interface{}{
+			"login":  user.GetLogin(),
+			"scopes": strings.Split(scope, ","),
+		})
+	}
+}
+
+// Logout ends the user's session
+func Logout(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			data.OAuth.logout(cookie.Value)
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// randomString returns a random hex string suitable for states and session ids
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
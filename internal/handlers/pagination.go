@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/google/go-github/github"
+)
+
+// PaginationConfig limits walking every page of a list
+type PaginationConfig struct {
+	// MaxPages is the number of pages of 100 items walked at most, defaulting to 10
+	MaxPages int `yaml:"max_pages"`
+	// Concurrency is the number of pages fetched at once, defaulting to 4
+	Concurrency int `yaml:"concurrency"`
+}
+
+// maxPerPage is the largest page GitHub serves
+const maxPerPage = 100
+
+// pageHeaders are the response headers holding the numbers of the neighbouring pages, by Link relation
+var pageHeaders = []struct{ rel, header string }{
+	{"first", "X-First-Page"},
+	{"prev", "X-Prev-Page"},
+	{"next", "X-Next-Page"},
+	{"last", "X-Last-Page"},
+}
+
+// ListOptions returns the page and per_page query parameters of the request, passed through to GitHub
+func ListOptions(r *http.Request) (github.ListOptions, error) {
+	var opt github.ListOptions
+	for param, value := range map[string]*int{"page": &opt.Page, "per_page": &opt.PerPage} {
+		s := r.URL.Query().Get(param)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return opt, fmt.Errorf("invalid %v %q, expecting a positive number", param, s)
+		}
+		*value = n
+	}
+	if opt.PerPage > maxPerPage {
+		return opt, fmt.Errorf("invalid per_page %v, expecting at most %v", opt.PerPage, maxPerPage)
+	}
+	return opt, nil
+}
+
+// QueryChoice returns the query parameter of the request, which must be one of the choices when set, e.g. the
+// sort and filters of a list passed through to GitHub
+func QueryChoice(r *http.Request, param string, choices ...string) (string, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return "", nil
+	}
+	for _, choice := range choices {
+		if value == choice {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %v %q, expecting one of %v", param, value, strings.Join(choices, ", "))
+}
+
+// WritePagination translates GitHub's pagination of the response into a Link header pointing to the proxy's
+// pages, and the X-First-Page, X-Prev-Page, X-Next-Page and X-Last-Page headers enveloped by WriteJSON. It's
+// called before writing the body.
+func WritePagination(w http.ResponseWriter, r *http.Request, resp *github.Response) {
+	pages := map[string]int{"first": resp.FirstPage, "prev": resp.PrevPage, "next": resp.NextPage, "last": resp.LastPage}
+
+	var links []string
+	for _, page := range pageHeaders {
+		n := pages[page.rel]
+		if n == 0 {
+			continue
+		}
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(n))
+		links = append(links, fmt.Sprintf(`<%v?%v>; rel="%v"`, r.URL.Path, query.Encode(), page.rel))
+		w.Header().Set(page.header, strconv.Itoa(n))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// AllPages reports whether the consumer asked for every page of the list with ?all=true
+func AllPages(r *http.Request) bool {
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	return all
+}
+
+// FetchAll walks every page of a list, fetching the pages after the first one concurrently on the datastore's
+// workers, and returns their items in order. truncated is set when the list has more pages than the configured
+// maximum, or than the calls left of the token's rate limit as reported by the first page, which are left out.
+func FetchAll[T any](ctx context.Context, data *Datastore, fetch func(ctx context.Context, opt github.ListOptions) ([]T, *github.Response, error)) (items []T, truncated bool, err error) {
+	truncated, err = walkPages(ctx, data, fetch, func(page []T, _ bool) error {
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return items, truncated, nil
+}
+
+// walkPages walks every page of a list like FetchAll, handing the items of each page to emit in order. At most
+// the configured concurrency of pages are fetched ahead of the one emitted, for the items of the pages emitted to
+// be released meanwhile. emit is told whether the list is truncated as far as known yet, and the walk stops with
+// its error, cancelling the pages in flight. The walk's truncation is returned once done.
+func walkPages[T any](ctx context.Context, data *Datastore, fetch func(ctx context.Context, opt github.ListOptions) ([]T, *github.Response, error), emit func(items []T, truncated bool) error) (truncated bool, err error) {
+	config := data.pagination
+	if config.MaxPages <= 0 {
+		config.MaxPages = 10
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 4
+	}
+
+	first, resp, err := fetch(ctx, github.ListOptions{Page: 1, PerPage: maxPerPage})
+	if err != nil {
+		return false, err
+	}
+	if resp.LastPage == 0 && resp.NextPage != 0 {
+		// without a last page, the pages are walked one after the other
+		items := first
+		for page := 2; ; page++ {
+			if err := emit(items, false); err != nil {
+				return false, err
+			}
+			if resp.NextPage == 0 {
+				return false, nil
+			}
+			if page > config.MaxPages || pageBudget(ctx, resp) == 0 {
+				return true, nil
+			}
+			if items, resp, err = fetch(ctx, github.ListOptions{Page: page, PerPage: maxPerPage}); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	last := resp.LastPage
+	if last > config.MaxPages {
+		last, truncated = config.MaxPages, true
+	}
+	if budget := pageBudget(ctx, resp); last-1 > budget {
+		last, truncated = budget+1, true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pages := make([][]T, last+1)
+	errs := make([]error, last+1)
+	fetched := make([]chan struct{}, last+1)
+	g, gctx := data.workers.Group(ctx, "pagination", config.Concurrency)
+	launched := 1
+	// launch fetches the pages up to the given one not fetched yet
+	launch := func(upto int) {
+		for ; launched < min(upto, last); launched++ {
+			page := launched + 1
+			fetched[page] = make(chan struct{})
+			g.Go(func() error {
+				defer close(fetched[page])
+				pages[page], _, errs[page] = fetch(gctx, github.ListOptions{Page: page, PerPage: maxPerPage})
+				return errs[page]
+			})
+		}
+	}
+
+	launch(1 + config.Concurrency)
+	err = emit(first, truncated)
+	for page := 2; err == nil && page <= last; page++ {
+		launch(page + config.Concurrency)
+		select {
+		case <-fetched[page]:
+		case <-gctx.Done():
+			if err = g.Wait(); err == nil {
+				err = ctx.Err()
+			}
+			return false, err
+		}
+		if err = errs[page]; err != nil {
+			break
+		}
+		err = emit(pages[page], truncated)
+		pages[page] = nil
+	}
+	cancel()
+	if werr := g.Wait(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		return false, err
+	}
+	return truncated, nil
+}
+
+// pageBudget returns the number of pages the walk can still fetch by the rate limit of the latest response, or
+// every one when GitHub didn't report it
+func pageBudget(ctx context.Context, resp *github.Response) int {
+	if resp.Rate.Limit == 0 {
+		return math.MaxInt
+	}
+	return githubsvc.CallBudget(ctx, resp.Rate.Remaining)
+}
+
+// WriteTruncated flags the lists cut at the maximum number of pages with X-Truncated: true
+func WriteTruncated(w http.ResponseWriter, truncated bool) {
+	if truncated {
+		w.Header().Set("X-Truncated", "true")
+	}
+}
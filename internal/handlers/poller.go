@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/go-github/github"
+)
+
+// pollPages is the number of pages of 100 events walked for the new ones, the Events API serving the last 300
+const pollPages = 3
+
+// PollConfig configures polling the orgs' events from the Events API, for the orgs whose webhooks can't be
+// configured
+type PollConfig struct {
+	Orgs []string `yaml:"orgs"`
+	// Interval is the time between two polls, defaulting to 1m, or GitHub's X-Poll-Interval when longer
+	Interval time.Duration `yaml:"interval"`
+}
+
+// StartPolling polls the orgs' events until the datastore's background work is stopped, dispatching the new ones to the pipeline
+// like the webhooks. The events before the first poll aren't dispatched.
+func StartPolling(data *Datastore, config PollConfig) {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	for _, org := range config.Orgs {
+		p := &orgPoller{data: data, org: org, interval: interval}
+		go p.run(data.backgroundContext())
+	}
+}
+
+// orgPoller polls an org's events, conditionally on the ETag of the last poll
+type orgPoller struct {
+	data     *Datastore
+	org      string
+	interval time.Duration
+
+	etag string
+	// last is the ID of the last event dispatched, 0 until the first poll
+	last int64
+}
+
+func (p *orgPoller) run(ctx context.Context) {
+	for {
+		wait := p.interval
+		if pollInterval, err := p.poll(ctx); err != nil {
+			slog.WarnContext(ctx, "polling events failed", "org", p.org, "error", err)
+		} else if pollInterval > wait {
+			wait = pollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// poll dispatches the events since the last poll, oldest first, returning GitHub's poll interval. The ETag
+// and the last event are kept together once all the pages are listed, for a failed page to be walked again
+// at the next poll rather than answered 304.
+func (p *orgPoller) poll(ctx context.Context) (time.Duration, error) {
+	svc, err := p.data.ServiceForOwner(p.org)
+	if err != nil {
+		return 0, err
+	}
+
+	var fresh []*github.Event
+	var interval time.Duration
+	var latest string
+	for page := 1; page <= pollPages; page++ {
+		etag := ""
+		if page == 1 {
+			etag = p.etag
+		}
+		events, resp, err := svc.ListOrgEvents(ctx, p.org, etag, &github.ListOptions{Page: page, PerPage: maxPerPage})
+		if resp != nil && page == 1 {
+			seconds, _ := strconv.Atoi(resp.Header.Get("X-Poll-Interval"))
+			interval = time.Duration(seconds) * time.Second
+			if resp.StatusCode == 304 {
+				return interval, nil
+			}
+			latest = resp.Header.Get("ETag")
+		}
+		if err != nil {
+			return interval, err
+		}
+
+		seen := false
+		for _, event := range events {
+			id, _ := strconv.ParseInt(event.GetID(), 10, 64)
+			if id <= p.last {
+				seen = true
+				break
+			}
+			fresh = append(fresh, event)
+		}
+		if seen || p.last == 0 || resp.NextPage == 0 {
+			break
+		}
+	}
+	p.etag = latest
+	if len(fresh) == 0 {
+		return interval, nil
+	}
+
+	first := p.last == 0
+	p.last, _ = strconv.ParseInt(fresh[0].GetID(), 10, 64)
+	if first {
+		return interval, nil
+	}
+	for i := len(fresh) - 1; i >= 0; i-- {
+		if err := p.data.webhooks.Dispatch(ctx, polledEvent(fresh[i])); err != nil {
+			slog.WarnContext(ctx, "dispatching polled event failed", "org", p.org, "id", fresh[i].GetID(), "error", err)
+		}
+	}
+	return interval, nil
+}
+
+// polledEvent returns the webhook event of the Events API's event, e.g. of type "pull_request" for a
+// PullRequestEvent. Its payload is the Events API's, which lacks e.g. the repository and the sender.
+func polledEvent(event *github.Event) *WebhookEvent {
+	raw := []byte(event.GetRawPayload())
+	polled := &WebhookEvent{
+		Type:       eventType(event.GetType()),
+		DeliveryID: "event-" + event.GetID(),
+		Repo:       event.GetRepo().GetName(),
+		Org:        event.GetOrg().GetLogin(),
+		Raw:        raw,
+	}
+	var fields struct {
+		Action string `json:"action"`
+	}
+	json.Unmarshal(raw, &fields)
+	polled.Action = fields.Action
+	polled.Payload, _ = parsePayload(polled.Type, raw)
+	if polled.Payload == nil {
+		polled.Payload = json.RawMessage(raw)
+	}
+	return polled
+}
+
+// eventType returns the webhook type of the Events API's type, e.g. "pull_request_review" for
+// "PullRequestReviewEvent"
+func eventType(apiType string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSuffix(apiType, "Event") {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+)
+
+// token rotation strategies of a token pool
+const (
+	RoundRobin    = "round-robin"
+	LeastDepleted = "least-depleted"
+)
+
+// NewPool function, initiates and returns a Github datastore instance rotating between several tokens,
+// multiplying the available rate limit. The strategy is either RoundRobin or LeastDepleted, which picks
+// the token with the most requests remaining in its rate limit window. Only the reads rotate: the writes are all
+// sent with the first token, for the comments, reviews and labels to be authored by the same user.
+func NewPool(authTokens []string, strategy string) (*Datastore, error) {
+	if len(authTokens) == 0 {
+		return nil, errors.New("Token pool is empty")
+	}
+	switch strategy {
+	case "":
+		strategy = RoundRobin
+	case RoundRobin, LeastDepleted:
+	default:
+		return nil, fmt.Errorf("Unknown token rotation strategy %q", strategy)
+	}
+
+	pool := &tokenPool{
+		strategy: strategy,
+		base:     githubsvc.OutboundTransport,
+	}
+	for _, token := range authTokens {
+		pool.tokens = append(pool.tokens, &pooledToken{token: token, remaining: -1})
+	}
+
+	client := githubsvc.NewClient(&http.Client{Transport: pool})
+	ctx, cancel := context.WithCancel(githubsvc.OutboundContext(context.Background()))
+
+	return &Datastore{
+		Context: ctx,
+		cancel:  cancel,
+		Client:  client,
+		Service: client.Git,
+	}, nil
+}
+
+// tokenPool authorizes each outbound request with one of its tokens
+type tokenPool struct {
+	tokens   []*pooledToken
+	strategy string
+	next     uint32
+	base     http.RoundTripper
+}
+
+// pooledToken tracks the rate limit of a token as reported by its latest response
+type pooledToken struct {
+	token string
+
+	mu sync.Mutex
+	// remaining is -1 until the first response is seen
+	remaining int
+	reset     time.Time
+}
+
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := p.tokens[0]
+	if req.Method == "GET" || req.Method == "HEAD" {
+		t = p.pick()
+	}
+
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+t.token)
+
+	resp, err := p.base.RoundTrip(req)
+	if err == nil {
+		t.update(resp)
+	}
+	return resp, err
+}
+
+// pick returns the token to use next
+func (p *tokenPool) pick() *pooledToken {
+	if p.strategy == RoundRobin {
+		n := atomic.AddUint32(&p.next, 1)
+		return p.tokens[int(n-1)%len(p.tokens)]
+	}
+
+	best, bestRemaining := p.tokens[0], -2
+	for _, t := range p.tokens {
+		if remaining := t.estimate(); remaining > bestRemaining {
+			best, bestRemaining = t, remaining
+		}
+	}
+	return best
+}
+
+// estimate returns the remaining requests of the token, treating unknown or reset limits as unused
+func (t *pooledToken) estimate() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.remaining < 0 || time.Now().After(t.reset) {
+		return math.MaxInt32
+	}
+	return t.remaining
+}
+
+func (t *pooledToken) update(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+	t.mu.Unlock()
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// authorizationsTransport records the Authorization header of each call
+type authorizationsTransport struct {
+	authorizations []string
+}
+
+func (t *authorizationsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.authorizations = append(t.authorizations, req.Header.Get("Authorization"))
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func TestTokenPoolRotatesReads(t *testing.T) {
+	tests := []struct {
+		method string
+		want   []string
+	}{
+		{method: "GET", want: []string{"token one", "token two", "token three", "token one"}},
+		{method: "HEAD", want: []string{"token one", "token two", "token three", "token one"}},
+		{method: "POST", want: []string{"token one", "token one", "token one", "token one"}},
+		{method: "PATCH", want: []string{"token one", "token one", "token one", "token one"}},
+		{method: "DELETE", want: []string{"token one", "token one", "token one", "token one"}},
+	}
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			base := &authorizationsTransport{}
+			pool := &tokenPool{strategy: RoundRobin, base: base}
+			for _, token := range []string{"one", "two", "three"} {
+				pool.tokens = append(pool.tokens, &pooledToken{token: token, remaining: -1})
+			}
+			for range test.want {
+				req, _ := http.NewRequest(test.method, "https://api.github.com/repos/octocat/hello-world/issues/1/comments", nil)
+				resp, err := pool.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+			if strings.Join(base.authorizations, ",") != strings.Join(test.want, ",") {
+				t.Errorf("authorized with %v, want %v", base.authorizations, test.want)
+			}
+		})
+	}
+}
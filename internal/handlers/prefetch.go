@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+)
+
+// PrefetchConfig configures the routes refreshed in the background, for dashboards to be served from the cache.
+// The cache must be enabled for their route groups, with a TTL longer than the interval.
+type PrefetchConfig struct {
+	// Interval is the time between two refreshes
+	Interval time.Duration `yaml:"interval"`
+	// Paths are the GET routes refreshed, e.g. "/v1/octocat/repos/count"
+	Paths []string `yaml:"paths"`
+	// APIKey authenticates the refreshes, when the proxy requires API keys
+	APIKey string `yaml:"api_key"`
+}
+
+// StartPrefetch refreshes the configured routes of the handler every interval, with background priority, until
+// the returned function is called
+func StartPrefetch(handler http.Handler, config PrefetchConfig) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	if config.Interval <= 0 || len(config.Paths) == 0 {
+		return cancel
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+		for {
+			for _, path := range config.Paths {
+				prefetch(ctx, handler, path, config.APIKey)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
+
+// prefetch serves a GET of the path, skipping the cached response to replace it
+func prefetch(ctx context.Context, handler http.Handler, path, apiKey string) {
+	req, err := http.NewRequestWithContext(githubsvc.Background(ctx), "GET", path, nil)
+	if err != nil {
+		slog.Error("prefetch failed", "path", path, "error", err)
+		return
+	}
+	req.RemoteAddr = "prefetch"
+	req.Header.Set("Cache-Control", "no-cache")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	w := &discardWriter{header: http.Header{}, status: http.StatusOK}
+	handler.ServeHTTP(w, req)
+	if w.status >= 400 {
+		slog.Warn("prefetch failed", "path", path, "status", w.status)
+	}
+}
+
+// discardWriter records the status of a response, discarding its body
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+// projectsQuery lists the projects (v2) of an org, aliasing the fields to the snake case of the REST API
+const projectsQuery = `query($org: String!, $first: Int, $after: String, $last: Int, $before: String) {
+  organization(login: $org) {
+    projectsV2(first: $first, after: $after, last: $last, before: $before) {
+      ` + pageInfoFields + `
+      nodes {
+        id number title url closed public
+        short_description: shortDescription created_at: createdAt updated_at: updatedAt
+        items { total_count: totalCount }
+      }
+    }
+  }
+}`
+
+// ListProjects returns a page of the org's projects. The opaque cursors of the neighbouring pages are returned
+// in the X-Next-Cursor and X-Prev-Cursor headers, and passed back as the cursor query parameter.
+func ListProjects(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		variables, err := ConnectionPage(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		variables["org"] = mux.Vars(r)["org"]
+
+		var result struct {
+			Organization struct {
+				ProjectsV2 struct {
+					PageInfo PageInfo          `json:"pageInfo"`
+					Nodes    []json.RawMessage `json:"nodes"`
+				} `json:"projectsV2"`
+			} `json:"organization"`
+		}
+		_, err = svc.GraphQL(r.Context(), projectsQuery, variables, &result)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteCursors(w, result.Organization.ProjectsV2.PageInfo)
+		WriteJSON(w, r, http.StatusOK, result.Organization.ProjectsV2.Nodes)
+	}
+}
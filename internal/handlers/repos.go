@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// countQualifiers are the search qualifiers counting the owner's repositories of each type
+var countQualifiers = map[string]string{
+	"public":  "is:public fork:true",
+	"private": "is:private fork:true",
+	"forks":   "fork:only",
+	"sources": "fork:false",
+}
+
+// GetCount returns the number of repositories of the owner, optionally of the type given by the type query
+// parameter: public, private, forks or sources. The total is the number of the last page of one repository,
+// or the search API's total for the types the owner's list can't be filtered by, orgs and users alike.
+func GetCount(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+
+		kind, err := QueryChoice(r, "type", "public", "private", "forks", "sources")
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		if kind != "" {
+			query := fmt.Sprintf("user:%v %v", owner, countQualifiers[kind])
+			result, _, err := svc.SearchRepos(r.Context(), query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+			if WriteError(w, err) {
+				return
+			}
+
+			WriteJSON(w, r, http.StatusOK, result.GetTotal())
+			return
+		}
+
+		repos, resp, err := svc.ListRepos(r.Context(), owner, &github.RepositoryListOptions{ListOptions: github.ListOptions{PerPage: 1}})
+		if WriteError(w, err) {
+			return
+		}
+
+		count := len(repos)
+		if resp.LastPage > 0 {
+			count = resp.LastPage
+		}
+		WriteJSON(w, r, http.StatusOK, count)
+	}
+}
+
+// CountIssues returns the number of issues or pull requests of the repository, as selected by the kind search
+// qualifier, in the state given by the state query parameter: open (the default), closed or all
+func CountIssues(data *Datastore, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+
+		state, err := QueryChoice(r, "state", "open", "closed", "all")
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		query := fmt.Sprintf("repo:%v/%v %v", vars["owner"], vars["repo"], kind)
+		if state != "all" {
+			if state == "" {
+				state = "open"
+			}
+			query += " is:" + state
+		}
+
+		result, _, err := svc.SearchIssues(r.Context(), query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, result.GetTotal())
+	}
+}
+
+// CountStargazers returns the number of stargazers of the repository
+func CountStargazers(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+
+		repo, _, err := svc.GetRepo(r.Context(), vars["owner"], vars["repo"])
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, repo.GetStargazersCount())
+	}
+}
+
+// ListRepos returns a page of the owner's repositories, as selected by the page and per_page query parameters,
+// all of them with ?all=true, or streams all of them page by page with Accept: application/x-ndjson. They're
+// filtered and sorted by the type, sort and direction query parameters.
+func ListRepos(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		owner := mux.Vars(r)["owner"]
+
+		filter, err := repoListOptions(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		list := func(ctx context.Context, opt github.ListOptions) ([]*github.Repository, *github.Response, error) {
+			filtered := *filter
+			filtered.ListOptions = opt
+			return svc.ListRepos(ctx, owner, &filtered)
+		}
+
+		if WantsNDJSON(r) {
+			StreamNDJSON(w, r, func(ctx context.Context, page string) ([]*github.Repository, string, error) {
+				opt := github.ListOptions{PerPage: maxPerPage}
+				opt.Page, _ = strconv.Atoi(page)
+				repos, resp, err := list(ctx, opt)
+				if err != nil || resp.NextPage == 0 {
+					return repos, "", err
+				}
+				return repos, strconv.Itoa(resp.NextPage), nil
+			})
+			return
+		}
+
+		if AllPages(r) {
+			WriteAll(w, r, data, list)
+			return
+		}
+
+		opt, err := ListOptions(r)
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		repos, resp, err := list(r.Context(), opt)
+		if WriteError(w, err) {
+			return
+		}
+
+		WritePagination(w, r, resp)
+		WriteJSON(w, r, http.StatusOK, repos)
+	}
+}
+
+// repoListOptions returns the type, sort and direction query parameters of the request, passed through to GitHub
+func repoListOptions(r *http.Request) (*github.RepositoryListOptions, error) {
+	opt := &github.RepositoryListOptions{}
+	var err error
+	if opt.Type, err = QueryChoice(r, "type", "all", "owner", "member", "public", "private", "forks", "sources"); err != nil {
+		return nil, err
+	}
+	if opt.Sort, err = QueryChoice(r, "sort", "created", "updated", "pushed", "full_name"); err != nil {
+		return nil, err
+	}
+	if opt.Direction, err = QueryChoice(r, "direction", "asc", "desc"); err != nil {
+		return nil, err
+	}
+	return opt, nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+const (
+	// maxStatsRepos is the most repositories of a stats request
+	maxStatsRepos = 100
+	// statsChunk is the repositories aliased in each GraphQL query, kept well under GitHub's node limits
+	statsChunk = 25
+)
+
+// repoStatsFragment selects the stats of a repository, aliasing the fields to the snake case of the REST API
+const repoStatsFragment = `fragment repoStats on Repository {
+  full_name: nameWithOwner
+  stargazers_count: stargazerCount
+  issues(states: OPEN) { totalCount }
+  pullRequests(states: OPEN) { totalCount }
+  latestRelease { tag_name: tagName name published_at: publishedAt html_url: url }
+  defaultBranchRef { name }
+}`
+
+// RepoStats are the stats of a repository, or the error fetching them
+type RepoStats struct {
+	Repo             string       `json:"repo"`
+	Stars            int          `json:"stargazers_count"`
+	OpenIssues       int          `json:"open_issues_count"`
+	OpenPullRequests int          `json:"open_pull_requests_count"`
+	LatestRelease    *RepoRelease `json:"latest_release"`
+	DefaultBranch    string       `json:"default_branch"`
+	Error            string       `json:"error,omitempty"`
+}
+
+// RepoRelease is the latest release of a repository
+type RepoRelease struct {
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	PublishedAt *time.Time `json:"published_at"`
+	HTMLURL     string     `json:"html_url"`
+}
+
+// repoStatsNode is a repository selected by repoStatsFragment
+type repoStatsNode struct {
+	FullName      string       `json:"full_name"`
+	Stars         int          `json:"stargazers_count"`
+	Issues        totalCount   `json:"issues"`
+	PullRequests  totalCount   `json:"pullRequests"`
+	LatestRelease *RepoRelease `json:"latestRelease"`
+	DefaultBranch *struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+}
+
+type totalCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+// GetRepoStats returns the stars, open issues and pull requests, latest release and default branch of the
+// comma separated repos, e.g. GET /v1/repos/stats?repos=octocat/hello-world,octocat/spoon-knife, in their order.
+// The repositories are fetched by aliased GraphQL queries of 25 repositories each rather than 4 REST calls per
+// repository; those not found or not accessible are returned with their error.
+func GetRepoStats(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		var stats []*RepoStats
+		for _, repo := range strings.Split(r.URL.Query().Get("repos"), ",") {
+			if repo = strings.TrimSpace(repo); repo == "" {
+				continue
+			}
+			if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+				middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("Invalid repo %q, expected owner/name", repo))
+				return
+			}
+			stats = append(stats, &RepoStats{Repo: repo})
+		}
+		if len(stats) == 0 {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("The repos query parameter is required"))
+			return
+		}
+		if len(stats) > maxStatsRepos {
+			middleware.WriteStatusError(w, http.StatusBadRequest, fmt.Errorf("At most %d repos can be requested", maxStatsRepos))
+			return
+		}
+
+		g, ctx := data.workers.Group(r.Context(), "repo-stats", data.pagination.Concurrency)
+		for start := 0; start < len(stats); start += statsChunk {
+			chunk := stats[start:min(start+statsChunk, len(stats))]
+			g.Go(func() error {
+				result, _, err := svc.ForwardGraphQL(ctx, repoStatsQuery(chunk))
+				if err != nil {
+					return err
+				}
+				return readRepoStats(chunk, result)
+			})
+		}
+		if WriteError(w, g.Wait()) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, stats)
+	}
+}
+
+// repoStatsQuery returns the query of the chunk's repositories, aliased r0, r1, ... in their order
+func repoStatsQuery(chunk []*RepoStats) *githubsvc.GraphQLRequest {
+	var params, fields strings.Builder
+	variables := map[string]interface{}{}
+	for i, repo := range chunk {
+		owner, name, _ := strings.Cut(repo.Repo, "/")
+		n := strconv.Itoa(i)
+		variables["o"+n], variables["n"+n] = owner, name
+		if i > 0 {
+			params.WriteString(", ")
+		}
+		fmt.Fprintf(&params, "$o%v: String!, $n%v: String!", n, n)
+		fmt.Fprintf(&fields, "  r%v: repository(owner: $o%v, name: $n%v) { ...repoStats }\n", n, n, n)
+	}
+	query := "query(" + params.String() + ") {\n" + fields.String() + "}\n" + repoStatsFragment
+	return &githubsvc.GraphQLRequest{Query: query, Variables: variables}
+}
+
+// readRepoStats sets the stats of the chunk's repositories from the response, or the errors of those GitHub
+// failed to return. The errors of the query as a whole are returned.
+func readRepoStats(chunk []*RepoStats, result *githubsvc.GraphQLResponse) error {
+	var nodes map[string]*repoStatsNode
+	if len(result.Data) > 0 && string(result.Data) != "null" {
+		if err := json.Unmarshal(result.Data, &nodes); err != nil {
+			return err
+		}
+	}
+	if nodes == nil && len(result.Errors) > 0 {
+		return result.Errors
+	}
+
+	errs := map[string]string{}
+	for _, err := range result.Errors {
+		if len(err.Path) > 0 {
+			if alias, ok := err.Path[0].(string); ok && errs[alias] == "" {
+				errs[alias] = err.Message
+			}
+		}
+	}
+	for i, repo := range chunk {
+		alias := "r" + strconv.Itoa(i)
+		node := nodes[alias]
+		if node == nil {
+			repo.Error = errs[alias]
+			if repo.Error == "" {
+				repo.Error = "Not Found"
+			}
+			continue
+		}
+		node.stats(repo)
+	}
+	return nil
+}
+
+// stats sets the stats of the repository from the node
+func (node *repoStatsNode) stats(repo *RepoStats) {
+	repo.Repo = node.FullName
+	repo.Stars = node.Stars
+	repo.OpenIssues = node.Issues.TotalCount
+	repo.OpenPullRequests = node.PullRequests.TotalCount
+	repo.LatestRelease = node.LatestRelease
+	if node.DefaultBranch != nil {
+		repo.DefaultBranch = node.DefaultBranch.Name
+	}
+}
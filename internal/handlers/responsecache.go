@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// cachedHeaders are the response headers replayed with the cached bodies
+var cachedHeaders = []string{"Content-Type", "X-Next-Cursor", "X-Prev-Cursor", "Link", "X-First-Page", "X-Prev-Page",
+	"X-Next-Page", "X-Last-Page", "X-Truncated", "X-API-Version"}
+
+// cachedResponse is a successful JSON response, as kept in the CacheStore
+type cachedResponse struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// CacheResponses serves successful JSON GET responses from the store for the ttl, so that dashboards polling the
+// same routes don't each make the GitHub calls. Responses are cached per consumer credentials, as they depend on
+// the GitHub token used. Requests with Cache-Control: no-cache skip the cached response, and no-store also skips
+// caching theirs. Streamed NDJSON lists aren't cached.
+func CacheResponses(store middleware.CacheStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cacheControl := strings.ToLower(r.Header.Get("Cache-Control"))
+			if r.Method != "GET" || strings.Contains(cacheControl, "no-store") || WantsNDJSON(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := responseKey(r)
+			if !strings.Contains(cacheControl, "no-cache") {
+				if b, ok := store.Get(r.Context(), key); ok {
+					var cached cachedResponse
+					if json.Unmarshal(b, &cached) == nil {
+						writeCached(w, r, &cached)
+						return
+					}
+				}
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") ||
+				w.Header().Get(streamErrorTrailer) != "" {
+				return
+			}
+
+			cached := &cachedResponse{Header: http.Header{}, Body: rec.body.Bytes()}
+			for _, header := range cachedHeaders {
+				if value := w.Header().Get(header); value != "" {
+					cached.Header.Set(header, value)
+				}
+			}
+			if b, err := json.Marshal(cached); err == nil {
+				store.Set(r.Context(), key, b, ttl)
+			}
+		})
+	}
+}
+
+// writeCached replays the cached response, identified by the current request's ID
+func writeCached(w http.ResponseWriter, r *http.Request, cached *cachedResponse) {
+	for header, values := range cached.Header {
+		w.Header()[header] = values
+	}
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(http.StatusOK)
+	w.Write(replayedBody(r, cached.Body))
+}
+
+// replayedBody returns the saved body, with the current request's ID in its envelope and without the rate limit,
+// as GitHub isn't called
+func replayedBody(r *http.Request, body []byte) []byte {
+	if raw, _ := r.Context().Value(rawResponsesKey).(bool); raw {
+		return body
+	}
+	var envelope map[string]json.RawMessage
+	if json.Unmarshal(body, &envelope) != nil {
+		return body
+	}
+	delete(envelope, "rate_limit")
+	envelope["request_id"], _ = json.Marshal(middleware.RequestIDFrom(r.Context()))
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return body
+	}
+	return append(b, '\n')
+}
+
+// responseKey identifies the response by url, media type and the consumer's GitHub credentials
+func responseKey(r *http.Request) string {
+	var session string
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		session = cookie.Value
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		r.URL.RequestURI(),
+		r.Header.Get("Accept"),
+		r.Header.Get("X-GitHub-Token"),
+		r.Header.Get("Authorization"),
+		session,
+	}, "\n")))
+	return "response:" + hex.EncodeToString(hash[:])
+}
+
+// cacheRecorder records the status and body of the response as it's written
+type cacheRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *cacheRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheRecorder) Write(b []byte) (int, error) {
+	// large downloads, e.g. migration archives, aren't cached
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
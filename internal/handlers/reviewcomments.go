@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// ReplyToPullComment replies to the pull request's review comment in its thread, e.g. {"body": "Fixed"}
+func ReplyToPullComment(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		number, _ := strconv.Atoi(vars["number"])
+		commentID, _ := strconv.ParseInt(vars["comment_id"], 10, 64)
+
+		var in struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		v := &validator{}
+		if v.required("body", in.Body) {
+			v.maxLength("body", in.Body, maxCommentLength)
+		}
+		if WriteError(w, v.err()) {
+			return
+		}
+
+		reply, _, err := svc.ReplyToPullComment(r.Context(), vars["owner"], vars["repo"], number, commentID, in.Body)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusCreated, reply)
+	}
+}
+
+// PullComment comments the line of the side of the pull request's diff, e.g. {"body": "Typo", "commit_id":
+// "6dcb09b", "path": "README.md", "line": 12, "side": "RIGHT"}, or the lines from start_line and start_side, or
+// the legacy position in the diff instead of the line. Without a body, the comment is the configured template
+// rendered with the pull request's location.
+func PullComment(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		number, _ := strconv.Atoi(vars["number"])
+
+		in := new(pullCommentRequest)
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if in.Body == "" && data.pullComment != nil {
+			if in.Body, err = data.pullComment.Render(CommentVars{
+				Owner:    owner,
+				Repo:     repo,
+				Number:   number,
+				Commit:   in.CommitID,
+				Path:     in.Path,
+				Position: in.Position,
+				Line:     in.Line,
+			}); WriteError(w, err) {
+				return
+			}
+		}
+		if WriteError(w, in.validate()) {
+			return
+		}
+
+		comment := &githubsvc.ReviewComment{
+			Body:     github.String(in.Body),
+			CommitID: github.String(in.CommitID),
+			Path:     github.String(in.Path),
+		}
+		if in.Line > 0 {
+			comment.Line, comment.Side = github.Int(in.Line), github.String(in.Side)
+			if in.StartLine > 0 {
+				comment.StartLine, comment.StartSide = github.Int(in.StartLine), github.String(in.StartSide)
+			}
+		} else {
+			comment.Position = github.Int(in.Position)
+		}
+		cmt, _, err := svc.CreatePullComment(r.Context(), owner, repo, number, comment)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusCreated, cmt)
+	}
+}
+
+// pullCommentRequest is the body of the pull request comments
+type pullCommentRequest struct {
+	Body     string `json:"body"`
+	CommitID string `json:"commit_id"`
+	Path     string `json:"path"`
+	Position int    `json:"position"`
+	// Side defaults to RIGHT, and StartSide to Side
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line"`
+	StartSide string `json:"start_side"`
+}
+
+func (in *pullCommentRequest) validate() error {
+	v := &validator{}
+	if v.required("body", in.Body) {
+		v.maxLength("body", in.Body, maxCommentLength)
+	}
+	v.sha("commit_id", in.CommitID)
+	v.required("path", in.Path)
+
+	if in.Line == 0 {
+		if in.StartLine != 0 || in.Side != "" || in.StartSide != "" {
+			v.fail("line", "is required with start_line, side and start_side")
+		}
+		v.atLeast("position", in.Position, 1)
+		return v.err()
+	}
+	if in.Position != 0 {
+		v.fail("position", "can't be set with line")
+	}
+	v.atLeast("line", in.Line, 1)
+	if in.Side == "" {
+		in.Side = "RIGHT"
+	}
+	v.oneOf("side", in.Side, "LEFT", "RIGHT")
+	if in.StartLine == 0 {
+		if in.StartSide != "" {
+			v.fail("start_line", "is required with start_side")
+		}
+		return v.err()
+	}
+	if in.StartLine >= in.Line {
+		v.fail("start_line", "must be before line")
+	}
+	if in.StartSide == "" {
+		in.StartSide = in.Side
+	}
+	v.oneOf("start_side", in.StartSide, "LEFT", "RIGHT")
+	return v.err()
+}
@@ -0,0 +1,233 @@
+// Package handlers serves the proxy's routes. NewRouter returns them as an http.Handler for other services to
+// embed, and NewWithService serves them with a fake GitHubService, e.g. for testing the handlers in isolation.
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRouter returns the handler of the datastore's routes, served under the /v1/ prefix or negotiated by
+// NegotiateVersion, e.g. for other services to mount them
+func NewRouter(data *Datastore) http.Handler {
+	r := mux.NewRouter()
+	r.Use(middleware.RecordRoute)
+	r.Use(githubsvc.RequestPriority)
+	if data.jwt != nil {
+		// consumers can authenticate with either a bearer token or an API key when both are configured
+		r.Use(middleware.RequireJWT(data.jwt, len(data.apiKeys) > 0))
+	}
+	if len(data.apiKeys) > 0 {
+		r.Use(middleware.RequireAPIKey(data.apiKeys))
+	}
+	if data.rateLimit.RequestsPerSecond > 0 {
+		// consumers are limited once authenticated, so each one has its own budget
+		r.Use(middleware.RateLimit(data.rateLimit, data.redis))
+	}
+	if data.jobs != nil {
+		// jobs are rate limited when submitted, and audited when run
+		r.Use(Async(data.jobs))
+	}
+	if data.rawResponses {
+		r.Use(RawResponses)
+	}
+	// the dry runs aren't audited, and run as jobs when asynchronous
+	r.Use(DryRun(data.readOnly))
+	if data.audit != nil {
+		// consumers are identified by then
+		r.Use(middleware.AuditWrites(data.audit))
+	}
+
+	if data.Enabled("metrics") {
+		r.Methods("GET").Path("/metrics").Handler(promhttp.Handler())
+	}
+
+	if data.debug {
+		// the profiles expose the proxy's internals, so they're for the admins whatever the roles of the routes
+		g := r.PathPrefix("/debug").Subrouter()
+		g.Use(middleware.RequireRole(data.roles, data.defaultRole, middleware.RoleAdmin))
+		g.Methods("GET").Path("/runtime").Handler(GetRuntimeStats())
+		g.Methods("GET").Path("/pprof/cmdline").HandlerFunc(pprof.Cmdline)
+		g.Methods("GET").Path("/pprof/profile").HandlerFunc(pprof.Profile)
+		g.Methods("GET").Path("/pprof/symbol").HandlerFunc(pprof.Symbol)
+		g.Methods("GET").Path("/pprof/trace").HandlerFunc(pprof.Trace)
+		g.Methods("GET").PathPrefix("/pprof/").HandlerFunc(pprof.Index)
+	}
+
+	if data.webhookSecret != nil {
+		r.Methods("POST").Path("/webhooks/github").Handler(ReceiveWebhook(data.webhookSecret, data.webhooks))
+	}
+
+	if data.OAuth != nil {
+		r.Methods("GET").Path("/auth/login").Handler(Login(data))
+		r.Methods("GET").Path("/auth/callback").Handler(Callback(data))
+		r.Methods("POST").Path("/auth/logout").Handler(Logout(data))
+	}
+
+	versioned := middleware.NegotiateVersion(r)
+	v1 := r.PathPrefix("/v1").Subrouter()
+
+	if data.webhooks != nil && data.Enabled("events") {
+		g := data.group(v1, "events")
+		g.Methods("GET").Path("/events/stream").Handler(StreamEvents(data))
+		g.Methods("GET").Path("/events/ws").Handler(SubscribeEvents(data))
+		if data.webhooks.store != nil {
+			g.Methods("GET").Path("/events").Handler(ListEvents(data))
+			g.Methods("POST").Path("/events/replay").Handler(ReplayEvents(data))
+		}
+	}
+
+	if data.jobs != nil {
+		g := data.group(v1, "jobs")
+		g.Methods("GET").Path("/jobs/{id}").Handler(GetJob(data))
+	}
+
+	if data.Enabled("batch") {
+		g := data.group(v1, "batch")
+		g.Methods("POST").Path("/batch").Handler(Batch(data, versioned))
+	}
+
+	if data.Enabled("token") {
+		g := data.group(v1, "token")
+		g.Methods("GET").Path("/token/info").Handler(GetTokenInfo(data))
+	}
+
+	if data.Enabled("count") {
+		g := data.group(v1, "count")
+		g.Methods("GET").Path("/{owner}/repos/count").Handler(GetCount(data))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/issues/count").Handler(CountIssues(data, "is:issue"))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/pulls/count").Handler(CountIssues(data, "is:pr"))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/stargazers/count").Handler(CountStargazers(data))
+	}
+
+	if data.Enabled("repos") {
+		g := data.group(v1, "repos")
+		g.Methods("GET").Path("/{owner}/repos").Handler(ListRepos(data))
+	}
+
+	if data.Enabled("stats") {
+		g := data.group(v1, "stats")
+		g.Methods("GET").Path("/repos/stats").Handler(GetRepoStats(data))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/dashboard").Handler(GetRepoDashboard(data))
+	}
+
+	if data.inventory != nil && data.Enabled("inventory") {
+		g := data.group(v1, "inventory")
+		g.Methods("GET").Path("/orgs/{org}/inventory").Handler(GetInventory(data))
+	}
+
+	if data.graphql != nil && data.Enabled("graphql") {
+		g := data.group(v1, "graphql")
+		g.Methods("GET", "POST").Path("/graphql").Handler(GraphQL(data))
+	}
+
+	if data.Enabled("comments") {
+		g := data.group(v1, "comments")
+		g.Methods("POST").Path("/{owner}/repos/{repo}/{commit}/comment").Handler(CommitComment(data))
+		g.Methods("POST").Path("/{owner}/repos/{repo}/pulls/{number:[0-9]+}/comments").Handler(PullComment(data))
+		g.Methods("POST").Path("/{owner}/repos/{repo}/pulls/{number:[0-9]+}/suggestions").Handler(SuggestChange(data))
+		g.Methods("POST").Path("/{owner}/repos/{repo}/pulls/{number:[0-9]+}/comments/{comment_id:[0-9]+}/replies").Handler(ReplyToPullComment(data))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/commits/{commit}/comments").Handler(ListComments(data, commitComments))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/issues/{number:[0-9]+}/comments").Handler(ListComments(data, issueComments))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/pulls/{number:[0-9]+}/comments").Handler(ListComments(data, pullComments))
+		for kind, path := range commentPaths {
+			g.Methods("GET").Path("/{owner}/repos/{repo}/" + path).Handler(ListComments(data, kind))
+			g.Methods("PATCH").Path("/{owner}/repos/{repo}/" + path + "/{comment_id:[0-9]+}").Handler(EditComment(data, kind))
+			g.Methods("DELETE").Path("/{owner}/repos/{repo}/" + path + "/{comment_id:[0-9]+}").Handler(DeleteComment(data, kind))
+		}
+	}
+
+	if data.Enabled("hooks") && githubsvc.Supports(githubsvc.FeatureHookDeliveries) {
+		g := data.group(v1, "hooks")
+		g.Methods("GET").Path("/orgs/{org}/hooks/failed-deliveries").Handler(ListFailedDeliveries(data))
+		g.Methods("POST").Path("/orgs/{org}/hooks/failed-deliveries/redeliver").Handler(RedeliverFailed(data))
+	}
+
+	if data.Enabled("interaction-limits") && githubsvc.Supports(githubsvc.FeatureInteractionLimits) {
+		g := data.group(v1, "interaction-limits")
+		g.Methods("GET").Path("/{owner}/repos/{repo}/interaction-limits").Handler(GetInteractions(data))
+		g.Methods("PUT").Path("/{owner}/repos/{repo}/interaction-limits").Handler(SetInteractions(data))
+		g.Methods("DELETE").Path("/{owner}/repos/{repo}/interaction-limits").Handler(RemoveInteractions(data))
+		g.Methods("GET").Path("/orgs/{org}/interaction-limits").Handler(GetInteractions(data))
+		g.Methods("PUT").Path("/orgs/{org}/interaction-limits").Handler(SetInteractions(data))
+		g.Methods("DELETE").Path("/orgs/{org}/interaction-limits").Handler(RemoveInteractions(data))
+	}
+
+	if data.Enabled("migrations") {
+		g := data.group(v1, "migrations")
+		g.Methods("POST").Path("/orgs/{org}/migrations").Handler(StartMigration(data))
+		g.Methods("GET").Path("/orgs/{org}/migrations").Handler(ListMigrations(data))
+		g.Methods("GET").Path("/orgs/{org}/migrations/{id:[0-9]+}").Handler(MigrationStatus(data))
+		g.Methods("GET").Path("/orgs/{org}/migrations/{id:[0-9]+}/archive").Handler(MigrationArchive(data))
+	}
+
+	if data.Enabled("audit-log") && githubsvc.Supports(githubsvc.FeatureAuditLog) {
+		g := data.group(v1, "audit-log")
+		g.Methods("GET").Path("/orgs/{org}/audit-log").Handler(GetAuditLog(data))
+	}
+
+	if data.Enabled("discussions") && githubsvc.Supports(githubsvc.FeatureDiscussions) {
+		g := data.group(v1, "discussions")
+		g.Methods("GET").Path("/{owner}/repos/{repo}/discussions").Handler(ListDiscussions(data))
+	}
+
+	if data.Enabled("projects") && githubsvc.Supports(githubsvc.FeatureProjectsV2) {
+		g := data.group(v1, "projects")
+		g.Methods("GET").Path("/orgs/{org}/projects").Handler(ListProjects(data))
+	}
+
+	if data.Enabled("scim") && githubsvc.Supports(githubsvc.FeatureSCIM) {
+		g := data.group(v1, "scim")
+		g.Methods("GET").Path("/orgs/{org}/scim/users").Handler(ListSCIMUsers(data))
+		g.Methods("POST").Path("/orgs/{org}/scim/users").Handler(ProvisionSCIMUser(data))
+		g.Methods("GET").Path("/orgs/{org}/scim/users/{id}").Handler(GetSCIMUser(data))
+		g.Methods("DELETE").Path("/orgs/{org}/scim/users/{id}").Handler(DeprovisionSCIMUser(data))
+	}
+
+	if data.Enabled("imports") {
+		g := data.group(v1, "imports")
+		g.Methods("PUT").Path("/{owner}/repos/{repo}/import").Handler(StartImport(data))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/import").Handler(ImportProgress(data))
+		g.Methods("PATCH").Path("/{owner}/repos/{repo}/import").Handler(UpdateImport(data))
+		g.Methods("DELETE").Path("/{owner}/repos/{repo}/import").Handler(CancelImport(data))
+		g.Methods("GET").Path("/{owner}/repos/{repo}/import/authors").Handler(ImportAuthors(data))
+		g.Methods("PATCH").Path("/{owner}/repos/{repo}/import/authors/{id:[0-9]+}").Handler(MapImportAuthor(data))
+	}
+
+	// preflight requests are answered before authentication, as browsers don't send credentials with them
+	return middleware.Trace(middleware.RequestID(middleware.LogRequests(middleware.ForwardRateLimit(middleware.Compress(middleware.Recover(middleware.CORS(data.cors)(versioned)))))))
+}
+
+// group returns a subrouter for a group of routes, only allowing consumers with the required role, and
+// applying the group's request timeout, retries, priority, response caching and idempotency keys
+func (data *Datastore) group(r *mux.Router, name string) *mux.Router {
+	g := r.NewRoute().Subrouter()
+	if len(data.roles) > 0 {
+		g.Use(middleware.Authorize(data.roles, data.defaultRole, name))
+	}
+	g.Use(middleware.RequestTimeout(data.timeout(name)))
+	if attempts, ok := data.attempts[name]; ok {
+		g.Use(githubsvc.RetryAttempts(attempts))
+	}
+	for _, background := range data.backgroundRoutes {
+		if background == name {
+			g.Use(githubsvc.BackgroundPriority)
+		}
+	}
+	if ttl := data.cacheTTL(name); ttl > 0 {
+		g.Use(CacheResponses(data.cache, ttl))
+	}
+	if data.idempotency != nil {
+		g.Use(data.idempotency.Idempotent)
+	}
+	// the cached and replayed responses are served under load, and the event streams, long-lived, aren't bounded
+	if data.shedder != nil && name != "events" {
+		g.Use(data.shedder.Shed)
+	}
+	return g
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+func TestRoutes(t *testing.T) {
+	const origin = "https://dashboard.example.com"
+	preflight := map[string]string{"Origin": origin, "Access-Control-Request-Method": "POST"}
+
+	tests := []struct {
+		name   string
+		setup  func(data *Datastore)
+		method string
+		path   string
+		body   string
+		header map[string]string
+
+		status int
+		// headers are the response headers expected, their values joined, empty when they must be missing
+		headers  map[string]string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:   "comment created",
+			method: "POST", path: "/v1/octocat/repos/hello-world/" + testSHA + "/comment", body: `{"body": "Looks good"}`,
+			status:   http.StatusCreated,
+			contains: []string{`"id":1`, `"body":"Looks good"`},
+		},
+		{
+			name:   "comment without body",
+			method: "POST", path: "/v1/octocat/repos/hello-world/" + testSHA + "/comment", body: `{"body": ""}`,
+			status:   http.StatusBadRequest,
+			contains: []string{`"Invalid request"`, `"body"`},
+		},
+		{
+			name:   "comment with invalid sha",
+			method: "POST", path: "/v1/octocat/repos/hello-world/not-a-sha/comment", body: `{"body": "Looks good"}`,
+			status:   http.StatusBadRequest,
+			contains: []string{"must be a hex commit SHA"},
+		},
+		{
+			name:   "comment of a missing repository",
+			method: "POST", path: "/v1/octocat/repos/missing/" + testSHA + "/comment", body: `{"body": "Looks good"}`,
+			status:   http.StatusNotFound,
+			contains: []string{`"error":"Not Found"`, `"status":404`},
+		},
+		{
+			name:   "GitHub server error",
+			method: "POST", path: "/v1/octocat/repos/broken/" + testSHA + "/comment", body: `{"body": "Looks good"}`,
+			status:   http.StatusBadGateway,
+			contains: []string{`"error":"Server Error"`, `"status":500`},
+		},
+		{
+			name:   "page of comments",
+			method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?page=2&per_page=1",
+			status:   http.StatusOK,
+			headers:  map[string]string{"X-Prev-Page": "1", "X-Next-Page": "3", "X-Last-Page": "3"},
+			contains: []string{`"id":12`, `"next_page":3`},
+			excludes: []string{`"id":11`},
+		},
+		{
+			name:   "all comments",
+			method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?all=true",
+			status:   http.StatusOK,
+			headers:  map[string]string{"X-Truncated": ""},
+			contains: []string{`"id":11`, `"id":12`, `"id":13`},
+		},
+		{
+			name:   "all comments truncated at the maximum pages",
+			setup:  func(data *Datastore) { data.pagination.MaxPages = 2 },
+			method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?all=true",
+			status:   http.StatusOK,
+			headers:  map[string]string{"X-Truncated": "true"},
+			contains: []string{`"id":11`, `"id":12`},
+			excludes: []string{`"id":13`},
+		},
+		{
+			name:   "invalid page",
+			method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?page=zero",
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "CORS preflight of an allowed origin",
+			setup: func(data *Datastore) {
+				data.cors = middleware.CORSConfig{AllowedOrigins: []string{origin}, AllowCredentials: true}
+			},
+			method: "OPTIONS", path: "/v1/octocat/repos/hello-world/" + testSHA + "/comment", header: preflight,
+			status: http.StatusNoContent,
+			headers: map[string]string{"Access-Control-Allow-Origin": origin, "Access-Control-Allow-Credentials": "true",
+				"Vary": "Accept-Encoding, Origin", "Access-Control-Max-Age": "600"},
+		},
+		{
+			name:   "CORS of any origin",
+			setup:  func(data *Datastore) { data.cors = middleware.CORSConfig{AllowedOrigins: []string{"*"}} },
+			method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?page=2&per_page=1",
+			header: map[string]string{"Origin": origin},
+			status: http.StatusOK,
+			headers: map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": "",
+				"Vary": "Accept-Encoding"},
+		},
+		{
+			name:   "CORS of another origin",
+			setup:  func(data *Datastore) { data.cors = middleware.CORSConfig{AllowedOrigins: []string{origin}} },
+			method: "GET", path: "/v1/octocat/repos/hello-world/issues/1/comments?page=2&per_page=1",
+			header:  map[string]string{"Origin": "https://evil.example.com"},
+			status:  http.StatusOK,
+			headers: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router, _ := newTestRouter(t, "comments.json", test.setup)
+			resp, body := serve(router, test.method, test.path, test.body, test.header)
+			if resp.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, test.status, body)
+			}
+			for header, want := range test.headers {
+				if got := strings.Join(resp.Header.Values(header), ", "); got != want {
+					t.Errorf("%v: %q, want %q", header, got, want)
+				}
+			}
+			for _, want := range test.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("body missing %v: %s", want, body)
+				}
+			}
+			for _, unwanted := range test.excludes {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body has %v: %s", unwanted, body)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	router, transport := newTestRouter(t, "comments.json", func(data *Datastore) {
+		data.idempotency = newIdempotencyKeys(middleware.NewMemoryCache(100), IdempotencyConfig{Enabled: true})
+	})
+	path := "/v1/octocat/repos/hello-world/" + testSHA + "/comment"
+	header := map[string]string{"Idempotency-Key": "comment-1"}
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		replayed string
+		calls    int32
+	}{
+		{name: "first request", body: `{"body": "Looks good"}`, status: http.StatusCreated, calls: 1},
+		{name: "retry replayed", body: `{"body": "Looks good"}`, status: http.StatusCreated, replayed: "true", calls: 1},
+		{name: "key reused for another request", body: `{"body": "Ship it"}`, status: http.StatusUnprocessableEntity, calls: 1},
+	}
+	// the requests run in order, each sharing the keys of the previous ones
+	for _, test := range tests {
+		resp, body := serve(router, "POST", path, test.body, header)
+		if resp.StatusCode != test.status {
+			t.Fatalf("%v: status %d, want %d: %s", test.name, resp.StatusCode, test.status, body)
+		}
+		if got := resp.Header.Get("Idempotent-Replayed"); got != test.replayed {
+			t.Errorf("%v: Idempotent-Replayed %q, want %q", test.name, got, test.replayed)
+		}
+		if calls := transport.calls.Load(); calls != test.calls {
+			t.Errorf("%v: %d GitHub calls, want %d", test.name, calls, test.calls)
+		}
+		if test.status == http.StatusCreated && !strings.Contains(body, `"id":1`) {
+			t.Errorf("%v: %s, want the comment created first", test.name, body)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// WebhookRule decides whether the events it matches are forwarded, dropped or transformed before their delivery
+// to the sinks, e.g. dropping the events of bots or removing the senders from the payloads
+type WebhookRule struct {
+	// Events are the patterns of the matched events, e.g. "pull_request.opened", and Repos the patterns of their
+	// repository's full name, e.g. "my-org/api-*"; empty lists match every event
+	Events []string `yaml:"events"`
+	Repos  []string `yaml:"repos"`
+	// Fields are the patterns of the payload's values at their dotted paths, e.g. "sender.type": "Bot"
+	Fields map[string]string `yaml:"fields"`
+	// Action is "forward", "drop" or "transform", forwarding the transformed event
+	Action string `yaml:"action"`
+	// Keep prunes the transformed payloads to the dotted paths, Remove deletes the paths and Set sets them
+	Keep   []string          `yaml:"keep"`
+	Remove []string          `yaml:"remove"`
+	Set    map[string]string `yaml:"set"`
+}
+
+// validateRules reports the rules' unknown actions and malformed patterns
+func validateRules(rules []WebhookRule) error {
+	for i, rule := range rules {
+		switch rule.Action {
+		case "forward", "drop", "transform":
+		default:
+			return fmt.Errorf("Unknown action %q of webhook rule %v", rule.Action, i+1)
+		}
+		patterns := append([]string(nil), rule.Repos...)
+		for _, pattern := range rule.Fields {
+			patterns = append(patterns, pattern)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("Invalid pattern %q of webhook rule %v", pattern, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyRules forwards the events to the handler as decided by the first rule they match, and forwards the
+// events matching none
+func ApplyRules(rules []WebhookRule) WebhookMiddleware {
+	return func(next WebhookHandler) WebhookHandler {
+		return WebhookHandlerFunc(func(ctx context.Context, event *WebhookEvent) error {
+			rule := firstMatch(rules, event)
+			if rule == nil {
+				return next.HandleWebhook(ctx, event)
+			}
+			switch rule.Action {
+			case "drop":
+				return nil
+			case "transform":
+				transformed, err := rule.transform(event)
+				if err != nil {
+					return err
+				}
+				event = transformed
+			}
+			return next.HandleWebhook(ctx, event)
+		})
+	}
+}
+
+// firstMatch returns the first rule matching the event, or nil
+func firstMatch(rules []WebhookRule, event *WebhookEvent) *WebhookRule {
+	var payload interface{}
+	decoded := false
+	for i := range rules {
+		if len(rules[i].Fields) > 0 && !decoded {
+			// the payload is only decoded for the rules matching its fields
+			payload, decoded = decodePayload(event.Raw), true
+		}
+		if rules[i].matches(event, payload) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (rule *WebhookRule) matches(event *WebhookEvent, payload interface{}) bool {
+	if !matchesAny(rule.Events, func(pattern string) bool { return matchesPattern(pattern, event) }) ||
+		!matchesAny(rule.Repos, func(pattern string) bool {
+			matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(event.Repo))
+			return matched
+		}) {
+		return false
+	}
+	for field, pattern := range rule.Fields {
+		if matched, _ := path.Match(pattern, csvCell(payload, field)); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// transform returns a copy of the event with the transformed payload
+func (rule *WebhookRule) transform(event *WebhookEvent) (*WebhookEvent, error) {
+	payload := decodePayload(event.Raw)
+	if len(rule.Keep) > 0 {
+		payload = newFieldTree(rule.Keep).prune(payload)
+	}
+	object, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("The payload of the %v event isn't an object", event.Type)
+	}
+	for _, field := range rule.Remove {
+		names := strings.Split(field, ".")
+		if parent, ok := fieldParent(object, names, false); ok {
+			delete(parent, names[len(names)-1])
+		}
+	}
+	for field, value := range rule.Set {
+		names := strings.Split(field, ".")
+		if parent, ok := fieldParent(object, names, true); ok {
+			parent[names[len(names)-1]] = value
+		}
+	}
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	transformed := *event
+	transformed.Raw = raw
+	if transformed.Payload, err = parsePayload(event.Type, raw); err != nil {
+		return nil, err
+	}
+	return &transformed, nil
+}
+
+// decodePayload decodes the payload, keeping the numbers as they are, e.g. the 64 bit IDs
+func decodePayload(raw json.RawMessage) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var payload interface{}
+	dec.Decode(&payload)
+	return payload
+}
+
+// fieldParent returns the object holding the last of the names, creating the missing objects when create is set
+func fieldParent(object map[string]interface{}, names []string, create bool) (map[string]interface{}, bool) {
+	for _, name := range names[:len(names)-1] {
+		child, ok := object[name].(map[string]interface{})
+		if !ok {
+			if !create {
+				return nil, false
+			}
+			child = map[string]interface{}{}
+			object[name] = child
+		}
+		object = child
+	}
+	return object, true
+}
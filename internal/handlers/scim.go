@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+// scimListParams are the query parameters passed through when listing SCIM users
+var scimListParams = []string{"startIndex", "count", "filter"}
+
+// ListSCIMUsers lists the users provisioned in the org, passing through the startIndex, count and filter parameters
+func ListSCIMUsers(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		org := mux.Vars(r)["org"]
+
+		query := url.Values{}
+		for _, param := range scimListParams {
+			if v := r.URL.Query().Get(param); v != "" {
+				query.Set(param, v)
+			}
+		}
+
+		list, _, err := svc.ListSCIMUsers(r.Context(), org, query)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, list)
+	}
+}
+
+// GetSCIMUser returns a single provisioned user
+func GetSCIMUser(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+
+		user, _, err := svc.GetSCIMUser(r.Context(), vars["org"], vars["id"])
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusOK, user)
+	}
+}
+
+// ProvisionSCIMUser provisions an org membership for a user, sending an invitation to the given email
+func ProvisionSCIMUser(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		org := mux.Vars(r)["org"]
+
+		user := new(githubsvc.SCIMUser)
+		if err := json.NewDecoder(r.Body).Decode(user); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if user.UserName == "" || len(user.Emails) == 0 {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("userName and at least one email are required"))
+			return
+		}
+
+		created, _, err := svc.ProvisionSCIMUser(r.Context(), org, user)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusCreated, created)
+	}
+}
+
+// DeprovisionSCIMUser removes the user from the org and deletes its SCIM identity
+func DeprovisionSCIMUser(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+
+		_, err = svc.DeprovisionSCIMUser(r.Context(), vars["org"], vars["id"])
+		if WriteError(w, err) {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// groupScopes maps the route groups to the OAuth scopes their routes need
+var groupScopes = map[string][]string{
+	"count":              {"repo"},
+	"comments":           {"repo"},
+	"imports":            {"repo"},
+	"interaction-limits": {"repo", "admin:org"},
+	"migrations":         {"admin:org"},
+	"audit-log":          {"admin:org"},
+	"scim":               {"admin:org"},
+	"hooks":              {"admin:repo_hook", "admin:org_hook"},
+	"discussions":        {"read:discussion"},
+	"projects":           {"read:project"},
+}
+
+// writeGroupScopes maps the groups of write routes, disabled unless enabled by the config, to the OAuth scopes
+// their routes need
+var writeGroupScopes = map[string][]string{
+	"discussions-write": {"write:discussion"},
+	"projects-write":    {"project"},
+}
+
+// requiredScopes maps the OAuth scopes needed by the route groups enabled by the config to the groups needing
+// them, the groups being enabled when missing, but the groups of write routes
+func requiredScopes(routes map[string]bool) map[string][]string {
+	required := map[string][]string{}
+	for group, scopes := range groupScopes {
+		if enabled, ok := routes[group]; ok && !enabled {
+			continue
+		}
+		for _, scope := range scopes {
+			required[scope] = append(required[scope], group)
+		}
+	}
+	for group, scopes := range writeGroupScopes {
+		if !routes[group] {
+			continue
+		}
+		for _, scope := range scopes {
+			required[scope] = append(required[scope], group)
+		}
+	}
+	return required
+}
+
+// impliedScopes lists the scopes granted along with a parent scope
+var impliedScopes = map[string][]string{
+	"repo":             {"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"},
+	"admin:org":        {"write:org", "read:org"},
+	"write:org":        {"read:org"},
+	"admin:public_key": {"write:public_key", "read:public_key"},
+	"write:public_key": {"read:public_key"},
+	"admin:repo_hook":  {"write:repo_hook", "read:repo_hook"},
+	"write:repo_hook":  {"read:repo_hook"},
+	"write:discussion": {"read:discussion"},
+	"project":          {"read:project"},
+	"user":             {"read:user", "user:email", "user:follow"},
+}
+
+// ValidateToken verifies the client's token is valid, and has been granted the required scopes, mapped to the
+// route groups needing them. Tokens without OAuth scopes, e.g. fine-grained personal access tokens, are only
+// checked for validity.
+func ValidateToken(ctx context.Context, client *github.Client, required map[string][]string) error {
+	user, resp, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return fmt.Errorf("Token validation failed: %v", err)
+	}
+
+	header, ok := resp.Header["X-Oauth-Scopes"]
+	if !ok {
+		return nil
+	}
+	granted := map[string]bool{}
+	for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+		grantScope(granted, strings.TrimSpace(scope))
+	}
+
+	var missing []string
+	for scope, groups := range required {
+		if !granted[scope] {
+			sort.Strings(groups)
+			missing = append(missing, fmt.Sprintf("%v (needed by the %v routes)", scope, strings.Join(groups, ", ")))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("Token for %v is missing scopes: %v", user.GetLogin(), strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// grantScope adds the scope and all the scopes it implies
+func grantScope(granted map[string]bool, scope string) {
+	if scope == "" || granted[scope] {
+		return
+	}
+	granted[scope] = true
+	for _, implied := range impliedScopes[scope] {
+		grantScope(granted, implied)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRequiredScopes(t *testing.T) {
+	tests := []struct {
+		group string
+		// enabled is whether the group is enabled by the config, the groups of read routes being enabled when missing
+		enabled bool
+		scopes  []string
+	}{
+		{group: "count", enabled: true, scopes: []string{"repo"}},
+		{group: "comments", enabled: true, scopes: []string{"repo"}},
+		{group: "imports", enabled: true, scopes: []string{"repo"}},
+		{group: "interaction-limits", enabled: true, scopes: []string{"admin:org", "repo"}},
+		{group: "migrations", enabled: true, scopes: []string{"admin:org"}},
+		{group: "audit-log", enabled: true, scopes: []string{"admin:org"}},
+		{group: "scim", enabled: true, scopes: []string{"admin:org"}},
+		{group: "hooks", enabled: true, scopes: []string{"admin:org_hook", "admin:repo_hook"}},
+		{group: "discussions", enabled: true, scopes: []string{"read:discussion"}},
+		{group: "discussions-write", enabled: true, scopes: []string{"write:discussion"}},
+		{group: "discussions-write"},
+		{group: "projects", enabled: true, scopes: []string{"read:project"}},
+		{group: "projects-write", enabled: true, scopes: []string{"project"}},
+		{group: "projects-write"},
+	}
+	for _, test := range tests {
+		// disable every other group, for only the group's scopes to be required
+		routes := map[string]bool{test.group: test.enabled}
+		for group := range groupScopes {
+			if group != test.group {
+				routes[group] = false
+			}
+		}
+
+		var scopes []string
+		for scope, groups := range requiredScopes(routes) {
+			if !reflect.DeepEqual(groups, []string{test.group}) {
+				t.Errorf("%v: %v needed by %v", test.group, scope, groups)
+			}
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		if !reflect.DeepEqual(scopes, test.scopes) {
+			t.Errorf("%v enabled %v: scopes %v, want %v", test.group, test.enabled, scopes, test.scopes)
+		}
+	}
+}
+
+func TestGrantScope(t *testing.T) {
+	tests := map[string][]string{
+		"write:discussion": {"read:discussion", "write:discussion"},
+		"project":          {"project", "read:project"},
+		"admin:repo_hook":  {"admin:repo_hook", "read:repo_hook", "write:repo_hook"},
+		"admin:org_hook":   {"admin:org_hook"},
+	}
+	for scope, want := range tests {
+		granted := map[string]bool{}
+		grantScope(granted, scope)
+		var scopes []string
+		for scope := range granted {
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		if !reflect.DeepEqual(scopes, want) {
+			t.Errorf("%v: granted %v, want %v", scope, scopes, want)
+		}
+	}
+}
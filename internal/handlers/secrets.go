@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"golang.org/x/oauth2"
+)
+
+// SecretConfig configures fetching the GitHub token from a secret manager, instead of the config or environment
+type SecretConfig struct {
+	// Provider is "vault" or "aws-secrets-manager"
+	Provider string `yaml:"provider"`
+	// Address is the Vault server, defaulting to VAULT_ADDR; Vault requests are authenticated with VAULT_TOKEN
+	Address string `yaml:"address"`
+	// Path is the Vault secret path, e.g. "secret/data/github-api", or the Secrets Manager secret ID
+	Path string `yaml:"path"`
+	// Key is the field of the secret holding the token, defaulting to "token". Plain text Secrets Manager
+	// secrets are used whole.
+	Key string `yaml:"key"`
+	// Region is the AWS region, defaulting to the SDK's configuration
+	Region string `yaml:"region"`
+	// Refresh is how often the token is fetched again, picking up rotations; it's only fetched at startup
+	// without one
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// NewFromSecret function, initiates and returns a Github datastore instance authenticated with the token fetched
+// from the secret manager, and refetched every refresh interval until the datastore is closed
+func NewFromSecret(config SecretConfig) (*Datastore, error) {
+	ctx, cancel := context.WithCancel(githubsvc.OutboundContext(context.Background()))
+
+	ts := &secretTokenSource{config: config}
+	if err := ts.fetch(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("Fetching the token from %v: %v", config.Provider, err)
+	}
+	if config.Refresh > 0 {
+		go ts.refresh(ctx)
+	}
+
+	return newWithTokenSource(ctx, cancel, ts)
+}
+
+// secretTokenSource serves the token last fetched from the secret manager
+type secretTokenSource struct {
+	config SecretConfig
+
+	mu    sync.RWMutex
+	token string
+}
+
+func (s *secretTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &oauth2.Token{AccessToken: s.token}, nil
+}
+
+// fetch replaces the token with the secret manager's
+func (s *secretTokenSource) fetch(ctx context.Context) error {
+	var token string
+	var err error
+	switch s.config.Provider {
+	case "vault":
+		token, err = s.fetchVault(ctx)
+	case "aws-secrets-manager":
+		token, err = s.fetchSecretsManager(ctx)
+	default:
+		return fmt.Errorf("Unknown secret provider %q", s.config.Provider)
+	}
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("Secret holds no token")
+	}
+
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+	return nil
+}
+
+// refresh fetches the token every refresh interval, keeping the previous token when that fails
+func (s *secretTokenSource) refresh(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.fetch(ctx); err != nil {
+				slog.Error("token refresh failed", "provider", s.config.Provider, "error", err)
+			}
+		}
+	}
+}
+
+func (s *secretTokenSource) key() string {
+	if s.config.Key == "" {
+		return "token"
+	}
+	return s.config.Key
+}
+
+// fetchVault reads the token from a Vault KV secret, of either version of the engine
+func (s *secretTokenSource) fetchVault(ctx context.Context) (string, error) {
+	address := s.config.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(s.config.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+
+	resp, err := (&http.Client{Transport: githubsvc.OutboundTransport}).Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vault responded %v", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	// version 2 of the KV engine nests the secret in its metadata
+	fields := secret.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		fields = nested
+	}
+	token, _ := fields[s.key()].(string)
+	return token, nil
+}
+
+// fetchSecretsManager reads the token from an AWS Secrets Manager secret, either plain text or a JSON object
+func (s *secretTokenSource) fetchSecretsManager(ctx context.Context) (string, error) {
+	config := aws.NewConfig().WithHTTPClient(&http.Client{Transport: githubsvc.OutboundTransport})
+	if s.config.Region != "" {
+		config = config.WithRegion(s.config.Region)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.config.Path),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	value := aws.StringValue(out.SecretString)
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return strings.TrimSpace(value), nil
+	}
+	return fields[s.key()], nil
+}
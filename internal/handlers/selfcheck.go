@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// SelfCheck reports the datastore's tokens at startup, the configured ones and those mapped to owners: the user
+// each authenticates as, its OAuth scopes and its rate limit. Each token is logged, identified by its position
+// or owner rather than its value, and the invalid tokens and the spent rate limits are returned.
+func (data *Datastore) SelfCheck(ctx context.Context) error {
+	var errs []error
+	if data.app != nil {
+		slog.InfoContext(ctx, "self-check skipped", "check", "token", "reason", "the GitHub App installation tokens are minted on use")
+	} else if len(data.tokens) == 0 {
+		errs = append(errs, checkToken(ctx, "token", data.Client))
+	}
+	for i, token := range data.tokens {
+		name := "token"
+		if len(data.tokens) > 1 {
+			name = "token " + strconv.Itoa(i+1)
+		}
+		errs = append(errs, checkToken(ctx, name, newTokenClient(data.Context, token)))
+	}
+
+	owners := make([]string, 0, len(data.owners))
+	for owner := range data.owners {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		errs = append(errs, checkToken(ctx, "token of "+owner, data.owners[owner]))
+	}
+	return errors.Join(errs...)
+}
+
+// checkToken logs the user, scopes and rate limit of the client's token, returning an error when it's invalid
+// or its rate limit is spent
+func checkToken(ctx context.Context, name string, client *github.Client) error {
+	user, resp, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return fmt.Errorf("Invalid %v: %v", name, err)
+	}
+
+	attrs := []interface{}{"check", "token", "token", name, "login", user.GetLogin(),
+		"limit", resp.Rate.Limit, "remaining", resp.Rate.Remaining, "reset", resp.Rate.Reset.Time}
+	if scopes, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		attrs = append(attrs, "scopes", strings.Join(scopes, ","))
+	}
+	if resp.Rate.Limit > 0 && resp.Rate.Remaining == 0 {
+		slog.ErrorContext(ctx, "self-check failed", attrs...)
+		return fmt.Errorf("The rate limit of %v is spent until %v", name, resp.Rate.Reset.Time)
+	}
+	slog.InfoContext(ctx, "self-check passed", attrs...)
+	return nil
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// SheddingConfig bounds the requests served at once, rejecting those over the limits rather than letting their
+// latency grow unbounded
+type SheddingConfig struct {
+	// MaxInFlight is the number of requests served at once, 0 leaving them unbounded
+	MaxInFlight int `yaml:"max_in_flight"`
+	// MaxQueue is the number of requests waiting for one of them to be done, defaulting to MaxInFlight; -1
+	// rejects them right away
+	MaxQueue int `yaml:"max_queue"`
+	// QueueTimeout is how long the queued requests wait, defaulting to 1s
+	QueueTimeout time.Duration `yaml:"queue_timeout"`
+	// GitHubBudget rejects the requests with a 429 while the rate limits of the tokens are spent, as tracked by
+	// the budget scheduler
+	GitHubBudget bool `yaml:"github_budget"`
+}
+
+// the load shedder's Prometheus metrics, served on /metrics
+var (
+	requestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "github_api_requests_in_flight",
+		Help: "Requests being served, as bounded by the load shedder.",
+	})
+	requestsQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "github_api_requests_queued",
+		Help: "Requests waiting for the load shedder to let them in.",
+	})
+	requestsShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_requests_shed_total",
+		Help: "Requests rejected by the load shedder, by reason: in_flight, queue_timeout or github_budget.",
+	}, []string{"reason"})
+)
+
+// shedKey is the context key marking the requests let in, e.g. for the sub-requests of a batch to be served
+// with their batch's slot
+const shedKey contextKey = "shed"
+
+// LoadShedder rejects the requests once the proxy is saturated with a 503, or once GitHub's rate limits are spent
+// with a 429, both with a Retry-After header
+type LoadShedder struct {
+	config SheddingConfig
+	// ownCredentials reports whether the request is served with the consumer's own credentials, whose rate limit
+	// isn't the proxy's
+	ownCredentials func(r *http.Request) bool
+	// slots holds a value per request served
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+// NewLoadShedder returns the shedder of the configuration, or nil when it sets no limit. ownCredentials reports
+// whether a request is served with the consumer's own credentials, left out of the GitHub budget.
+func NewLoadShedder(config SheddingConfig, ownCredentials func(r *http.Request) bool) *LoadShedder {
+	if config.MaxInFlight <= 0 && !config.GitHubBudget {
+		return nil
+	}
+	if config.MaxQueue == 0 {
+		config.MaxQueue = config.MaxInFlight
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = time.Second
+	}
+	s := &LoadShedder{config: config, ownCredentials: ownCredentials}
+	if config.MaxInFlight > 0 {
+		s.slots = make(chan struct{}, config.MaxInFlight)
+	}
+	return s
+}
+
+// Shed lets the requests in while the proxy and the GitHub rate limits of its tokens allow it. Once every slot is taken, the
+// requests wait in the queue for one to be freed, up to the queue timeout, while the background requests are
+// rejected right away to leave the slots to the interactive ones.
+func (s *LoadShedder) Shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(shedKey) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.config.GitHubBudget && !s.ownCredentials(r) {
+			if wait := githubsvc.Saturated(r.Context()); wait > 0 {
+				requestsShed.WithLabelValues("github_budget").Inc()
+				writeRetryAfter(w, wait)
+				middleware.WriteStatusError(w, http.StatusTooManyRequests, errors.New("The GitHub rate limits are spent"))
+				return
+			}
+		}
+
+		if s.slots != nil {
+			if !s.acquire(r) {
+				writeRetryAfter(w, s.config.QueueTimeout)
+				middleware.WriteStatusError(w, http.StatusServiceUnavailable, errors.New("The proxy is overloaded"))
+				return
+			}
+			requestsInFlight.Inc()
+			defer func() {
+				requestsInFlight.Dec()
+				<-s.slots
+			}()
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), shedKey, true)))
+	})
+}
+
+// acquire takes a slot for the request, waiting in the queue when there's room, and reports whether it got one
+func (s *LoadShedder) acquire(r *http.Request) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if githubsvc.IsBackground(r.Context()) {
+		requestsShed.WithLabelValues("in_flight").Inc()
+		return false
+	}
+	if s.queued.Add(1) > int64(s.config.MaxQueue) {
+		s.queued.Add(-1)
+		requestsShed.WithLabelValues("in_flight").Inc()
+		return false
+	}
+	requestsQueued.Inc()
+	defer func() {
+		s.queued.Add(-1)
+		requestsQueued.Dec()
+	}()
+
+	timer := time.NewTimer(s.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		requestsShed.WithLabelValues("queue_timeout").Inc()
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// writeRetryAfter sets the Retry-After header, in seconds rounded up
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/feckmore/github-api/internal/githubsvc"
+)
+
+// SQSConfig configures sending the webhook events to an AWS SQS queue, when it has a URL
+type SQSConfig struct {
+	QueueURL string `yaml:"queue_url"`
+	Region   string `yaml:"region"`
+	// Events are the patterns of the events sent, defaulting to every event, e.g. "pull_request.opened"
+	Events []string `yaml:"events"`
+}
+
+// sqsSink sends the webhook events to the queue, grouped by repository in FIFO queues so that the events of a
+// repository stay in order
+type sqsSink struct {
+	client   *sqs.SQS
+	queueURL string
+}
+
+// NewSQSSink returns a sink sending the webhook events to the queue, with the default AWS credentials
+func NewSQSSink(config SQSConfig) (WebhookSink, error) {
+	awsConfig := aws.NewConfig().WithHTTPClient(&http.Client{Transport: githubsvc.OutboundTransport})
+	if config.Region != "" {
+		awsConfig = awsConfig.WithRegion(config.Region)
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &sqsSink{client: sqs.New(sess), queueURL: config.QueueURL}, nil
+}
+
+func (s *sqsSink) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.queueURL),
+		MessageBody: aws.String(string(event.Raw)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"event":    {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"delivery": {DataType: aws.String("String"), StringValue: aws.String(event.DeliveryID)},
+		},
+	}
+	if strings.HasSuffix(s.queueURL, ".fifo") {
+		group := event.Repo
+		if group == "" {
+			group = event.Type
+		}
+		input.MessageGroupId = aws.String(group)
+		// GitHub's redeliveries keep their ID
+		input.MessageDeduplicationId = aws.String(event.DeliveryID)
+	}
+	_, err := s.client.SendMessageWithContext(ctx, input)
+	return err
+}
+
+func (s *sqsSink) Close() error {
+	return nil
+}
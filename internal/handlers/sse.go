@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseHeartbeat is the time between the comments keeping idle streams open through proxies
+const sseHeartbeat = 30 * time.Second
+
+// StreamEvents streams the webhook events as server-sent events, selected by the comma separated events, owner,
+// repo and org query parameters, e.g. ?events=pull_request.opened,push&org=my-org. Reconnecting clients sending
+// Last-Event-ID receive the recent events they missed first.
+func StreamEvents(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		after, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+		sub, missed := events.subscribe(queryFilter(r), after)
+		defer events.unsubscribe(sub)
+
+		rc := http.NewResponseController(w)
+		// the stream outlives the server's write timeout
+		rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+
+		for _, streamed := range missed {
+			writeSSE(w, streamed)
+		}
+		rc.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case streamed, ok := <-sub.events:
+				if !ok {
+					// fell behind, the client reconnects with its last ID
+					return
+				}
+				writeSSE(w, streamed)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			if rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// queryFilter returns the filter of the events, owner, repo and org query parameters
+func queryFilter(r *http.Request) EventFilter {
+	list := func(param string) []string {
+		var values []string
+		for _, value := range strings.Split(r.URL.Query().Get(param), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	return EventFilter{Events: list("events"), Owners: list("owner"), Repos: list("repo"), Orgs: list("org")}
+}
+
+// writeSSE writes the event's payload on a single data line, named by the event's type
+func writeSSE(w http.ResponseWriter, streamed *StreamedEvent) {
+	var payload bytes.Buffer
+	if json.Compact(&payload, streamed.Event.Raw) != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", streamed.ID, streamed.Event.Type, payload.Bytes())
+}
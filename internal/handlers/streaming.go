@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+)
+
+// streamErrorTrailer is the trailer set when a streamed list failed after its status was sent, which also keeps
+// the response out of the cache
+const streamErrorTrailer = "X-Stream-Error"
+
+// WriteAll writes every page of a list fetched like FetchAll, streaming the items as the data of the response
+// envelope, or as the whole body when serving raw responses, and flushing each page once written rather than
+// holding the whole list in memory. The walk stops once the consumer goes away.
+//
+// As the status is sent with the first page, an error fetching a later one ends the envelope with an "error"
+// field, or leaves the raw list unterminated for its consumer to fail decoding it, and is set in the
+// X-Stream-Error trailer. The lists only found truncated after the first page was sent are flagged by the
+// X-Truncated trailer. The items are pruned to the fields selected by the ?fields= query parameter; the lists
+// asked for as CSV are buffered.
+func WriteAll[T any](w http.ResponseWriter, r *http.Request, data *Datastore, fetch func(ctx context.Context, opt github.ListOptions) ([]T, *github.Response, error)) {
+	if WantsCSV(r) {
+		items, truncated, err := FetchAll(r.Context(), data, fetch)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteTruncated(w, truncated)
+		WriteJSON(w, r, http.StatusOK, items)
+		return
+	}
+
+	raw, _ := r.Context().Value(rawResponsesKey).(bool)
+	rc := http.NewResponseController(w)
+	// started is set once the status is sent, flagged once the list is flagged truncated, and gone once the
+	// consumer went away
+	var started, flagged, gone bool
+	write := func(b []byte) error {
+		_, err := w.Write(b)
+		gone = gone || err != nil
+		return err
+	}
+	truncated, err := walkPages(r.Context(), data, fetch, func(items []T, truncated bool) error {
+		separator := []byte(",")
+		if !started {
+			started, flagged = true, truncated
+			WriteTruncated(w, truncated)
+			trailers := streamErrorTrailer
+			if !truncated {
+				trailers += ", X-Truncated"
+			}
+			w.Header().Set("Trailer", trailers)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			separator = []byte("[")
+			if !raw {
+				separator = []byte(`{"data":[`)
+			}
+		}
+
+		for _, item := range items {
+			b, err := json.Marshal(SelectFields(r, item))
+			if err != nil {
+				return err
+			}
+			if err := write(append(separator, b...)); err != nil {
+				return err
+			}
+			separator = []byte(",")
+		}
+		if separator[0] != ',' {
+			// the first page is empty
+			if err := write(separator); err != nil {
+				return err
+			}
+		}
+		rc.Flush()
+		return nil
+	})
+	if !started {
+		WriteError(w, err)
+		return
+	}
+	if gone || r.Context().Err() != nil {
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "streaming failed", "error", err)
+		w.Header().Set(streamErrorTrailer, err.Error())
+		if !raw {
+			writeEnvelopeEnd(w, r, err)
+		}
+		return
+	}
+	if !flagged {
+		WriteTruncated(w, truncated)
+	}
+	if raw {
+		w.Write([]byte("]\n"))
+		return
+	}
+	writeEnvelopeEnd(w, r, nil)
+}
+
+// writeEnvelopeEnd closes the data of the streamed envelope, followed by the error it failed with, the rate limit
+// and the request ID
+func writeEnvelopeEnd(w http.ResponseWriter, r *http.Request, failed error) {
+	end := struct {
+		Error     string             `json:"error,omitempty"`
+		RateLimit *EnvelopeRateLimit `json:"rate_limit,omitempty"`
+		RequestID string             `json:"request_id,omitempty"`
+	}{RequestID: middleware.RequestIDFrom(r.Context())}
+	if failed != nil {
+		end.Error = failed.Error()
+	}
+	if limit, remaining, reset, ok := middleware.GitHubRateLimit(r.Context()); ok {
+		end.RateLimit = &EnvelopeRateLimit{Limit: limit, Remaining: remaining, Reset: reset}
+	}
+	b, _ := json.Marshal(&end)
+	if len(b) > 2 {
+		// the fields follow the data, without their opening brace
+		b[0] = ','
+	} else {
+		b = b[1:]
+	}
+	w.Write(append(append([]byte("]"), b...), '\n'))
+}
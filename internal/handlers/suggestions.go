@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/githubsvc"
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
+)
+
+// hunkHeader matches the header of a hunk of a unified diff, capturing the first line and count of the new file
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// SuggestChange comments the pull request with a suggestion replacing the lines of the file with the text, e.g.
+// {"path": "main.go", "start_line": 10, "line": 12, "suggestion": "return nil", "body": "Simpler"}, which the
+// author can commit from GitHub. The comment is on the right side of the diff at the pull request's head, unless
+// commit_id is set, and the lines must be in a single hunk of the file's diff. The path is only rejected as
+// unchanged once every file of the pull request was listed, answering 503 when they're too many to be.
+func SuggestChange(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		number, _ := strconv.Atoi(vars["number"])
+
+		in := new(suggestionRequest)
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			middleware.WriteStatusError(w, http.StatusBadRequest, err)
+			return
+		}
+		if WriteError(w, in.validate()) {
+			return
+		}
+
+		if in.CommitID == "" {
+			pull, _, err := svc.GetPull(r.Context(), owner, repo, number)
+			if WriteError(w, err) {
+				return
+			}
+			in.CommitID = pull.GetHead().GetSHA()
+		}
+		files, truncated, err := FetchAll(r.Context(), data, func(ctx context.Context, opt github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+			opt.PerPage = maxPerPage
+			return svc.ListPullFiles(ctx, owner, repo, number, &opt)
+		})
+		if WriteError(w, err) {
+			return
+		}
+		changed, err := in.inDiff(files)
+		if !changed && truncated {
+			middleware.WriteStatusError(w, http.StatusServiceUnavailable, errors.New("The pull request's files are too many to find the path among them"))
+			return
+		}
+		if WriteError(w, err) {
+			return
+		}
+
+		comment := &githubsvc.ReviewComment{
+			Body:     github.String(suggestionBody(in.Body, in.Suggestion)),
+			CommitID: github.String(in.CommitID),
+			Path:     github.String(in.Path),
+			Line:     github.Int(in.Line),
+			Side:     github.String("RIGHT"),
+		}
+		if in.StartLine > 0 && in.StartLine < in.Line {
+			comment.StartLine, comment.StartSide = github.Int(in.StartLine), github.String("RIGHT")
+		}
+		cmt, _, err := svc.CreatePullComment(r.Context(), owner, repo, number, comment)
+		if WriteError(w, err) {
+			return
+		}
+
+		WriteJSON(w, r, http.StatusCreated, cmt)
+	}
+}
+
+// suggestionRequest is the body of the suggested changes
+type suggestionRequest struct {
+	Path string `json:"path"`
+	// StartLine defaults to Line, suggesting to replace a single line
+	StartLine  int    `json:"start_line"`
+	Line       int    `json:"line"`
+	Suggestion string `json:"suggestion"`
+	// Body is the comment above the suggestion, if any
+	Body     string `json:"body"`
+	CommitID string `json:"commit_id"`
+}
+
+func (in *suggestionRequest) validate() error {
+	v := &validator{}
+	v.required("path", in.Path)
+	v.atLeast("line", in.Line, 1)
+	if in.StartLine != 0 && in.StartLine > in.Line {
+		v.fail("start_line", "can't be after line")
+	}
+	// an empty suggestion deletes the lines
+	v.maxLength("suggestion", in.Suggestion, maxCommentLength)
+	v.maxLength("body", in.Body, maxCommentLength)
+	if in.CommitID != "" {
+		v.sha("commit_id", in.CommitID)
+	}
+	return v.err()
+}
+
+// inDiff returns a validation error unless the lines are in a single hunk of the file's diff, as GitHub requires,
+// and whether the file is among the files
+func (in *suggestionRequest) inDiff(files []*github.CommitFile) (changed bool, err error) {
+	first := in.StartLine
+	if first == 0 {
+		first = in.Line
+	}
+	for _, file := range files {
+		if file.GetFilename() != in.Path {
+			continue
+		}
+		for _, line := range strings.Split(file.GetPatch(), "\n") {
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(m[1])
+			count := 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			if first >= start && in.Line < start+count {
+				return true, nil
+			}
+		}
+		return true, ValidationError{{Field: "line", Message: "must be in a hunk of the file's diff"}}
+	}
+	return false, ValidationError{{Field: "path", Message: "isn't changed by the pull request"}}
+}
+
+// suggestionBody returns the comment's body followed by the suggestion block, fenced with more backticks than
+// the suggestion's longest run of them
+func suggestionBody(body, suggestion string) string {
+	fence := "```"
+	for strings.Contains(suggestion, fence) {
+		fence += "`"
+	}
+
+	var b strings.Builder
+	if body != "" {
+		b.WriteString(body)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(fence + "suggestion\n")
+	if suggestion != "" {
+		b.WriteString(strings.TrimSuffix(suggestion, "\n") + "\n")
+	}
+	b.WriteString(fence)
+	return b.String()
+}
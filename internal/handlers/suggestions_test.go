@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSuggestChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPages int
+		body     string
+		status   int
+		contains string
+	}{
+		{name: "suggestion created", body: `{"path": "main.go", "line": 11, "suggestion": "y := 3"}`,
+			status: http.StatusCreated, contains: "```suggestion\\ny := 3\\n```"},
+		{name: "line out of the hunks", body: `{"path": "main.go", "line": 30, "suggestion": "y := 3"}`,
+			status: http.StatusBadRequest, contains: "must be in a hunk of the file's diff"},
+		{name: "path on the last page", body: `{"path": "util.go", "line": 40, "suggestion": ""}`,
+			status: http.StatusBadRequest, contains: "must be in a hunk of the file's diff"},
+		{name: "path unchanged", body: `{"path": "README.md", "line": 1, "suggestion": "Hello"}`,
+			status: http.StatusBadRequest, contains: "isn't changed by the pull request"},
+		{name: "path unchanged among the files listed", maxPages: 1, body: `{"path": "README.md", "line": 1, "suggestion": "Hello"}`,
+			status: http.StatusServiceUnavailable, contains: "too many"},
+		{name: "path past the files listed", maxPages: 1, body: `{"path": "util.go", "line": 2, "suggestion": ""}`,
+			status: http.StatusServiceUnavailable, contains: "too many"},
+		{name: "path among the files listed", maxPages: 1, body: `{"path": "main.go", "line": 30, "suggestion": "y := 3"}`,
+			status: http.StatusBadRequest, contains: "must be in a hunk of the file's diff"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router, _ := newTestRouter(t, "suggestions.json", func(data *Datastore) { data.pagination.MaxPages = test.maxPages })
+			resp, body := serve(router, "POST", "/v1/octocat/repos/hello-world/pulls/1/suggestions", test.body, nil)
+			if resp.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, test.status, body)
+			}
+			if !strings.Contains(body, test.contains) {
+				t.Errorf("body missing %v: %s", test.contains, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// TokenInfo describes the token used to serve a request
+type TokenInfo struct {
+	Login     string      `json:"login"`
+	Scopes    []string    `json:"scopes"`
+	RateLimit github.Rate `json:"rate_limit"`
+	// ExpiresAt is only set for tokens with an expiration, e.g. fine-grained personal access tokens
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// expirationLayout is the format of the GitHub-Authentication-Token-Expiration header
+const expirationLayout = "2006-01-02 15:04:05 MST"
+
+// GetTokenInfo returns the authenticated login, granted scopes, rate limit and expiry of the request's token
+func GetTokenInfo(data *Datastore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc, err := data.ServiceFor(r)
+		if WriteError(w, err) {
+			return
+		}
+
+		user, resp, err := svc.GetUser(r.Context(), "")
+		if WriteError(w, err) {
+			return
+		}
+
+		info := TokenInfo{
+			Login:     user.GetLogin(),
+			Scopes:    []string{},
+			RateLimit: resp.Rate,
+		}
+		for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				info.Scopes = append(info.Scopes, scope)
+			}
+		}
+		if expiration := resp.Header.Get("GitHub-Authentication-Token-Expiration"); expiration != "" {
+			if t, err := time.Parse(expirationLayout, expiration); err == nil {
+				info.ExpiresAt = &t
+			}
+		}
+
+		WriteJSON(w, r, http.StatusOK, info)
+	}
+}
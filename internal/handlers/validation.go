@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/feckmore/github-api/internal/middleware"
+)
+
+// maxCommentLength is the longest comment body GitHub accepts
+const maxCommentLength = 65536
+
+// shaPattern matches the hex commit SHAs, abbreviated to 7 characters at least, SHA-1 or SHA-256
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// ValidationError lists the invalid fields of a request, answered with a 400 detailing each field rather
+// than GitHub's opaque 422
+type ValidationError []middleware.FieldError
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Field + " " + err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validator collects the field errors of a request
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) fail(field, message string) {
+	v.errs = append(v.errs, middleware.FieldError{Field: field, Message: message})
+}
+
+// required checks the value isn't blank
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+		return false
+	}
+	return true
+}
+
+// sha checks the value is a commit SHA
+func (v *validator) sha(field, value string) {
+	if v.required(field, value) && !shaPattern.MatchString(value) {
+		v.fail(field, "must be a hex commit SHA")
+	}
+}
+
+// maxLength checks the value has at most max characters
+func (v *validator) maxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.fail(field, "must be at most "+strconv.Itoa(max)+" characters")
+	}
+}
+
+// atLeast checks the value is at least min
+func (v *validator) atLeast(field string, value, min int) {
+	if value < min {
+		v.fail(field, "must be at least "+strconv.Itoa(min))
+	}
+}
+
+// oneOf checks the value is one of the choices
+func (v *validator) oneOf(field, value string, choices ...string) {
+	for _, choice := range choices {
+		if value == choice {
+			return
+		}
+	}
+	v.fail(field, "must be one of "+strings.Join(choices, ", "))
+}
+
+// err returns the ValidationError of the field errors, or nil without any
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Webhooks is the registry of the webhook handlers, which register from their own files, e.g.
+//
+//	func init() {
+//	    Webhooks.Handle("pull_request.opened", WebhookHandlerFunc(labelPullRequest), OnlyOrgs("my-org"))
+//	}
+var Webhooks = NewWebhookRegistry()
+
+// WebhookHandler handles the webhook events it's registered for
+type WebhookHandler interface {
+	HandleWebhook(ctx context.Context, event *WebhookEvent) error
+}
+
+// WebhookHandlerFunc is a function handling webhook events
+type WebhookHandlerFunc func(ctx context.Context, event *WebhookEvent) error
+
+func (f WebhookHandlerFunc) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	return f(ctx, event)
+}
+
+// WebhookSink is a handler forwarding the events to another system, e.g. Kafka
+type WebhookSink interface {
+	WebhookHandler
+	Close() error
+}
+
+// WebhookMiddleware wraps a webhook handler, e.g. to filter its events
+type WebhookMiddleware func(WebhookHandler) WebhookHandler
+
+// WebhookRegistry dispatches the webhook events to the handlers registered for their type and action
+type WebhookRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string][]WebhookHandler
+}
+
+// NewWebhookRegistry returns an empty registry
+func NewWebhookRegistry() *WebhookRegistry {
+	return &WebhookRegistry{handlers: map[string][]WebhookHandler{}}
+}
+
+// clone returns a registry with the same handlers, for more to be registered
+func (reg *WebhookRegistry) clone() *WebhookRegistry {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	cloned := NewWebhookRegistry()
+	for pattern, handlers := range reg.handlers {
+		cloned.handlers[pattern] = append([]WebhookHandler(nil), handlers...)
+	}
+	return cloned
+}
+
+// Handle registers the handler, wrapped in the middleware, for the events matching the pattern: a type and
+// action, e.g. "pull_request.opened", a type for all its actions, e.g. "pull_request", or "*" for every event
+func (reg *WebhookRegistry) Handle(pattern string, handler WebhookHandler, middleware ...WebhookMiddleware) {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.handlers[pattern] = append(reg.handlers[pattern], handler)
+}
+
+// handleAll registers the handler for each of the patterns, or for every event without any
+func (reg *WebhookRegistry) handleAll(patterns []string, handler WebhookHandler) {
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	for _, pattern := range patterns {
+		reg.Handle(pattern, handler)
+	}
+}
+
+// Dispatch hands the event to every matching handler, returning their errors
+func (reg *WebhookRegistry) Dispatch(ctx context.Context, event *WebhookEvent) error {
+	patterns := []string{"*", event.Type}
+	if event.Action != "" {
+		patterns = append(patterns, event.Type+"."+event.Action)
+	}
+
+	reg.mu.RLock()
+	var handlers []WebhookHandler
+	for _, pattern := range patterns {
+		handlers = append(handlers, reg.handlers[pattern]...)
+	}
+	reg.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		slog.DebugContext(ctx, "webhook not handled", "event", event.Type, "action", event.Action, "delivery", event.DeliveryID)
+		return nil
+	}
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler.HandleWebhook(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OnlyRepos passes the handlers the events of the repositories only, given by their full names, e.g.
+// "octocat/hello-world"
+func OnlyRepos(repos ...string) WebhookMiddleware {
+	return onlyMatching(func(event *WebhookEvent) string { return event.Repo }, repos)
+}
+
+// OnlyOrgs passes the handlers the events of the orgs only
+func OnlyOrgs(orgs ...string) WebhookMiddleware {
+	return onlyMatching(func(event *WebhookEvent) string { return event.Org }, orgs)
+}
+
+// onlyMatching passes the handlers the events whose field is one of the values, ignoring case as GitHub does
+func onlyMatching(field func(*WebhookEvent) string, values []string) WebhookMiddleware {
+	return func(next WebhookHandler) WebhookHandler {
+		return WebhookHandlerFunc(func(ctx context.Context, event *WebhookEvent) error {
+			for _, value := range values {
+				if strings.EqualFold(field(event), value) {
+					return next.HandleWebhook(ctx, event)
+				}
+			}
+			return nil
+		})
+	}
+}
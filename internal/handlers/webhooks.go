@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/feckmore/github-api/internal/middleware"
+	"github.com/google/go-github/github"
+)
+
+// maxWebhookPayload is the largest payload GitHub delivers
+const maxWebhookPayload = 25 << 20
+
+// WebhooksConfig configures receiving GitHub's webhooks on /webhooks/github
+type WebhooksConfig struct {
+	// Secret is the webhooks' secret, signing their payloads (env GITHUB_WEBHOOK_SECRET); the receiver is
+	// disabled without one, as is the pipeline unless it polls orgs
+	Secret string `yaml:"secret"`
+	// Kafka, NATS and SQS publish the events to these systems, and Forward posts them to internal endpoints
+	Kafka   KafkaConfig     `yaml:"kafka"`
+	NATS    NATSConfig      `yaml:"nats"`
+	SQS     SQSConfig       `yaml:"sqs"`
+	Forward []ForwardConfig `yaml:"forward"`
+	// Store keeps the received events, for their history and their replay to the sinks
+	Store EventStoreConfig `yaml:"store"`
+	// Rules decide which events are forwarded to the sinks, dropped or transformed, in order
+	Rules []WebhookRule `yaml:"rules"`
+	// Labels label the opened issues and pull requests
+	Labels []LabelRule `yaml:"labels"`
+	// Welcome comments the opened pull requests
+	Welcome WelcomeConfig `yaml:"welcome"`
+	// Poll polls the events of the orgs without webhooks, dispatching them like the webhooks
+	Poll PollConfig `yaml:"poll"`
+}
+
+// WebhookEvent is a webhook delivered by GitHub
+type WebhookEvent struct {
+	// Type is the event's type, e.g. "pull_request", and Action its action when it has one, e.g. "opened"
+	Type       string
+	Action     string
+	DeliveryID string
+	// Repo and Org are the full name of the repository and the login of the org of the event, when it has one
+	Repo string
+	Org  string
+	// Payload is the go-github event, e.g. *github.PullRequestEvent, or the raw JSON payload for the types
+	// go-github doesn't know
+	Payload interface{}
+	Raw     json.RawMessage
+}
+
+// WebhookDispatcher hands the webhook events to their handlers
+type WebhookDispatcher interface {
+	Dispatch(ctx context.Context, event *WebhookEvent) error
+}
+
+// WebhookPipeline records the received events in the store, when configured, and dispatches them to the
+// registered Webhooks handlers, the event streams and the configured sinks, as decided by the rules
+type WebhookPipeline struct {
+	// store keeps the events for their history and replay, when configured
+	store EventStore
+	// handlers are the handlers of every event, and sinks the handlers the stored events are replayed to
+	handlers *WebhookRegistry
+	sinks    *WebhookRegistry
+	closers  []WebhookSink
+}
+
+// NewWebhookPipeline opens the configured store and sinks, closed by Close. The automations, e.g. labelling the
+// opened issues, call GitHub with the datastore's clients.
+func NewWebhookPipeline(data *Datastore, config WebhooksConfig) (*WebhookPipeline, error) {
+	if err := validateRules(config.Rules); err != nil {
+		return nil, err
+	}
+	p := &WebhookPipeline{handlers: Webhooks.clone(), sinks: NewWebhookRegistry()}
+	p.handlers.Handle("*", events)
+	if len(config.Labels) > 0 {
+		labeler, err := newLabeler(data, config.Labels)
+		if err != nil {
+			return nil, err
+		}
+		labeler.register(p.handlers)
+	}
+	if config.Welcome.Comment != "" {
+		welcomer, err := newWelcomer(data, config.Welcome)
+		if err != nil {
+			return nil, err
+		}
+		p.handlers.Handle("pull_request.opened", welcomer)
+	}
+	add := func(sink WebhookSink, patterns []string) {
+		p.closers = append(p.closers, sink)
+		var handler WebhookHandler = sink
+		if len(config.Rules) > 0 {
+			handler = ApplyRules(config.Rules)(sink)
+		}
+		p.handlers.handleAll(patterns, handler)
+		p.sinks.handleAll(patterns, handler)
+	}
+
+	if len(config.Kafka.Brokers) > 0 {
+		add(NewKafkaSink(config.Kafka), config.Kafka.Events)
+	}
+	if config.NATS.URL != "" {
+		sink, err := NewNATSSink(config.NATS)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		add(sink, config.NATS.Events)
+	}
+	if config.SQS.QueueURL != "" {
+		sink, err := NewSQSSink(config.SQS)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		add(sink, config.SQS.Events)
+	}
+	for _, forward := range config.Forward {
+		add(NewForwardSink(forward), forward.Events)
+	}
+	if config.Store.Driver != "" {
+		store, err := NewEventStore(config.Store, data.storage)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.store = store
+	}
+	return p, nil
+}
+
+// Dispatch records the event, failing its delivery when it can't be recorded for GitHub to redeliver it, and
+// hands it to the handlers
+func (p *WebhookPipeline) Dispatch(ctx context.Context, event *WebhookEvent) error {
+	if p.store != nil {
+		if err := p.store.Save(ctx, event); err != nil {
+			return err
+		}
+	}
+	return p.handlers.Dispatch(ctx, event)
+}
+
+// Replay hands the stored event to the sinks again, e.g. after their outage
+func (p *WebhookPipeline) Replay(ctx context.Context, event *WebhookEvent) error {
+	return p.sinks.Dispatch(ctx, event)
+}
+
+// Close closes the store and the sinks
+func (p *WebhookPipeline) Close() {
+	for _, sink := range p.closers {
+		sink.Close()
+	}
+	if p.store != nil {
+		p.store.Close()
+	}
+}
+
+// ReceiveWebhook validates the X-Hub-Signature-256 signature of GitHub's webhook deliveries against the secret,
+// and hands the parsed events to the dispatcher
+func ReceiveWebhook(secret []byte, dispatcher WebhookDispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+		if middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+		if !validSignature(r.Header.Get("X-Hub-Signature-256"), payload, secret) {
+			middleware.WriteStatusError(w, http.StatusUnauthorized, errors.New("Invalid X-Hub-Signature-256 signature"))
+			return
+		}
+
+		event := &WebhookEvent{
+			Type:       github.WebHookType(r),
+			DeliveryID: github.DeliveryID(r),
+			Raw:        payload,
+		}
+		if event.Type == "" {
+			middleware.WriteStatusError(w, http.StatusBadRequest, errors.New("The X-GitHub-Event header is required"))
+			return
+		}
+		var fields struct {
+			Action     string `json:"action"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			Organization struct {
+				Login string `json:"login"`
+			} `json:"organization"`
+		}
+		if middleware.WriteStatusError(w, http.StatusBadRequest, json.Unmarshal(payload, &fields)) {
+			return
+		}
+		event.Action, event.Repo, event.Org = fields.Action, fields.Repository.FullName, fields.Organization.Login
+		if event.Payload, err = parsePayload(event.Type, payload); middleware.WriteStatusError(w, http.StatusBadRequest, err) {
+			return
+		}
+
+		if WriteError(w, dispatcher.Dispatch(r.Context(), event)) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parsePayload returns the go-github event of the payload, or the raw payload for the types go-github doesn't
+// know
+func parsePayload(eventType string, payload []byte) (interface{}, error) {
+	parsed, err := github.ParseWebHook(eventType, payload)
+	if err != nil && strings.HasPrefix(err.Error(), "unknown X-Github-Event") {
+		return json.RawMessage(payload), nil
+	}
+	return parsed, err
+}
+
+// validSignature reports whether the signature, "sha256=" followed by the hex HMAC of the payload, is the
+// secret's
+func validSignature(signature string, payload, secret []byte) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	mac, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha256.New, secret)
+	h.Write(payload)
+	return hmac.Equal(mac, h.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+// recordingDispatcher records the events dispatched
+type recordingDispatcher struct {
+	events []*WebhookEvent
+}
+
+func (d *recordingDispatcher) Dispatch(ctx context.Context, event *WebhookEvent) error {
+	d.events = append(d.events, event)
+	return nil
+}
+
+// sign returns the X-Hub-Signature-256 of the payload
+func sign(secret, payload string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+func TestReceiveWebhook(t *testing.T) {
+	const secret = "s3cret"
+	const payload = `{"action": "created", "repository": {"full_name": "octocat/hello-world"}, "comment": {"id": 1}}`
+
+	tests := []struct {
+		name       string
+		event      string
+		signature  string
+		status     int
+		dispatched bool
+	}{
+		{name: "signed", event: "issue_comment", signature: sign(secret, payload), status: http.StatusNoContent, dispatched: true},
+		{name: "unsigned", event: "issue_comment", status: http.StatusUnauthorized},
+		{name: "signed with another secret", event: "issue_comment", signature: sign("other", payload), status: http.StatusUnauthorized},
+		{name: "signed with SHA-1", event: "issue_comment", signature: "sha1=0123456789abcdef", status: http.StatusUnauthorized},
+		{name: "malformed signature", event: "issue_comment", signature: "sha256=zz", status: http.StatusUnauthorized},
+		{name: "without event type", signature: sign(secret, payload), status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dispatcher := &recordingDispatcher{}
+			header := map[string]string{"X-GitHub-Event": test.event, "X-GitHub-Delivery": "72d3162e", "X-Hub-Signature-256": test.signature}
+			resp, body := serve(ReceiveWebhook([]byte(secret), dispatcher), "POST", "/webhooks/github", payload, header)
+			if resp.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, test.status, body)
+			}
+			if dispatched := len(dispatcher.events) > 0; dispatched != test.dispatched {
+				t.Fatalf("dispatched %v, want %v", dispatched, test.dispatched)
+			}
+			if test.dispatched {
+				event := dispatcher.events[0]
+				if event.Type != test.event || event.Action != "created" || event.Repo != "octocat/hello-world" || event.DeliveryID != "72d3162e" {
+					t.Errorf("dispatched %+v", event)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsPing is the time between the pings detecting the dead connections, closed after wsPong without a pong
+	wsPing = 30 * time.Second
+	wsPong = 2 * wsPing
+	// wsWrite is how long a message has to reach the client
+	wsWrite = 10 * time.Second
+)
+
+// wsRequest is a message of the client: a subscription to the events selected by the filter, resumed after the
+// ID of the last event it received, e.g. {"type": "subscribe", "subscription": "prs", "owners": ["my-org"],
+// "events": ["pull_request"], "last_event_id": 42}, or the unsubscription of a subscription
+type wsRequest struct {
+	Type         string `json:"type"`
+	Subscription string `json:"subscription"`
+	EventFilter
+	LastEventID uint64 `json:"last_event_id"`
+}
+
+// wsMessage is a message to the client: an event of a subscription, the confirmation of a subscription or of an
+// unsubscription, or an error
+type wsMessage struct {
+	Type         string          `json:"type"`
+	Subscription string          `json:"subscription,omitempty"`
+	ID           uint64          `json:"id,omitempty"`
+	Event        string          `json:"event,omitempty"`
+	Action       string          `json:"action,omitempty"`
+	Repo         string          `json:"repo,omitempty"`
+	Org          string          `json:"org,omitempty"`
+	DeliveryID   string          `json:"delivery_id,omitempty"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// SubscribeEvents pushes the webhook events over a WebSocket to the subscriptions the client makes on it, each
+// named by the client and resumed after the ID of the last event it received, e.g. after reconnecting
+func SubscribeEvents(data *Datastore) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host) || data.cors.Allows(origin)
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the connection outlives the server's write timeout
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		conn, err := upgrader.Upgrade(hijacker(w), r, nil)
+		if err != nil {
+			// the upgrader answered the error
+			return
+		}
+		defer conn.Close()
+
+		ws := &wsSession{out: make(chan wsMessage, 64), done: make(chan struct{}), subscriptions: map[string]*wsSubscription{}}
+		defer ws.close()
+		go ws.read(conn)
+
+		ping := time.NewTicker(wsPing)
+		defer ping.Stop()
+		for {
+			select {
+			case message := <-ws.out:
+				conn.SetWriteDeadline(time.Now().Add(wsWrite))
+				if conn.WriteJSON(message) != nil {
+					return
+				}
+			case <-ping.C:
+				if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWrite)) != nil {
+					return
+				}
+			case <-ws.done:
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// hijacker returns the underlying writer taking over the connection, as the upgrader doesn't unwrap the writers
+// of the middlewares like http.ResponseController does
+func hijacker(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		if _, ok := w.(http.Hijacker); ok {
+			return w
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return w
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// wsSession is a client's connection and its subscriptions. Its messages are written by a single goroutine,
+// as the connections don't support concurrent writers.
+type wsSession struct {
+	out  chan wsMessage
+	done chan struct{}
+
+	mu            sync.Mutex
+	closed        bool
+	subscriptions map[string]*wsSubscription
+}
+
+// wsSubscription forwards the events of the hub's subscription, stopped when unsubscribed
+type wsSubscription struct {
+	stop chan struct{}
+}
+
+// read handles the client's requests until the connection is closed
+func (ws *wsSession) read(conn *websocket.Conn) {
+	defer close(ws.done)
+	conn.SetReadDeadline(time.Now().Add(wsPong))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPong))
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request wsRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			ws.send(wsMessage{Type: "error", Message: "Invalid message: " + err.Error()})
+			continue
+		}
+
+		switch {
+		case request.Subscription == "":
+			ws.send(wsMessage{Type: "error", Message: "The subscription is required"})
+		case request.Type == "subscribe":
+			ws.subscribe(request)
+			ws.send(wsMessage{Type: "subscribed", Subscription: request.Subscription})
+		case request.Type == "unsubscribe":
+			ws.unsubscribe(request.Subscription)
+			ws.send(wsMessage{Type: "unsubscribed", Subscription: request.Subscription})
+		default:
+			ws.send(wsMessage{Type: "error", Subscription: request.Subscription, Message: "Unknown message type " + request.Type})
+		}
+	}
+}
+
+// subscribe replaces the subscription of the same name, forwarding the events after the last one received
+func (ws *wsSession) subscribe(request wsRequest) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return
+	}
+	if previous, ok := ws.subscriptions[request.Subscription]; ok {
+		close(previous.stop)
+	}
+	subscription := &wsSubscription{stop: make(chan struct{})}
+	ws.subscriptions[request.Subscription] = subscription
+	go ws.forward(request.Subscription, request.EventFilter, request.LastEventID, subscription.stop)
+}
+
+func (ws *wsSession) unsubscribe(name string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if subscription, ok := ws.subscriptions[name]; ok {
+		close(subscription.stop)
+		delete(ws.subscriptions, name)
+	}
+}
+
+// close stops the subscriptions once the connection is closed
+func (ws *wsSession) close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.closed = true
+	for name, subscription := range ws.subscriptions {
+		close(subscription.stop)
+		delete(ws.subscriptions, name)
+	}
+}
+
+// forward sends the events of the hub's subscription until stopped, resubscribing after the last event sent when
+// the hub drops the subscription for falling behind
+func (ws *wsSession) forward(name string, filter EventFilter, after uint64, stop chan struct{}) {
+	for {
+		sub, missed := events.subscribe(filter, after)
+		for _, streamed := range missed {
+			if !ws.push(name, streamed, stop) {
+				events.unsubscribe(sub)
+				return
+			}
+			after = streamed.ID
+		}
+
+	receive:
+		for {
+			select {
+			case streamed, ok := <-sub.events:
+				if !ok {
+					break receive
+				}
+				if !ws.push(name, streamed, stop) {
+					events.unsubscribe(sub)
+					return
+				}
+				after = streamed.ID
+			case <-stop:
+				events.unsubscribe(sub)
+				return
+			}
+		}
+	}
+}
+
+// push queues the event of the subscription, reporting false once stopped or the connection is closed
+func (ws *wsSession) push(name string, streamed *StreamedEvent, stop chan struct{}) bool {
+	select {
+	case ws.out <- eventMessage(name, streamed):
+		return true
+	case <-stop:
+	case <-ws.done:
+	}
+	return false
+}
+
+// send queues the message, unless the connection is closed
+func (ws *wsSession) send(message wsMessage) {
+	select {
+	case ws.out <- message:
+	case <-ws.done:
+	}
+}
+
+func eventMessage(subscription string, streamed *StreamedEvent) wsMessage {
+	event := streamed.Event
+	return wsMessage{
+		Type:         "event",
+		Subscription: subscription,
+		ID:           streamed.ID,
+		Event:        event.Type,
+		Action:       event.Action,
+		Repo:         event.Repo,
+		Org:          event.Org,
+		DeliveryID:   event.DeliveryID,
+		Payload:      event.Raw,
+	}
+}
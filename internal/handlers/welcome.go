@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// WelcomeConfig configures the comment welcoming the authors of the opened pull requests
+type WelcomeConfig struct {
+	// Comment is the template of the comment, e.g. "Thanks @{{.Author}}!", the bot being disabled without one
+	Comment string `yaml:"comment"`
+	// FirstTimeOnly only welcomes the authors of their first pull request to the repository
+	FirstTimeOnly bool `yaml:"first_time_only"`
+}
+
+// firstTimeAssociations are the author associations of GitHub's first time contributors
+var firstTimeAssociations = map[string]bool{"FIRST_TIMER": true, "FIRST_TIME_CONTRIBUTOR": true}
+
+// welcomer comments the opened pull requests
+type welcomer struct {
+	data          *Datastore
+	comment       *CommentTemplate
+	firstTimeOnly bool
+}
+
+func newWelcomer(data *Datastore, config WelcomeConfig) (*welcomer, error) {
+	comment, err := ParseCommentTemplate("welcome", config.Comment)
+	if err != nil {
+		return nil, err
+	}
+	return &welcomer{data: data, comment: comment, firstTimeOnly: config.FirstTimeOnly}, nil
+}
+
+func (b *welcomer) HandleWebhook(ctx context.Context, event *WebhookEvent) error {
+	payload, ok := event.Payload.(*github.PullRequestEvent)
+	if !ok {
+		return nil
+	}
+	pull := payload.GetPullRequest()
+	if b.firstTimeOnly && !firstTimeAssociations[pull.GetAuthorAssociation()] {
+		return nil
+	}
+
+	owner, repo, _ := strings.Cut(event.Repo, "/")
+	vars := CommentVars{
+		Owner:  owner,
+		Repo:   repo,
+		Number: payload.GetNumber(),
+		Author: pull.GetUser().GetLogin(),
+		Title:  pull.GetTitle(),
+	}
+	if b.comment.files {
+		var err error
+		if vars.Files, err = pullFiles(ctx, b.data, event.Repo, vars.Number); err != nil {
+			return err
+		}
+	}
+	body, err := b.comment.Render(vars)
+	if err != nil {
+		return err
+	}
+
+	svc, err := b.data.ServiceForOwner(owner)
+	if err != nil {
+		return err
+	}
+	_, _, err = svc.CreateIssueComment(ctx, owner, repo, vars.Number, &github.IssueComment{Body: github.String(body)})
+	return err
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/errgroup"
+)
+
+// WorkersConfig bounds the fan-outs' tasks run at once across the requests, e.g. fetching the pages of the lists
+// walked with ?all=true, serving the batches' sub-requests or listing the hooks of an org's repositories
+type WorkersConfig struct {
+	// Size is the number of tasks run at once, defaulting to 32
+	Size int `yaml:"size"`
+}
+
+// the worker pool's Prometheus metrics, served on /metrics
+var (
+	workersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "github_api_workers_busy",
+		Help: "Fan-out tasks running on a worker.",
+	})
+	workersWaiting = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "github_api_workers_waiting",
+		Help: "Fan-out tasks waiting for a free worker.",
+	})
+	workerTasks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_worker_tasks_total",
+		Help: "Fan-out tasks run, by fan-out.",
+	}, []string{"fan_out"})
+)
+
+// workerKey is the context key marking the contexts of the fan-outs' tasks
+const workerKey contextKey = "worker"
+
+// WorkerPool runs the fan-outs' tasks on a bounded number of workers shared by the requests. A nil pool runs
+// them as soon as their group's limit allows.
+type WorkerPool struct {
+	workers chan struct{}
+}
+
+// NewWorkerPool returns a pool of the configured number of workers
+func NewWorkerPool(config WorkersConfig) *WorkerPool {
+	if config.Size <= 0 {
+		config.Size = 32
+	}
+	return &WorkerPool{workers: make(chan struct{}, config.Size)}
+}
+
+// WorkerGroup is the tasks of a fan-out, which fails with its first failed task
+type WorkerGroup struct {
+	pool   *WorkerPool
+	fanOut string
+	ctx    context.Context
+	group  *errgroup.Group
+	// nested is set for the fan-outs of a task, e.g. the pages walked by a batch's sub-request, whose tasks run
+	// on their parent's worker rather than wait for one held by their parent
+	nested bool
+}
+
+// Group returns the group of tasks of the named fan-out, at most limit of them running at once, and the context
+// of the tasks, cancelled once one fails or the group is waited for
+func (p *WorkerPool) Group(ctx context.Context, fanOut string, limit int) (*WorkerGroup, context.Context) {
+	nested := ctx.Value(workerKey) != nil
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(max(limit, 1))
+	ctx = context.WithValue(ctx, workerKey, true)
+	return &WorkerGroup{pool: p, fanOut: fanOut, ctx: ctx, group: group, nested: nested}, ctx
+}
+
+// Go runs the task on a worker once the group's limit allows it, blocking meanwhile, and a worker is free
+func (g *WorkerGroup) Go(task func() error) {
+	g.group.Go(func() error {
+		if g.pool != nil && !g.nested {
+			workersWaiting.Inc()
+			select {
+			case g.pool.workers <- struct{}{}:
+				workersWaiting.Dec()
+			case <-g.ctx.Done():
+				workersWaiting.Dec()
+				return g.ctx.Err()
+			}
+			defer func() { <-g.pool.workers }()
+		}
+		workersBusy.Inc()
+		defer workersBusy.Dec()
+		workerTasks.WithLabelValues(g.fanOut).Inc()
+		return task()
+	})
+}
+
+// Wait waits for the group's tasks, returning the first error
+func (g *WorkerGroup) Wait() error {
+	return g.group.Wait()
+}
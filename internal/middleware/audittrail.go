@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AuditConfig configures the audit trail of the write requests, which is disabled without a sink
+type AuditConfig struct {
+	// Sink is "file", "sqlite", "storage" for the shared storage, or "syslog"
+	Sink string `yaml:"sink"`
+	// Path is the file the events are appended to as JSON lines, or the SQLite database
+	Path string `yaml:"path"`
+	// Network and Address are the syslog server, defaulting to the local one
+	Network string `yaml:"network"`
+	Address string `yaml:"address"`
+}
+
+// AuditEvent is the record of a write request made on behalf of a consumer
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Consumer  string    `json:"consumer"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Path      string    `json:"path"`
+	Owner     string    `json:"owner,omitempty"`
+	Repo      string    `json:"repo,omitempty"`
+	// PayloadSHA256 is the hex encoded digest of the request body, which isn't recorded itself
+	PayloadSHA256 string `json:"payload_sha256"`
+	Status        int    `json:"status"`
+	// GitHubStatus is the status of the last GitHub response, or 0 when GitHub wasn't called
+	GitHubStatus int `json:"github_status,omitempty"`
+}
+
+// AuditSink appends audit events to a store
+type AuditSink interface {
+	Record(event *AuditEvent) error
+	Close() error
+}
+
+// NewAuditSink opens the configured sink, the storage being the shared one when configured
+func NewAuditSink(config AuditConfig, storage *Database) (AuditSink, error) {
+	switch config.Sink {
+	case "file":
+		f, err := os.OpenFile(config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &writerSink{w: f}, nil
+	case "sqlite":
+		return newSQLSink(OpenDatabase("sqlite", config.Path, nil))
+	case "storage":
+		return newSQLSink(OpenDatabase("storage", "", storage))
+	case "syslog":
+		w, err := syslog.Dial(config.Network, config.Address, syslog.LOG_INFO|syslog.LOG_AUTH, "github-api")
+		if err != nil {
+			return nil, err
+		}
+		return &writerSink{w: w}, nil
+	}
+	return nil, fmt.Errorf("Unknown audit sink %q", config.Sink)
+}
+
+// writerSink writes the events as JSON lines, to a file or syslog
+type writerSink struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+func (s *writerSink) Record(event *AuditEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+func (s *writerSink) Close() error {
+	return s.w.Close()
+}
+
+// sqlSink inserts the events into the audit_events table of a SQLite or Postgres database, which is created
+// when missing
+type sqlSink struct {
+	db *Database
+}
+
+func newSQLSink(db *Database, err error) (*sqlSink, error) {
+	if err != nil {
+		return nil, err
+	}
+	err = db.Migrate(`CREATE TABLE IF NOT EXISTS audit_events (
+		id ` + db.AutoIncrement() + `,
+		time TIMESTAMP NOT NULL,
+		request_id TEXT NOT NULL,
+		consumer TEXT NOT NULL,
+		method TEXT NOT NULL,
+		route TEXT NOT NULL,
+		path TEXT NOT NULL,
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		payload_sha256 TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		github_status INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlSink{db: db}, nil
+}
+
+func (s *sqlSink) Record(event *AuditEvent) error {
+	_, err := s.db.Exec(s.db.Bind(`INSERT INTO audit_events
+		(time, request_id, consumer, method, route, path, owner, repo, payload_sha256, status, github_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		event.Time, event.RequestID, event.Consumer, event.Method, event.Route, event.Path, event.Owner, event.Repo,
+		event.PayloadSHA256, event.Status, event.GitHubStatus)
+	return err
+}
+
+func (s *sqlSink) Close() error {
+	return s.db.Close()
+}
+
+// AuditWrites records every write request to the sink once served. Failing to record is logged, as the write
+// has already been made.
+func AuditWrites(sink AuditSink) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !IsWrite(r.Method) || DryRunFrom(r.Context()) != nil {
+				// the dry runs write nothing
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if WriteStatusError(w, http.StatusBadRequest, err) {
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			digest := sha256.Sum256(body)
+
+			// the GitHub status is recorded by the GitHub client, in the request log
+			entry, ok := r.Context().Value(requestLogKey).(*requestLog)
+			if !ok {
+				entry = &requestLog{}
+			}
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			vars := mux.Vars(r)
+			route, _ := mux.CurrentRoute(r).GetPathTemplate()
+			owner := vars["owner"]
+			if owner == "" {
+				owner = vars["org"]
+			}
+			entry.mu.Lock()
+			githubStatus := entry.githubStatus
+			entry.mu.Unlock()
+
+			err = sink.Record(&AuditEvent{
+				Time:          time.Now().UTC(),
+				RequestID:     RequestIDFrom(r.Context()),
+				Consumer:      ConsumerIdentity(r),
+				Method:        r.Method,
+				Route:         route,
+				Path:          r.URL.Path,
+				Owner:         owner,
+				Repo:          vars["repo"],
+				PayloadSHA256: hex.EncodeToString(digest[:]),
+				Status:        rec.status,
+				GitHubStatus:  githubStatus,
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "recording audit event failed", "error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// CacheStore keeps cached values by key, e.g. GitHub responses
+type CacheStore interface {
+	// Get returns the value of the key, unless missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores the value of the key for the ttl, or until evicted when the ttl is 0
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// ClaimStore is a CacheStore claiming keys atomically, for a single one of the replicas sharing it to act on a key
+type ClaimStore interface {
+	CacheStore
+	// Claim sets the value of the key for the ttl unless it's already set, reporting whether it was set
+	Claim(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes the key
+	Delete(ctx context.Context, key string)
+}
+
+// memoryCache is an in-process CacheStore evicting the least recently used entries beyond its maximum
+type memoryCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns an in-process cache of up to maxEntries values, or unbounded when maxEntries is 0
+func NewMemoryCache(maxEntries int) ClaimStore {
+	return &memoryCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, ttl)
+}
+
+func (c *memoryCache) Claim(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		if entry.expires.IsZero() || time.Now().Before(entry.expires) {
+			return false, nil
+		}
+	}
+	c.set(key, value, ttl)
+	return true, nil
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// set stores the value of the key, with the lock held
+func (c *memoryCache) set(key string, value []byte, ttl time.Duration) {
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// sqlCache is a ClaimStore keeping the values in a table of a SQLite or Postgres database, shared by the replicas
+// using it
+type sqlCache struct {
+	db         *Database
+	table      string
+	maxEntries int
+
+	mu sync.Mutex
+	// pruned is when the expired and evicted entries were last deleted
+	pruned time.Time
+}
+
+// NewSQLCache returns a cache keeping up to maxEntries values in the table of the database, e.g. "cache_entries",
+// or unbounded when maxEntries is 0, creating its table when missing
+func NewSQLCache(db *Database, table string, maxEntries int) (ClaimStore, error) {
+	err := db.Migrate(`CREATE TABLE IF NOT EXISTS `+table+` (
+		key TEXT PRIMARY KEY,
+		value `+db.Blob()+` NOT NULL,
+		stored_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP
+	)`, `CREATE INDEX IF NOT EXISTS `+table+`_stored_at ON `+table+` (stored_at)`)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlCache{db: db, table: table, maxEntries: maxEntries}, nil
+}
+
+func (c *sqlCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	var value []byte
+	var expires sql.NullTime
+	err := c.db.QueryRowContext(ctx, c.db.Bind(`SELECT value, expires_at FROM `+c.table+` WHERE key = ?`), key).
+		Scan(&value, &expires)
+	if err != nil || expires.Valid && time.Now().After(expires.Time) {
+		// misses and unavailability alike fall back to GitHub
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *sqlCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	now := time.Now().UTC()
+	var expires *time.Time
+	if ttl > 0 {
+		at := now.Add(ttl)
+		expires = &at
+	}
+	_, err := c.db.ExecContext(ctx, c.db.Bind(`INSERT INTO `+c.table+` (key, value, stored_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, stored_at = excluded.stored_at, expires_at = excluded.expires_at`),
+		key, value, now, expires)
+	if err != nil {
+		return
+	}
+
+	// the expired entries, and the oldest ones beyond the maximum, are deleted once a minute rather than on every set
+	c.mu.Lock()
+	prune := now.Sub(c.pruned) > time.Minute
+	if prune {
+		c.pruned = now
+	}
+	c.mu.Unlock()
+	if !prune {
+		return
+	}
+	c.db.ExecContext(ctx, c.db.Bind(`DELETE FROM `+c.table+` WHERE expires_at < ?`), now)
+	if c.maxEntries > 0 {
+		c.db.ExecContext(ctx, c.db.Bind(`DELETE FROM `+c.table+` WHERE key NOT IN
+			(SELECT key FROM `+c.table+` ORDER BY stored_at DESC LIMIT ?)`), c.maxEntries)
+	}
+}
+
+func (c *sqlCache) Claim(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	var expires *time.Time
+	if ttl > 0 {
+		at := now.Add(ttl)
+		expires = &at
+	}
+	// the unique key claims it, an expired value being replaced
+	result, err := c.db.ExecContext(ctx, c.db.Bind(`INSERT INTO `+c.table+` (key, value, stored_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, stored_at = excluded.stored_at, expires_at = excluded.expires_at
+		WHERE `+c.table+`.expires_at < ?`),
+		key, value, now, expires, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (c *sqlCache) Delete(ctx context.Context, key string) {
+	c.db.ExecContext(ctx, c.db.Bind(`DELETE FROM `+c.table+` WHERE key = ?`), key)
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the body size below which responses aren't worth compressing
+const compressMinSize = 1024
+
+// Compress compresses the JSON, NDJSON and text responses of at least compressMinSize bytes with gzip or deflate, as
+// negotiated by the Accept-Encoding header, e.g. long lists of commits or search results
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		// the WebSocket connections are taken over by their handler
+		if encoding == "" || r.Method == "HEAD" || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.Close()
+	})
+}
+
+// acceptedEncoding returns gzip or deflate when accepted, preferring gzip, or an empty string otherwise
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		segments := strings.Split(part, ";")
+		q := 1.0
+		for _, param := range segments[1:] {
+			if value := strings.TrimSpace(param); strings.HasPrefix(value, "q=") {
+				q, _ = strconv.ParseFloat(value[len("q="):], 64)
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(segments[0]))] = q > 0
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressible reports whether the response is JSON, NDJSON or text, and not encoded already
+func compressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		// the events must reach the clients as they're written
+		return false
+	}
+	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "application/x-ndjson") ||
+		strings.HasPrefix(contentType, "text/")
+}
+
+// compressWriter buffers the start of the body until it's known to be large enough to compress
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+
+	buf     []byte
+	decided bool
+	// enc compresses the body, once decided to
+	enc io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	if !compressible(w.Header()) {
+		w.start(false)
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= compressMinSize {
+		w.start(true)
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.enc.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start writes the response header, compressing the body from then on when compress is set
+func (w *compressWriter) start(compress bool) {
+	w.decided = true
+	if compress {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+		if w.encoding == "gzip" {
+			w.enc = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			// the level is valid, so there's no error
+			w.enc, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Flush sends the body written so far, e.g. of streamed responses
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(false)
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close writes the small bodies left uncompressed, or the end of the compressed ones
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		w.start(false)
+		_, err := w.ResponseWriter.Write(w.buf)
+		return err
+	}
+	if w.enc != nil {
+		return w.enc.Close()
+	}
+	return nil
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
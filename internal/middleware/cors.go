@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures which browser origins may call the proxy
+type CORSConfig struct {
+	// AllowedOrigins are the allowed origins, e.g. "https://dashboard.example.com", or "*" for any origin
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// AllowedMethods and AllowedHeaders default to the methods and headers used by the routes
+	AllowedMethods []string `yaml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration `yaml:"max_age"`
+	// AllowCredentials lets browsers send their cookies, which they refuse to with any origin allowed
+	AllowCredentials bool `yaml:"allow_credentials"`
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-API-Version", "X-GitHub-Token", "X-Request-ID", "X-Request-Priority", "Prefer", "Last-Event-ID", "Idempotency-Key"}
+	// exposedHeaders are the response headers readable by the browser scripts
+	exposedHeaders = []string{"X-API-Version", "X-Next-Cursor", "X-Prev-Cursor", "Link", "X-First-Page", "X-Prev-Page",
+		"X-Next-Page", "X-Last-Page", "X-Truncated", "X-Request-ID", "X-Cache", "Location", "Idempotent-Replayed",
+		"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Proxy-RateLimit-Limit", "X-Proxy-RateLimit-Remaining"}
+)
+
+// CORS answers preflight requests and adds the CORS headers to the responses to allowed origins. Requests
+// from other origins are served without the headers, so browsers block their responses. With "*" allowed, any
+// origin is allowed as a literal *, without the credentials.
+func CORS(config CORSConfig) func(http.Handler) http.Handler {
+	wildcard := config.wildcard()
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	maxAge := config.MaxAge
+	if maxAge == 0 {
+		maxAge = 10 * time.Minute
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !config.Allows(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Add("Vary", "Origin")
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials && !wildcard {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Allows reports whether browsers may call the proxy from the origin
+func (config CORSConfig) Allows(origin string) bool {
+	for _, allowed := range config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error when the credentials are allowed along with any origin
+func (config CORSConfig) Validate() error {
+	if config.AllowCredentials && config.wildcard() {
+		return errors.New("The CORS credentials can't be allowed with any origin, \"*\"")
+	}
+	return nil
+}
+
+// wildcard reports whether any origin is allowed
+func (config CORSConfig) wildcard() bool {
+	for _, allowed := range config.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// dryRunKey is the request context key of the request's *DryRun
+const dryRunKey contextKey = "dryRun"
+
+// DryRunRequest is a GitHub write recorded by a dry run instead of being sent
+type DryRunRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body is the JSON body of the request, or its text when it isn't JSON
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// DryRun records the GitHub writes of a request served as a dry run
+type DryRun struct {
+	mu       sync.Mutex
+	requests []*DryRunRequest
+}
+
+// WithDryRun returns a context whose GitHub writes are recorded in its DryRun rather than sent
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey, &DryRun{})
+}
+
+// DryRunFrom returns the request's DryRun, or nil if it isn't one
+func DryRunFrom(ctx context.Context) *DryRun {
+	d, _ := ctx.Value(dryRunKey).(*DryRun)
+	return d
+}
+
+// Record records the write instead of sending it
+func (d *DryRun) Record(req *DryRunRequest) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, req)
+}
+
+// Requests returns the writes recorded, in order
+func (d *DryRun) Requests() []*DryRunRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*DryRunRequest{}, d.requests...)
+}
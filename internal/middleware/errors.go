@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/go-github/github"
+)
+
+// FieldError is the problem of a field of a request, in its body or path
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorBody is the JSON body of the error responses
+type ErrorBody struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+	// GitHub is GitHub's description of the error, when the request failed there
+	GitHub *GitHubError `json:"github,omitempty"`
+	// Fields are the invalid fields of the request
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// GitHubError is the error response of a failed GitHub call
+type GitHubError struct {
+	Status           int            `json:"status"`
+	Message          string         `json:"message"`
+	Errors           []github.Error `json:"errors,omitempty"`
+	DocumentationURL string         `json:"documentation_url,omitempty"`
+}
+
+// WriteStatusError writes err as a JSON error body with the given status code and the request's ID, returning
+// true if there was an error
+func WriteStatusError(w http.ResponseWriter, status int, err error) bool {
+	if err != nil {
+		WriteErrorBody(w, status, &ErrorBody{Error: err.Error()})
+		return true
+	}
+	return false
+}
+
+// WriteErrorBody writes the error body, identified by the request's ID set by RequestID
+func WriteErrorBody(w http.ResponseWriter, status int, body *ErrorBody) {
+	body.RequestID = w.Header().Get("X-Request-ID")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	oidc "github.com/coreos/go-oidc"
+)
+
+// JWTConfig configures the validation of bearer tokens issued by an OIDC identity provider
+type JWTConfig struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// JWKSURL is the url of the issuer's signing keys, which is discovered from the issuer when empty
+	JWKSURL string `yaml:"jwks_url"`
+}
+
+// Claims are the claims of a validated bearer token
+type Claims map[string]interface{}
+
+// claimsKey is the request context key of the validated token's Claims
+const claimsKey contextKey = "claims"
+
+// ClaimsFrom returns the claims of the request's bearer token, or nil if it had none
+func ClaimsFrom(ctx context.Context) Claims {
+	claims, _ := ctx.Value(claimsKey).(Claims)
+	return claims
+}
+
+// Subject returns the "sub" claim identifying the token's principal
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// NewJWTVerifier returns the verifier of the tokens issued for the configured audience
+func NewJWTVerifier(ctx context.Context, config JWTConfig) (*oidc.IDTokenVerifier, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("JWT validation requires an issuer and an audience")
+	}
+	oidcConfig := &oidc.Config{ClientID: config.Audience}
+	if config.JWKSURL != "" {
+		return oidc.NewVerifier(config.Issuer, oidc.NewRemoteKeySet(ctx, config.JWKSURL), oidcConfig), nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Verifier(oidcConfig), nil
+}
+
+// RequireJWT rejects requests without a valid "Authorization: Bearer" token, exposing the token's claims to
+// the handlers. When optional is set, requests without a bearer token are passed on to be authenticated
+// otherwise, e.g. with an API key.
+func RequireJWT(verifier *oidc.IDTokenVerifier, optional bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/auth/") || strings.HasPrefix(r.URL.Path, "/webhooks/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			parts := strings.Fields(r.Header.Get("Authorization"))
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+				if optional {
+					next.ServeHTTP(w, r)
+					return
+				}
+				WriteStatusError(w, http.StatusUnauthorized, errors.New("A bearer token is required"))
+				return
+			}
+
+			token, err := verifier.Verify(r.Context(), parts[1])
+			if WriteStatusError(w, http.StatusUnauthorized, err) {
+				return
+			}
+			claims := Claims{}
+			if err := token.Claims(&claims); WriteStatusError(w, http.StatusUnauthorized, err) {
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+		})
+	}
+}
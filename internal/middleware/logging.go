@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ConfigureLogging logs JSON lines to stderr from the level on, one of "debug", "info", "warn" or "error". The
+// standard logger's messages are logged at the info level.
+func ConfigureLogging(level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("Invalid log level %q", level)
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})))
+	return nil
+}
+
+const requestLogKey contextKey = "requestLog"
+
+// requestLog collects the details of a request known only to the inner handlers, for LogRequests
+type requestLog struct {
+	mu    sync.Mutex
+	owner string
+	repo  string
+	// githubStatus is the status of the request's last GitHub response
+	githubStatus int
+	// the GitHub rate limit headers of the request's last GitHub response
+	rateLimit     string
+	rateRemaining string
+	rateReset     string
+}
+
+// GitHubRateLimit returns the GitHub rate limit of the request's last GitHub response, or false when it had
+// none
+func GitHubRateLimit(ctx context.Context) (limit, remaining int, reset int64, ok bool) {
+	entry, ok := ctx.Value(requestLogKey).(*requestLog)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	remaining, err := strconv.Atoi(entry.rateRemaining)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	limit, _ = strconv.Atoi(entry.rateLimit)
+	reset, _ = strconv.ParseInt(entry.rateReset, 10, 64)
+	return limit, remaining, reset, true
+}
+
+// LogRequests logs every request once served, with its method, path, owner and repo, status, latency, and the
+// GitHub rate limit remaining after it. Server errors are logged at the error level.
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestLogKey, entry)))
+
+		entry.mu.Lock()
+		defer entry.mu.Unlock()
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("request_id", RequestIDFrom(r.Context())),
+		}
+		if entry.owner != "" {
+			attrs = append(attrs, slog.String("owner", entry.owner))
+		}
+		if entry.repo != "" {
+			attrs = append(attrs, slog.String("repo", entry.repo))
+		}
+		if entry.rateRemaining != "" {
+			attrs = append(attrs, slog.Group("github_rate_limit",
+				slog.String("limit", entry.rateLimit),
+				slog.String("remaining", entry.rateRemaining),
+				slog.String("reset", entry.rateReset),
+			))
+		}
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// RecordRoute records the owner and repo of the matched route for LogRequests, and on the request's span
+func RecordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		span := trace.SpanFromContext(r.Context())
+		if route, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		for attr, name := range map[string]string{"github.owner": "owner", "github.org": "org", "github.repo": "repo"} {
+			if value := vars[name]; value != "" {
+				span.SetAttributes(attribute.String(attr, value))
+			}
+		}
+
+		if entry, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+			entry.mu.Lock()
+			entry.owner = vars["owner"]
+			if entry.owner == "" {
+				entry.owner = vars["org"]
+			}
+			entry.repo = vars["repo"]
+			entry.mu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimitRecorder records the status and rate limit headers of GitHub's responses for LogRequests
+type RateLimitRecorder struct {
+	Base http.RoundTripper
+}
+
+func (t *RateLimitRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if entry, ok := req.Context().Value(requestLogKey).(*requestLog); ok {
+		entry.mu.Lock()
+		entry.githubStatus = resp.StatusCode
+		if resp.Header.Get("X-RateLimit-Remaining") != "" {
+			entry.rateLimit = resp.Header.Get("X-RateLimit-Limit")
+			entry.rateRemaining = resp.Header.Get("X-RateLimit-Remaining")
+			entry.rateReset = resp.Header.Get("X-RateLimit-Reset")
+		}
+		entry.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// statusRecorder records the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streamed archives
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
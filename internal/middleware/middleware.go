@@ -0,0 +1,73 @@
+// Package middleware authenticates, authorizes, rate limits, logs and traces the proxy's requests, and writes
+// their error responses.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// RequireClientCert rejects write requests made without a verified TLS client certificate
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsWrite(r.Method) && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			WriteStatusError(w, http.StatusForbidden, errors.New("A client certificate is required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestTimeout cancels the request's context after the timeout, aborting its outbound GitHub calls. The
+// context is also cancelled when the client disconnects. A timeout of 0 disables it, e.g. for streams.
+func RequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Recover answers the requests whose handler panicked with a JSON 500 error, and logs the panic with its stack
+// trace, instead of the server dropping the connection
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// deliberately aborted, e.g. when the consumer went away mid-stream
+				panic(p)
+			}
+			slog.ErrorContext(r.Context(), "handler panicked",
+				"panic", fmt.Sprint(p),
+				"stack", string(debug.Stack()),
+				"request_id", RequestIDFrom(r.Context()),
+			)
+			WriteStatusError(w, http.StatusInternalServerError, errors.New("Internal server error"))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsWrite reports whether the method modifies resources
+func IsWrite(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// budgetStore keeps the token bucket of each consumer
+type budgetStore interface {
+	// take takes a token from the consumer's bucket, returning how long to wait for one when empty, and the
+	// tokens left
+	take(ctx context.Context, key string) (time.Duration, float64, error)
+}
+
+// consumerLimiter rate limits each consumer with its own token bucket, in memory
+type consumerLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*limiterEntry
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterIdleTimeout is how long the bucket of a consumer without requests is kept
+const limiterIdleTimeout = 10 * time.Minute
+
+// RateLimit rejects requests exceeding the consumer's rate with a 429 and a Retry-After header, and reports the
+// consumer's budget in the X-Proxy-RateLimit-Limit and X-Proxy-RateLimit-Remaining headers. Consumers are
+// identified by ConsumerIdentity, or by their IP address when anonymous.
+// The buckets are shared by the replicas through Redis, when configured.
+func RateLimit(config RateLimitConfig, redis *RedisStore) func(http.Handler) http.Handler {
+	burst := config.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(config.RequestsPerSecond))
+	}
+	var budgets budgetStore = &consumerLimiter{
+		limit:    rate.Limit(config.RequestsPerSecond),
+		burst:    burst,
+		limiters: map[string]*limiterEntry{},
+	}
+	if redis != nil {
+		budgets = &redisBudgets{store: redis, interval: time.Duration(float64(time.Second) / config.RequestsPerSecond), burst: burst}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			delay, remaining, err := budgets.take(r.Context(), consumerKeyFor(r))
+			if err != nil {
+				// consumers aren't locked out while Redis is unavailable
+				slog.ErrorContext(r.Context(), "rate limiting failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-Proxy-RateLimit-Limit", strconv.Itoa(burst))
+			w.Header().Set("X-Proxy-RateLimit-Remaining", strconv.Itoa(int(math.Max(0, remaining))))
+			if delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				WriteStatusError(w, http.StatusTooManyRequests, errors.New("Rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take drops the buckets of idle consumers along the way
+func (l *consumerLimiter) take(ctx context.Context, key string) (time.Duration, float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := l.limiters[key]
+	if !ok {
+		for k, e := range l.limiters {
+			if now.Sub(e.lastSeen) > limiterIdleTimeout {
+				delete(l.limiters, k)
+			}
+		}
+		entry = &limiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	reservation := entry.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+	return delay, entry.limiter.TokensAt(now), nil
+}
+
+// ForwardRateLimit copies the rate limit headers of the request's last GitHub response onto the response, for
+// consumers to back off before the shared token runs out
+func ForwardRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+			w = &rateLimitWriter{ResponseWriter: w, entry: entry}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitWriter sets the GitHub rate limit headers right before the response headers are written
+type rateLimitWriter struct {
+	http.ResponseWriter
+	entry       *requestLog
+	wroteHeader bool
+}
+
+func (w *rateLimitWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.entry.mu.Lock()
+		if w.entry.rateRemaining != "" {
+			w.Header().Set("X-RateLimit-Limit", w.entry.rateLimit)
+			w.Header().Set("X-RateLimit-Remaining", w.entry.rateRemaining)
+			w.Header().Set("X-RateLimit-Reset", w.entry.rateReset)
+		}
+		w.entry.mu.Unlock()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *rateLimitWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *rateLimitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// consumerKeyFor returns the key of the consumer's bucket
+func consumerKeyFor(r *http.Request) string {
+	if identity := ConsumerIdentity(r); identity != "" {
+		return "consumer:" + identity
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimitConfig configures the rate limiting of the proxy's consumers, which is disabled without a rate
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained rate allowed per consumer, Burst the requests allowed at once
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+)
+
+// roles granted to consumers, each one including the permissions of the previous
+const (
+	RoleReadOnly = "read-only"
+	RoleComment  = "comment"
+	RoleAdmin    = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleReadOnly: 1,
+	RoleComment:  2,
+	RoleAdmin:    3,
+}
+
+// ValidRole reports whether the role is one of the roles granted to consumers
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// writeRoles are the roles needed for the write routes of each group, which default to RoleAdmin.
+// Read routes only need RoleReadOnly.
+var writeRoles = map[string]string{
+	"comments": RoleComment,
+}
+
+// Authorize rejects requests from consumers whose role doesn't allow calling the group's routes. Consumers
+// are identified by ConsumerIdentity, and missing from roles are granted defaultRole.
+func Authorize(roles map[string]string, defaultRole, group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := RoleReadOnly
+			if IsWrite(r.Method) {
+				required = RoleAdmin
+				if role, ok := writeRoles[group]; ok {
+					required = role
+				}
+			}
+
+			if roleLevels[consumerRole(r, roles, defaultRole)] < roleLevels[required] {
+				WriteStatusError(w, http.StatusForbidden, errors.New("The "+required+" role is required"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole rejects requests from consumers without the role, whatever their method. Unlike Authorize, the
+// consumers have no role when there are no roles nor default role.
+func RequireRole(roles map[string]string, defaultRole, required string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if roleLevels[consumerRole(r, roles, defaultRole)] < roleLevels[required] {
+				WriteStatusError(w, http.StatusForbidden, errors.New("The "+required+" role is required"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// consumerRole returns the role of the consumer making the request, defaultRole when it has none
+func consumerRole(r *http.Request, roles map[string]string, defaultRole string) string {
+	if identity := ConsumerIdentity(r); identity != "" {
+		if granted, ok := roles[identity]; ok {
+			return granted
+		}
+	}
+	return defaultRole
+}
+
+// ConsumerIdentity returns the identity of the consumer making the request: the name of its API key or the
+// subject of its bearer token, or an empty string when anonymous
+func ConsumerIdentity(r *http.Request) string {
+	if key := ConsumerFrom(r.Context()); key != nil {
+		return key.Name
+	}
+	if claims := ClaimsFrom(r.Context()); claims != nil {
+		return claims.Subject()
+	}
+	return ""
+}
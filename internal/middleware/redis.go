@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig configures the Redis server shared by the replicas of the proxy, for the caches, the consumer rate
+// limits and the coordination of the GitHub calls
+type RedisConfig struct {
+	// URL is the server, e.g. "redis://:password@localhost:6379/0"
+	URL string `yaml:"url"`
+	// Prefix namespaces the keys, defaulting to "github-api:"
+	Prefix string `yaml:"prefix"`
+}
+
+// redisDefaultTTL is how long the values set without a ttl are kept, as Redis doesn't evict by count
+const redisDefaultTTL = 24 * time.Hour
+
+// RedisStore is a ClaimStore shared by the replicas
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the Redis server
+func NewRedisStore(config RedisConfig) (*RedisStore, error) {
+	options, err := redis.ParseURL(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "github-api:"
+	}
+	return &RedisStore{client: redis.NewClient(options), prefix: prefix}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool) {
+	b, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err != nil {
+		// misses and unavailability alike fall back to GitHub
+		return nil, false
+	}
+	return b, true
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = redisDefaultTTL
+	}
+	s.client.Set(ctx, s.prefix+key, value, ttl)
+}
+
+func (s *RedisStore) Claim(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = redisDefaultTTL
+	}
+	return s.client.SetNX(ctx, s.prefix+key, value, ttl).Result()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) {
+	s.client.Del(ctx, s.prefix+key)
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// redisBudgets keeps the consumers' token buckets in Redis, with the generic cell rate algorithm: a consumer's
+// key holds the theoretical arrival time of its next request, which may run ahead of now by up to the burst
+type redisBudgets struct {
+	store *RedisStore
+	// interval is the time between two requests at the sustained rate
+	interval time.Duration
+	burst    int
+}
+
+// gcra takes a token from the bucket of KEYS[1], given the interval, burst and now in milliseconds. It returns
+// the milliseconds to wait for a token, or 0, and the tokens left.
+var gcra = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tat = math.max(tonumber(redis.call("GET", KEYS[1]) or now), now)
+local allowed_at = tat - (burst - 1) * interval
+if allowed_at > now then
+	return {math.ceil(allowed_at - now), 0}
+end
+tat = tat + interval
+redis.call("SET", KEYS[1], tat, "PX", math.ceil(tat - now))
+return {0, math.floor((now + burst * interval - tat) / interval)}
+`)
+
+func (b *redisBudgets) take(ctx context.Context, key string) (time.Duration, float64, error) {
+	interval := math.Max(1, float64(b.interval.Milliseconds()))
+	now := time.Now().UnixMilli()
+	res, err := gcra.Run(ctx, b.store.client, []string{b.store.prefix + "ratelimit:" + key}, interval, b.burst, now).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(res[0]) * time.Millisecond, float64(res[1]), nil
+}
+
+// shareRateLimit merges the rate limit of KEYS[1] reported by a response, ARGV[1] calls left until the reset
+// ARGV[2] in seconds, with the one kept: the fewest calls left of the same window, or the later window. It
+// returns the merged rate limit, kept until its reset.
+var shareRateLimit = redis.NewScript(`
+local remaining = tonumber(ARGV[1])
+local reset = tonumber(ARGV[2])
+local kept = redis.call("HMGET", KEYS[1], "remaining", "reset")
+local kept_remaining, kept_reset = tonumber(kept[1]), tonumber(kept[2])
+if kept_reset then
+	if kept_reset == reset then
+		remaining = math.min(remaining, kept_remaining)
+	elseif kept_reset > reset then
+		remaining, reset = kept_remaining, kept_reset
+	end
+end
+redis.call("HSET", KEYS[1], "remaining", remaining, "reset", reset)
+redis.call("EXPIREAT", KEYS[1], reset + 60)
+return {remaining, reset}
+`)
+
+// ShareRateLimit merges the rate limit of a token reported by a GitHub response with the ones the replicas saw,
+// keyed by the token's hash, and returns the merged calls left and reset
+func (s *RedisStore) ShareRateLimit(ctx context.Context, key string, remaining int, reset time.Time) (int, time.Time, error) {
+	res, err := shareRateLimit.Run(ctx, s.client, []string{s.prefix + "github-ratelimit:" + key}, remaining, reset.Unix()).Int64Slice()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return int(res[0]), time.Unix(res[1], 0), nil
+}
+
+// SharedRateLimit returns the rate limit of the token's hash the replicas saw last, or false when none did
+func (s *RedisStore) SharedRateLimit(ctx context.Context, key string) (int, time.Time, bool) {
+	values, err := s.client.HMGet(ctx, s.prefix+"github-ratelimit:"+key, "remaining", "reset").Result()
+	if err != nil || values[0] == nil || values[1] == nil {
+		return 0, time.Time{}, false
+	}
+	remaining, err := strconv.Atoi(fmt.Sprint(values[0]))
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	reset, err := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return remaining, time.Unix(reset, 0), true
+}
+
+// reserveTurn reserves the next turn of KEYS[1], ARGV[1] milliseconds after the previous one and not before the
+// pause of KEYS[2], given now in milliseconds. It returns the milliseconds to wait for the turn.
+var reserveTurn = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local turn = math.max(tonumber(redis.call("GET", KEYS[1]) or 0), tonumber(redis.call("GET", KEYS[2]) or 0), now)
+redis.call("SET", KEYS[1], turn + interval, "PX", math.ceil(turn + interval - now))
+return turn - now
+`)
+
+// ReserveTurn reserves the next turn of the replicas' writes to a repository, spaced by the interval and held
+// back by PauseTurns, and returns how long to wait for it
+func (s *RedisStore) ReserveTurn(ctx context.Context, key string, interval time.Duration) (time.Duration, error) {
+	keys := []string{s.prefix + "pacing:" + key, s.prefix + "pacing-paused"}
+	wait, err := reserveTurn.Run(ctx, s.client, keys, max(interval.Milliseconds(), 1), time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// pauseTurns holds back the turns until ARGV[1] in milliseconds, unless KEYS[1] already holds them back longer
+var pauseTurns = redis.NewScript(`
+local until_ms = tonumber(ARGV[1])
+local paused = tonumber(redis.call("GET", KEYS[1]) or 0)
+if until_ms > paused then
+	redis.call("SET", KEYS[1], until_ms, "PX", math.max(until_ms - tonumber(ARGV[2]), 1))
+end
+return 0
+`)
+
+// PauseTurns holds back the replicas' writes until the given time, e.g. after a secondary rate limit
+func (s *RedisStore) PauseTurns(ctx context.Context, until time.Time) error {
+	return pauseTurns.Run(ctx, s.client, []string{s.prefix + "pacing-paused"}, until.UnixMilli(), time.Now().UnixMilli()).Err()
+}
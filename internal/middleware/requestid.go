@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const requestIDKey contextKey = "requestID"
+
+// RequestID identifies every request with the consumer's X-Request-ID, or a generated one when missing or
+// invalid. The ID is echoed in the response, logged, and forwarded on the outbound GitHub calls.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		// set before the handlers run, for WriteStatusError to include it in error bodies
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// RequestIDFrom returns the ID of the request, set by RequestID
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// validRequestID reports whether the consumer's ID is short printable ASCII, safe to log and forward
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDTransport forwards the request's ID to GitHub
+type RequestIDTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := RequestIDFrom(req.Context()); id != "" {
+		// RoundTrippers must not modify the original request
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Request-ID", id)
+	}
+	return t.Base.RoundTrip(req)
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// StorageConfig configures the database shared by the persistent stores: the audit trail, the webhook events,
+// the jobs, the repository inventory and the response cache, each using it when its driver is "storage"
+type StorageConfig struct {
+	// Driver is "sqlite", the default, or "postgres", and DSN the SQLite database's path or the Postgres
+	// connection string; the storage is configured when DSN is set
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+// Database is a SQLite or Postgres database, whose tables are created by the stores using it. It's closed once
+// closed by each of the stores it's shared with.
+type Database struct {
+	*sql.DB
+	Postgres bool
+	refs     atomic.Int32
+}
+
+// OpenStorage opens the configured storage, or returns nil when it isn't configured
+func OpenStorage(config StorageConfig) (*Database, error) {
+	if config.DSN == "" {
+		return nil, nil
+	}
+	if config.Driver == "" {
+		config.Driver = "sqlite"
+	}
+	return OpenDatabase(config.Driver, config.DSN, nil)
+}
+
+// OpenDatabase opens the database of a "sqlite" or "postgres" driver, or shares the storage for the "storage"
+// driver
+func OpenDatabase(driver, dsn string, storage *Database) (*Database, error) {
+	switch driver {
+	case "storage":
+		if storage == nil {
+			return nil, errors.New("The storage driver requires the storage to be configured")
+		}
+		storage.refs.Add(1)
+		return storage, nil
+	case "sqlite", "postgres":
+	default:
+		return nil, fmt.Errorf("Unknown database driver %q", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	database := &Database{DB: db, Postgres: driver == "postgres"}
+	database.refs.Store(1)
+	return database, nil
+}
+
+// Close closes the database once it's no longer shared
+func (db *Database) Close() error {
+	if db.refs.Add(-1) > 0 {
+		return nil
+	}
+	return db.DB.Close()
+}
+
+// Migrate runs the statements creating the tables and indexes of a store, when missing
+func (db *Database) Migrate(statements ...string) error {
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AutoIncrement is the type of the tables' numbered primary keys
+func (db *Database) AutoIncrement() string {
+	if db.Postgres {
+		return "BIGSERIAL PRIMARY KEY"
+	}
+	return "INTEGER PRIMARY KEY AUTOINCREMENT"
+}
+
+// Blob is the type of the binary columns
+func (db *Database) Blob() string {
+	if db.Postgres {
+		return "BYTEA"
+	}
+	return "BLOB"
+}
+
+// Bind numbers the placeholders of the query for Postgres, e.g. $1, which SQLite takes as ?
+func (db *Database) Bind(query string) string {
+	if !db.Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// apiVersions are the versions of the proxy API, each served under its own path prefix, e.g. /v1/
+var apiVersions = []string{"v1"}
+
+// defaultVersion serves the unversioned paths of consumers not asking for a version
+const defaultVersion = "v1"
+
+// versionKey is the request context key of the API version serving the request
+const versionKey contextKey = "version"
+
+// APIVersion returns the version of the API serving the request, for handlers whose behavior changed
+func APIVersion(ctx context.Context) string {
+	if version, ok := ctx.Value(versionKey).(string); ok {
+		return version
+	}
+	return defaultVersion
+}
+
+// NegotiateVersion serves versioned paths, e.g. /v1/{owner}/repos/count, with their version, and unversioned
+// paths with the version of the X-API-Version header, or defaultVersion when there's none. The version is
+// returned in the X-API-Version response header. The /auth/ and /webhooks/ routes aren't versioned, as their
+// urls are registered with GitHub, and neither are /metrics and the /debug/ routes.
+func NegotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/auth/") || strings.HasPrefix(r.URL.Path, "/webhooks/") || r.URL.Path == "/metrics" ||
+			strings.HasPrefix(r.URL.Path, "/debug/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		version, _ := SplitVersion(r.URL.Path)
+		if version == "" {
+			version = r.Header.Get("X-API-Version")
+			if version == "" {
+				version = defaultVersion
+			}
+			if !knownVersion(version) {
+				WriteStatusError(w, http.StatusBadRequest, errors.New("Unknown API version "+version+", supported versions are "+strings.Join(apiVersions, ", ")))
+				return
+			}
+			// route the request as if the version was in the path
+			r.URL.Path = "/" + version + r.URL.Path
+			r.URL.RawPath = ""
+		}
+
+		w.Header().Set("X-API-Version", version)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey, version)))
+	})
+}
+
+// SplitVersion returns the known version prefixing the path and the remaining path, or an empty version when
+// the path isn't versioned
+func SplitVersion(path string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) == 2 && knownVersion(parts[0]) {
+		return parts[0], "/" + parts[1]
+	}
+	return "", path
+}
+
+func knownVersion(version string) bool {
+	for _, v := range apiVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}